@@ -0,0 +1,94 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Kinds of error reported in AuditEvent.ErrorKind
+const (
+	ErrorKindNotFound   = "not_found"
+	ErrorKindTimeout    = "timeout"
+	ErrorKindCanceled   = "canceled"
+	ErrorKindNotAllowed = "not_allowed"
+	ErrorKindOther      = "error"
+)
+
+// AuditEvent records a single secret access. It never contains the secret value.
+type AuditEvent struct {
+	Timestamp time.Time
+	ID        string // secret ID requested
+	Backend   string // name of the backend that served the request
+	Success   bool
+	ErrorKind string // one of the ErrorKind constants, empty on success
+	// Attributes is the metadata attached to the context of the Get with WithAuditMetadata, if any.
+	// Values equal to the secret value are replaced with MaskReplacement.
+	Attributes map[string]string
+}
+
+type auditMetadataKey struct{}
+
+// WithAuditMetadata returns a copy of ctx carrying md, which is included in the AuditEvent of any Get made with the
+// returned context (eg, a request ID or the user on whose behalf the secret is fetched). md is copied, so later
+// changes to it have no effect. Metadata already attached to ctx is kept unless md has the same key.
+func WithAuditMetadata(ctx context.Context, md map[string]string) context.Context {
+	merged := map[string]string{}
+	for k, v := range auditMetadata(ctx) {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, auditMetadataKey{}, merged)
+}
+
+// auditMetadata returns the metadata attached to ctx, if any
+func auditMetadata(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(auditMetadataKey{}).(map[string]string)
+	return md
+}
+
+// errorKind classifies err for audit events
+func errorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrSecretNotFound):
+		return ErrorKindNotFound
+	case errors.Is(err, ErrTimeout):
+		return ErrorKindTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.Is(err, ErrIDNotAllowed):
+		return ErrorKindNotAllowed
+	default:
+		return ErrorKindOther
+	}
+}
+
+// audit emits an AuditEvent for the Get of id, which returned value, if an audit sink is configured
+func (sc *SecretsClient) audit(ctx context.Context, id string, value []byte, err error) {
+	if sc.auditSink == nil {
+		return
+	}
+	var attrs map[string]string
+	if md := auditMetadata(ctx); len(md) > 0 {
+		attrs = make(map[string]string, len(md))
+		for k, v := range md {
+			// guard against callers putting the secret itself in the metadata
+			if len(value) > 0 && v == string(value) {
+				v = MaskReplacement
+			}
+			attrs[k] = v
+		}
+	}
+	sc.auditSink(AuditEvent{
+		Timestamp:  time.Now().UTC(),
+		ID:         sc.hashID(id),
+		Backend:    sc.backendFor(id),
+		Success:    err == nil,
+		ErrorKind:  errorKind(err),
+		Attributes: attrs,
+	})
+}
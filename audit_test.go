@@ -0,0 +1,114 @@
+package pvc
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	events []AuditEvent
+}
+
+func (rs *recordingSink) record(e AuditEvent) {
+	rs.events = append(rs.events, e)
+}
+
+func TestAuditSinkSuccess(t *testing.T) {
+	rs := &recordingSink{}
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONFileLocation("example/secrets.json"), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	before := time.Now().UTC()
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if len(rs.events) != 1 {
+		t.Fatalf("expected 1 event, got %v", len(rs.events))
+	}
+	e := rs.events[0]
+	if e.ID != "foo" || e.Backend != "jsonfile" || !e.Success || e.ErrorKind != "" {
+		t.Fatalf("bad event: %+v", e)
+	}
+	if e.Timestamp.Before(before) {
+		t.Fatalf("bad timestamp: %v", e.Timestamp)
+	}
+}
+
+func TestAuditSinkFailure(t *testing.T) {
+	rs := &recordingSink{}
+	os.Unsetenv("SECRET_MISSING")
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("missing"); err == nil {
+		t.Fatalf("should have failed")
+	}
+	if len(rs.events) != 1 {
+		t.Fatalf("expected 1 event, got %v", len(rs.events))
+	}
+	e := rs.events[0]
+	if e.ID != "missing" || e.Backend != "envvar" || e.Success || e.ErrorKind != ErrorKindNotFound {
+		t.Fatalf("bad event: %+v", e)
+	}
+}
+
+func TestAuditSinkTimeout(t *testing.T) {
+	rs := &recordingSink{}
+	sc := &SecretsClient{
+		backend:   &slowBackend{delay: 500 * time.Millisecond},
+		timeout:   newAtomicDuration(10 * time.Millisecond),
+		auditSink: rs.record,
+	}
+	if _, err := sc.Get("foo"); err == nil {
+		t.Fatalf("should have failed")
+	}
+	if len(rs.events) != 1 || rs.events[0].ErrorKind != ErrorKindTimeout {
+		t.Fatalf("bad events: %+v", rs.events)
+	}
+}
+
+func TestAuditSinkNotAllowed(t *testing.T) {
+	rs := &recordingSink{}
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithAllowedIDs("foo"), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("bar"); err == nil {
+		t.Fatalf("should have failed")
+	}
+	if len(rs.events) != 1 || rs.events[0].ErrorKind != ErrorKindNotAllowed {
+		t.Fatalf("bad events: %+v", rs.events)
+	}
+}
+
+func TestAuditSinkMetadata(t *testing.T) {
+	rs := &recordingSink{}
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "hunter2"}), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	md := map[string]string{"request_id": "abc123", "user": "alice"}
+	ctx := WithAuditMetadata(context.Background(), md)
+	ctx = WithAuditMetadata(ctx, map[string]string{"user": "bob", "leak": "hunter2"})
+	md["request_id"] = "changed"
+	if _, err := sc.GetContext(ctx, "foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if len(rs.events) != 2 {
+		t.Fatalf("expected 2 events, got %v", len(rs.events))
+	}
+	attrs := rs.events[0].Attributes
+	if len(attrs) != 3 || attrs["request_id"] != "abc123" || attrs["user"] != "bob" || attrs["leak"] != MaskReplacement {
+		t.Fatalf("bad attributes: %v", attrs)
+	}
+	if rs.events[1].Attributes != nil {
+		t.Fatalf("expected no attributes without metadata: %v", rs.events[1].Attributes)
+	}
+}
@@ -0,0 +1,64 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GetBatch returns the values of several secrets, fetched concurrently (see GetBatchContext)
+func (sc *SecretsClient) GetBatch(ids []string) (map[string][]byte, error) {
+	return sc.GetBatchContext(context.Background(), ids)
+}
+
+// GetBatchContext fetches the secrets ids concurrently, all within the deadline of ctx, so that a request handler
+// can spend a single latency budget across several secrets. The values that were retrieved are returned keyed by ID
+// even if others weren't, in which case the error lists the failures. If the deadline was exceeded (or ctx was
+// canceled) the error wraps ErrTimeout (or context.Canceled).
+func (sc *SecretsClient) GetBatchContext(ctx context.Context, ids []string) (map[string][]byte, error) {
+	type result struct {
+		id    string
+		value []byte
+		err   error
+	}
+	rc := make(chan result, len(ids))
+	wg := sync.WaitGroup{}
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			v, err := sc.GetContext(ctx, id)
+			rc <- result{id: id, value: v, err: err}
+		}(id)
+	}
+	wg.Wait()
+	close(rc)
+	values := make(map[string][]byte, len(ids))
+	failures := []string{}
+	var cause error
+	for r := range rc {
+		if r.err == nil {
+			values[r.id] = r.value
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("%v: %v", r.id, r.err))
+		switch {
+		case errors.Is(r.err, ErrTimeout):
+			cause = ErrTimeout
+		case errors.Is(r.err, context.Canceled) && cause == nil:
+			cause = context.Canceled
+		}
+	}
+	if len(failures) == 0 {
+		return values, nil
+	}
+	sort.Strings(failures)
+	msg := fmt.Sprintf("%v of %v secrets not retrieved: %v", len(failures), len(ids), strings.Join(failures, "; "))
+	if cause != nil {
+		return values, fmt.Errorf("%w: %v", cause, msg)
+	}
+	return values, fmt.Errorf("error retrieving secrets: %v", msg)
+}
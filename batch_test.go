@@ -0,0 +1,123 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// delayedBackend returns each ID as its own value after the delay configured for it
+type delayedBackend map[string]time.Duration
+
+func (db delayedBackend) Get(id string) ([]byte, error) {
+	d, ok := db[id]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+	time.Sleep(d)
+	return []byte(id), nil
+}
+
+func TestGetBatch(t *testing.T) {
+	sc := &SecretsClient{backend: delayedBackend{"foo": 0, "bar": 0}}
+	values, err := sc.GetBatch([]string{"foo", "bar"})
+	if err != nil {
+		t.Fatalf("batch get failed: %v", err)
+	}
+	if len(values) != 2 || string(values["foo"]) != "foo" || string(values["bar"]) != "bar" {
+		t.Fatalf("bad values: %v", values)
+	}
+}
+
+func TestGetBatchContextDeadline(t *testing.T) {
+	sc := &SecretsClient{backend: delayedBackend{
+		"fast1": time.Millisecond,
+		"fast2": time.Millisecond,
+		"slow":  time.Second,
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	values, err := sc.GetBatchContext(ctx, []string{"fast1", "slow", "fast2"})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("batch should have respected the deadline: %v", elapsed)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, received: %v", err)
+	}
+	if len(values) != 2 || string(values["fast1"]) != "fast1" || string(values["fast2"]) != "fast2" {
+		t.Fatalf("fast secrets should have been returned: %v", values)
+	}
+}
+
+func TestGetBatchPartialFailure(t *testing.T) {
+	sc := &SecretsClient{backend: delayedBackend{"foo": 0}}
+	values, err := sc.GetBatch([]string{"foo", "missing"})
+	if err == nil || errors.Is(err, ErrTimeout) {
+		t.Fatalf("should have failed without a timeout: %v", err)
+	}
+	if string(values["foo"]) != "foo" {
+		t.Fatalf("retrieved secrets should have been returned: %v", values)
+	}
+}
+
+// inFlightBackend records the most calls to Get in flight at once
+type inFlightBackend struct {
+	inFlight, max int32
+}
+
+func (cb *inFlightBackend) Get(id string) ([]byte, error) {
+	n := atomic.AddInt32(&cb.inFlight, 1)
+	defer atomic.AddInt32(&cb.inFlight, -1)
+	for {
+		m := atomic.LoadInt32(&cb.max)
+		if n <= m || atomic.CompareAndSwapInt32(&cb.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return []byte(id), nil
+}
+
+func TestGetBatchMaxConcurrency(t *testing.T) {
+	cb := &inFlightBackend{}
+	sc := &SecretsClient{backend: cb, slots: make(chan struct{}, 3)}
+	ids := []string{}
+	for i := 0; i < 20; i++ {
+		ids = append(ids, fmt.Sprintf("secret%v", i))
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sc.Scoped("batch/").GetBatch(ids); err != nil {
+				t.Errorf("batch get failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if cb.max > 3 {
+		t.Fatalf("%v calls were in flight at once (limit 3)", cb.max)
+	}
+	if cb.max < 2 {
+		t.Fatalf("calls should have run concurrently: %v", cb.max)
+	}
+}
+
+func TestNewSecretsClientMaxConcurrency(t *testing.T) {
+	if _, err := NewSecretsClient(WithNoopBackend(), WithMaxConcurrency(-1)); err == nil {
+		t.Fatalf("should have failed with negative max concurrency")
+	}
+	sc, err := NewSecretsClient(WithNoopBackend(), WithMaxConcurrency(2))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if cap(sc.slots) != 2 {
+		t.Fatalf("bad slots: %v", cap(sc.slots))
+	}
+}
@@ -0,0 +1,304 @@
+package pvc
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// secretCache holds secret values retrieved from the backend until they expire
+type secretCache struct {
+	sync.Mutex
+	ttl      time.Duration            // default TTL for entries
+	ttls     map[string]time.Duration // TTLs overriding ttl for particular IDs
+	maxStale time.Duration            // how long expired entries are kept for getStale
+	entries  map[string]cacheEntry
+	max      int                         // if positive, the most entries held, beyond which the least recently used are evicted
+	lru      *list.List                  // keys of entries, most recently used first
+	parsed   map[parsedKey]reflect.Value // values decoded by GetCachedInto, dropped along with their entry
+	now      func() time.Time
+	key      func(id string) string // if set, maps IDs to the keys entries are held under (see WithIDHashing)
+
+	hits, misses, evictions uint64 // accessed atomically
+}
+
+// CacheStats are counts of cache activity since the client was created (see SecretsClient.CacheStats)
+type CacheStats struct {
+	Hits      uint64 // Gets served from the cache
+	Misses    uint64 // Gets that found no unexpired value in the cache
+	Evictions uint64 // expired entries removed, and entries evicted to stay within WithCacheMaxEntries
+	Size      int    // entries currently held, including expired ones not yet removed
+}
+
+// CacheStats returns the cache statistics of the client, which are zero if caching isn't enabled (see WithCache)
+func (sc *SecretsClient) CacheStats() CacheStats {
+	if sc.cache == nil {
+		return CacheStats{}
+	}
+	sc.cache.Lock()
+	size := len(sc.cache.entries)
+	sc.cache.Unlock()
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&sc.cache.hits),
+		Misses:    atomic.LoadUint64(&sc.cache.misses),
+		Evictions: atomic.LoadUint64(&sc.cache.evictions),
+		Size:      size,
+	}
+}
+
+// parsedKey identifies a value decoded by GetCachedInto, which may be called with different types for the same ID
+type parsedKey struct {
+	id  string
+	typ reflect.Type
+}
+
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+	elem    *list.Element // position in the LRU list
+}
+
+func newSecretCache(ttl time.Duration) *secretCache {
+	return &secretCache{
+		ttl:     ttl,
+		entries: map[string]cacheEntry{},
+		lru:     list.New(),
+		parsed:  map[parsedKey]reflect.Value{},
+		now:     time.Now,
+	}
+}
+
+// keyFor returns the key the entry for id is held under
+func (c *secretCache) keyFor(id string) string {
+	if c.key == nil {
+		return id
+	}
+	return c.key(id)
+}
+
+// get returns a copy of the cached value for id if it exists and hasn't expired
+func (c *secretCache) get(id string) ([]byte, bool) {
+	id = c.keyFor(id)
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[id]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	if !c.now().Before(e.expires) {
+		if !c.now().Before(e.expires.Add(c.maxStale)) {
+			c.drop(id)
+			atomic.AddUint64(&c.evictions, 1)
+		}
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.lru.MoveToFront(e.elem)
+	return append([]byte(nil), e.value...), true
+}
+
+// peek returns a copy of the cached value for id if it hasn't expired, without counting it in the stats
+func (c *secretCache) peek(id string) ([]byte, bool) {
+	id = c.keyFor(id)
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[id]
+	if !ok || !c.now().Before(e.expires) {
+		return nil, false
+	}
+	return append([]byte(nil), e.value...), true
+}
+
+// getStale returns a copy of the cached value for id, even if it has expired, as long as it expired less than maxStale ago
+func (c *secretCache) getStale(id string) ([]byte, bool) {
+	id = c.keyFor(id)
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[id]
+	if !ok || !c.now().Before(e.expires.Add(c.maxStale)) {
+		return nil, false
+	}
+	return append([]byte(nil), e.value...), true
+}
+
+// drop removes the entry for id and any values decoded from it. The caller must hold the lock.
+func (c *secretCache) drop(id string) {
+	if e, ok := c.entries[id]; ok {
+		c.lru.Remove(e.elem)
+	}
+	delete(c.entries, id)
+	for k := range c.parsed {
+		if k.id == id {
+			delete(c.parsed, k)
+		}
+	}
+}
+
+// getParsed returns the value of type typ decoded from the entry for id, and a copy of the entry's value, if the
+// entry hasn't expired. A hit is counted like one from get; misses are left to the get that follows.
+func (c *secretCache) getParsed(id string, typ reflect.Type) (reflect.Value, []byte, bool) {
+	id = c.keyFor(id)
+	c.Lock()
+	defer c.Unlock()
+	v, ok := c.parsed[parsedKey{id: id, typ: typ}]
+	if !ok {
+		return reflect.Value{}, nil, false
+	}
+	e, ok := c.entries[id]
+	if !ok || !c.now().Before(e.expires) {
+		return reflect.Value{}, nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.lru.MoveToFront(e.elem)
+	return v, append([]byte(nil), e.value...), true
+}
+
+// setParsed caches v, decoded from value, for id. It is discarded unless value is still the cached entry for id,
+// so that it expires with the entry.
+func (c *secretCache) setParsed(id string, value []byte, v reflect.Value) {
+	id = c.keyFor(id)
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[id]; ok && string(e.value) == string(value) {
+		c.parsed[parsedKey{id: id, typ: v.Type()}] = v
+	}
+}
+
+// defaultTTL returns the TTL for id when the backend doesn't suggest one
+func (c *secretCache) defaultTTL(id string) time.Duration {
+	c.Lock()
+	defer c.Unlock()
+	if ttl, ok := c.ttls[id]; ok {
+		return ttl
+	}
+	return c.ttl
+}
+
+// set caches a copy of value for id. A positive ttl overrides the default TTL, a negative one prevents caching.
+func (c *secretCache) set(id string, value []byte, ttl time.Duration) {
+	switch {
+	case ttl < 0:
+		return
+	case ttl == 0:
+		ttl = c.defaultTTL(id)
+	}
+	id = c.keyFor(id)
+	c.Lock()
+	defer c.Unlock()
+	c.drop(id)
+	c.entries[id] = cacheEntry{
+		value:   append([]byte(nil), value...),
+		expires: c.now().Add(ttl),
+		elem:    c.lru.PushFront(id),
+	}
+	for c.max > 0 && len(c.entries) > c.max {
+		oldest := c.lru.Back().Value.(string)
+		wipe(c.entries[oldest].value)
+		c.drop(oldest)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// wipe zeroes b, so that an evicted value doesn't linger in memory until it is collected
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Warmup concurrently fetches ids into the cache, eg at startup so that later Gets don't wait on the backend.
+// Caching must be enabled with WithCache. If any secrets couldn't be fetched the error lists them (see GetBatchContext).
+func (sc *SecretsClient) Warmup(ctx context.Context, ids ...string) error {
+	if sc.cache == nil {
+		return fmt.Errorf("warmup requires caching to be enabled (see WithCache)")
+	}
+	_, err := sc.GetBatchContext(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("error warming up cache: %w", err)
+	}
+	return nil
+}
+
+// GetCachedInto decodes the JSON secret id into the value pointed to by v, like json.Unmarshal. The decoded value is
+// cached alongside the secret and expires with it, so repeated calls copy it into v rather than decoding again.
+// The copy is shallow: maps, slices and pointers in v are shared with the cache and must not be modified.
+// Caching must be enabled with WithCache.
+func (sc *SecretsClient) GetCachedInto(id string, v interface{}) error {
+	if sc.cache == nil {
+		return fmt.Errorf("GetCachedInto requires caching to be enabled (see WithCache)")
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("GetCachedInto requires a non-nil pointer, got %T", v)
+	}
+	typ := rv.Elem().Type()
+	// a disallowed ID falls through to Get, which reports and audits it
+	if sc.checkAllowed(sc.prefix+id) == nil {
+		if pv, s, ok := sc.cache.getParsed(sc.prefix+id, typ); ok {
+			sc.audit(context.Background(), sc.prefix+id, s, nil)
+			rv.Elem().Set(pv)
+			return nil
+		}
+	}
+	s, err := sc.Get(id)
+	if err != nil {
+		return err
+	}
+	pv := reflect.New(typ)
+	if err := json.Unmarshal(s, pv.Interface()); err != nil {
+		return fmt.Errorf("error decoding secret %v: %v", id, err)
+	}
+	sc.cache.setParsed(sc.prefix+id, s, pv.Elem())
+	rv.Elem().Set(pv.Elem())
+	return nil
+}
+
+// SharedCache is a cache of secret values shared between clients, such as Redis (see WithSharedCache). Keys are the
+// backend name and the location of the secret in the backend (eg, "vault:secret/foo"), so clients with different
+// mappings share entries for the same secret. Implementations must be safe for concurrent use.
+type SharedCache interface {
+	// Get returns the value for key, and whether there was an unexpired one
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for key, to expire after ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes any value for key
+	Delete(ctx context.Context, key string) error
+}
+
+// sharedKey returns the shared cache key for id, whose location in the backend is loc (if known)
+func (sc *SecretsClient) sharedKey(id, loc string) string {
+	if loc == "" {
+		loc = id
+	}
+	return sc.backendFor(id) + ":" + sc.hashID(loc)
+}
+
+// getShared looks for id in the shared cache, adding it to the in-memory cache if found. Errors from the shared
+// cache are treated as misses, so that it being unavailable just means falling back to the backend.
+func (sc *SecretsClient) getShared(ctx context.Context, id, loc string) ([]byte, bool) {
+	v, ok, err := sc.shared.Get(ctx, sc.sharedKey(id, loc))
+	if err != nil || !ok {
+		return nil, false
+	}
+	sc.cache.set(id, v, 0)
+	return v, true
+}
+
+// setShared writes a value fetched from the backend to the shared cache, with the TTL the backend suggested
+// or else the in-memory cache TTL. Errors are ignored, as the value has been fetched regardless.
+func (sc *SecretsClient) setShared(ctx context.Context, id, loc string, value []byte, ttl time.Duration) {
+	switch {
+	case ttl < 0:
+		return
+	case ttl == 0:
+		ttl = sc.cache.defaultTTL(id)
+	}
+	sc.shared.Set(ctx, sc.sharedKey(id, loc), value, ttl)
+}
@@ -0,0 +1,527 @@
+package pvc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBackend returns a fixed value for every ID, counting the calls made
+type countingBackend struct {
+	calls int32
+	value []byte
+}
+
+func (cb *countingBackend) Get(id string) ([]byte, error) {
+	atomic.AddInt32(&cb.calls, 1)
+	return cb.value, nil
+}
+
+func TestCacheHit(t *testing.T) {
+	cb := &countingBackend{value: []byte("foo")}
+	sc := &SecretsClient{backend: cb, cache: newSecretCache(time.Minute)}
+	for i := 0; i < 3; i++ {
+		s, err := sc.Get("bar")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(s) != "foo" {
+			t.Fatalf("bad value: %v", string(s))
+		}
+		s[0] = 'x' // must not affect the cached value
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 1 {
+		t.Fatalf("expected 1 backend call, got %v", n)
+	}
+}
+
+// withClock makes the client use clock for TTLs instead of time.Now
+func withClock(clock *fakeClock) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.now = clock.now
+	}
+}
+
+// fakeClock is a clock that only moves when advanced
+type fakeClock struct {
+	sync.Mutex
+	t time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (fc *fakeClock) now() time.Time {
+	fc.Lock()
+	defer fc.Unlock()
+	return fc.t
+}
+
+func (fc *fakeClock) advance(d time.Duration) {
+	fc.Lock()
+	defer fc.Unlock()
+	fc.t = fc.t.Add(d)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cb := &countingBackend{value: []byte("foo")}
+	sc := &SecretsClient{backend: cb, cache: newSecretCache(time.Minute)}
+	sc.cache.now = clock.now
+	if _, err := sc.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	clock.advance(time.Minute - time.Nanosecond)
+	if _, err := sc.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 1 {
+		t.Fatalf("value should still be cached, got %v backend calls", n)
+	}
+	clock.advance(time.Nanosecond)
+	if _, err := sc.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 2 {
+		t.Fatalf("expected 2 backend calls, got %v", n)
+	}
+}
+
+func TestCacheSetTTL(t *testing.T) {
+	c := newSecretCache(time.Minute)
+	c.set("default", []byte("foo"), 0)
+	c.set("hinted", []byte("foo"), time.Hour)
+	c.set("uncached", []byte("foo"), -1)
+	now := time.Now()
+	if e := c.entries["default"].expires; e.Before(now.Add(59*time.Second)) || e.After(now.Add(time.Minute)) {
+		t.Fatalf("bad default expiry: %v", e)
+	}
+	if e := c.entries["hinted"].expires; e.Before(now.Add(59*time.Minute)) || e.After(now.Add(time.Hour)) {
+		t.Fatalf("bad hinted expiry: %v", e)
+	}
+	if _, ok := c.entries["uncached"]; ok {
+		t.Fatalf("negative ttl should not be cached")
+	}
+}
+
+func TestWithCacheInvalidTTL(t *testing.T) {
+	if _, err := NewSecretsClient(WithEnvVarBackend(), WithCache(-time.Second)); err == nil {
+		t.Fatalf("should have failed")
+	}
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithCache(time.Second))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if sc.cache == nil || sc.cache.ttl != time.Second {
+		t.Fatalf("cache not configured: %+v", sc.cache)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	cb := &countingBackend{value: []byte("foo")}
+	sc := &SecretsClient{backend: cb, cache: newSecretCache(time.Minute)}
+	ids := []string{"a", "b", "c"}
+	if err := sc.Warmup(context.Background(), ids...); err != nil {
+		t.Fatalf("warmup failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 3 {
+		t.Fatalf("expected 3 backend calls, got %v", n)
+	}
+	for _, id := range ids {
+		s, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(s) != "foo" {
+			t.Fatalf("bad value: %v", string(s))
+		}
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 3 {
+		t.Fatalf("gets after warmup should hit the cache: %v backend calls", n)
+	}
+}
+
+func TestWarmupErrors(t *testing.T) {
+	sc := &SecretsClient{backend: &countingBackend{}}
+	if err := sc.Warmup(context.Background(), "a"); err == nil {
+		t.Fatalf("should have failed without a cache")
+	}
+	sc = &SecretsClient{backend: delayedBackend{"a": 0}, cache: newSecretCache(time.Minute)}
+	err := sc.Warmup(context.Background(), "a", "missing")
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("should have reported the missing secret: %v", err)
+	}
+	if _, ok := sc.cache.get("a"); !ok {
+		t.Fatalf("secrets that were fetched should be cached")
+	}
+}
+
+// memorySharedCache is an in-memory SharedCache recording the TTLs set
+type memorySharedCache struct {
+	sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	gets   int
+}
+
+func newMemorySharedCache() *memorySharedCache {
+	return &memorySharedCache{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (m *memorySharedCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.gets++
+	v, ok := m.values[key]
+	return v, ok, nil
+}
+
+func (m *memorySharedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.values[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+func (m *memorySharedCache) Delete(ctx context.Context, key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+func TestSharedCache(t *testing.T) {
+	shared := newMemorySharedCache()
+	cb := &countingBackend{value: []byte("foo")}
+	sc1 := &SecretsClient{backend: cb, backendName: "test", cache: newSecretCache(time.Minute), shared: shared}
+	sc2 := &SecretsClient{backend: cb, backendName: "test", cache: newSecretCache(time.Minute), shared: shared}
+	// a backend fetch populates both tiers
+	if _, err := sc1.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if _, ok := sc1.cache.get("bar"); !ok {
+		t.Fatalf("value should be in the local cache")
+	}
+	if string(shared.values["test:bar"]) != "foo" || shared.ttls["test:bar"] != time.Minute {
+		t.Fatalf("value should be in the shared cache with the cache TTL: %v", shared.ttls)
+	}
+	// another client's local miss is served by the shared cache
+	r, err := sc2.GetDetailed("bar")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(r.Value) != "foo" || !r.FromCache {
+		t.Fatalf("bad result: %+v", r)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 1 {
+		t.Fatalf("expected 1 backend call, got %v", n)
+	}
+	// and then by its local cache
+	if _, err := sc2.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if shared.gets != 2 {
+		t.Fatalf("local hits should not check the shared cache: %v shared gets", shared.gets)
+	}
+}
+
+func TestSharedCacheKeyUsesLocation(t *testing.T) {
+	shared := newMemorySharedCache()
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithEnvVarBackend(), WithCache(time.Minute), WithSharedCache(shared))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(shared.values["envvar:SECRET_FOO"]) != "bar" {
+		t.Fatalf("bad shared cache contents: %v", shared.values)
+	}
+}
+
+func TestWithSharedCacheRequiresCache(t *testing.T) {
+	_, err := NewSecretsClient(WithEnvVarBackend(), WithSharedCache(newMemorySharedCache()))
+	if err == nil {
+		t.Fatalf("should have failed without WithCache")
+	}
+}
+
+func TestWithCacheTTLFor(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithCache(time.Hour), WithCacheTTLFor(map[string]time.Duration{
+		"short": 20 * time.Millisecond,
+		"long":  time.Minute,
+	}))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	cb := &countingBackend{value: []byte("foo")}
+	sc.backend = cb
+	ids := []string{"short", "long", "unlisted"}
+	for _, id := range ids {
+		if _, err := sc.Get(id); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	now := time.Now()
+	if e := sc.cache.entries["long"].expires; e.Before(now.Add(59*time.Second)) || e.After(now.Add(time.Minute)) {
+		t.Fatalf("long should use its own TTL: %v", e)
+	}
+	if e := sc.cache.entries["unlisted"].expires; e.Before(now.Add(59 * time.Minute)) {
+		t.Fatalf("unlisted should use the WithCache TTL: %v", e)
+	}
+	time.Sleep(30 * time.Millisecond)
+	for _, id := range ids {
+		if _, err := sc.Get(id); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 4 {
+		t.Fatalf("only short should have expired: %v backend calls", n)
+	}
+}
+
+func TestWithCacheTTLForInvalid(t *testing.T) {
+	if _, err := NewSecretsClient(WithEnvVarBackend(), WithCacheTTLFor(map[string]time.Duration{"foo": time.Minute})); err == nil {
+		t.Fatalf("should have failed without WithCache")
+	}
+	if _, err := NewSecretsClient(WithEnvVarBackend(), WithCache(time.Minute), WithCacheTTLFor(map[string]time.Duration{"foo": -1})); err == nil {
+		t.Fatalf("should have failed with a negative TTL")
+	}
+}
+
+// countingCreds counts how many times it is decoded
+type countingCreds struct {
+	User string
+}
+
+var credsDecodes int32
+
+func (cc *countingCreds) UnmarshalJSON(b []byte) error {
+	atomic.AddInt32(&credsDecodes, 1)
+	type plain countingCreds
+	return json.Unmarshal(b, (*plain)(cc))
+}
+
+func TestGetCachedInto(t *testing.T) {
+	atomic.StoreInt32(&credsDecodes, 0)
+	cb := &countingBackend{value: []byte(`{"User": "alice"}`)}
+	sc := &SecretsClient{backend: cb, cache: newSecretCache(20 * time.Millisecond)}
+	for i := 0; i < 3; i++ {
+		var c countingCreds
+		if err := sc.GetCachedInto("creds", &c); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if c.User != "alice" {
+			t.Fatalf("bad value: %+v", c)
+		}
+	}
+	if n := atomic.LoadInt32(&credsDecodes); n != 1 {
+		t.Fatalf("expected 1 decode, got %v", n)
+	}
+	var m map[string]string
+	if err := sc.GetCachedInto("creds", &m); err != nil || m["User"] != "alice" {
+		t.Fatalf("get into a different type failed: %v: %v", m, err)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 1 {
+		t.Fatalf("expected 1 backend call, got %v", n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	var c countingCreds
+	if err := sc.GetCachedInto("creds", &c); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&credsDecodes); n != 2 {
+		t.Fatalf("decoded value should expire with the cache entry, got %v decodes", n)
+	}
+}
+
+func TestGetCachedIntoErrors(t *testing.T) {
+	cb := &countingBackend{value: []byte(`not json`)}
+	sc := &SecretsClient{backend: cb}
+	var m map[string]string
+	if err := sc.GetCachedInto("creds", &m); err == nil {
+		t.Fatalf("should have failed without a cache")
+	}
+	sc.cache = newSecretCache(time.Minute)
+	if err := sc.GetCachedInto("creds", m); err == nil {
+		t.Fatalf("should have failed with a non-pointer")
+	}
+	if err := sc.GetCachedInto("creds", &m); err == nil || strings.Contains(err.Error(), "not json") {
+		t.Fatalf("should have failed without leaking the value: %v", err)
+	}
+}
+
+// flakyBackend returns value until err is set
+type flakyBackend struct {
+	value []byte
+	err   error
+}
+
+func (fb *flakyBackend) Get(id string) ([]byte, error) {
+	if fb.err != nil {
+		return nil, fb.err
+	}
+	return fb.value, nil
+}
+
+func TestServeStaleOnError(t *testing.T) {
+	fb := &flakyBackend{value: []byte("foo")}
+	c := newSecretCache(10 * time.Millisecond)
+	c.maxStale = 50 * time.Millisecond
+	sc := &SecretsClient{backend: fb, cache: c}
+	if _, err := sc.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	fb.err = errors.New("vault unavailable")
+	r, err := sc.GetDetailed("bar")
+	if err != nil {
+		t.Fatalf("should have served stale value: %v", err)
+	}
+	if string(r.Value) != "foo" || !r.FromCache || !r.Stale {
+		t.Fatalf("bad result: %+v", r)
+	}
+	fb.err = ErrSecretNotFound
+	if _, err := sc.Get("bar"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should not serve stale value for a missing secret: %v", err)
+	}
+	fb.err = errors.New("vault unavailable")
+	time.Sleep(50 * time.Millisecond)
+	if _, err := sc.Get("bar"); err == nil {
+		t.Fatalf("should have failed once past max staleness")
+	}
+}
+
+func TestServeStaleOnErrorRequiresCache(t *testing.T) {
+	if _, err := NewSecretsClient(WithEnvVarBackend(), WithServeStaleOnError(time.Minute)); err == nil {
+		t.Fatalf("should have failed without a cache")
+	}
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithCache(time.Minute), WithServeStaleOnError(time.Minute))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if sc.cache.maxStale != time.Minute {
+		t.Fatalf("bad max staleness: %v", sc.cache.maxStale)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	cb := &countingBackend{value: []byte("foo")}
+	sc := &SecretsClient{backend: cb}
+	if s := sc.CacheStats(); s != (CacheStats{}) {
+		t.Fatalf("stats should be zero without a cache: %+v", s)
+	}
+	sc.cache = newSecretCache(20 * time.Millisecond)
+	for _, id := range []string{"a", "a", "b", "a", "b", "c"} {
+		if _, err := sc.Get(id); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if s := sc.CacheStats(); s != (CacheStats{Hits: 3, Misses: 3, Size: 3}) {
+		t.Fatalf("bad stats: %+v", s)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := sc.Get("a"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if s := sc.CacheStats(); s != (CacheStats{Hits: 3, Misses: 4, Evictions: 1, Size: 3}) {
+		t.Fatalf("bad stats after expiry: %+v", s)
+	}
+}
+
+func TestCacheClock(t *testing.T) {
+	clock := newFakeClock()
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithCache(time.Hour), withClock(clock))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for _, d := range []time.Duration{0, 59 * time.Minute, time.Minute} {
+		clock.advance(d)
+		if _, err := sc.Get("foo"); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if st := sc.CacheStats(); st.Hits != 1 || st.Misses != 2 || st.Evictions != 1 {
+		t.Fatalf("entry should have expired after an hour: %+v", st)
+	}
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	cb := &countingBackend{value: []byte("foo")}
+	sc, err := NewSecretsClient(WithNoopBackend(), WithCache(time.Minute), WithCacheMaxEntries(2))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	sc.backend = cb
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if _, err := sc.Get(id); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	// b was the least recently used when c was added
+	evicted := sc.cache.entries["a"].value
+	if _, ok := sc.cache.get("b"); ok {
+		t.Fatalf("b should have been evicted")
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok := sc.cache.get(id); !ok {
+			t.Fatalf("%v should still be cached", id)
+		}
+	}
+	stats := sc.CacheStats()
+	if stats.Size != 2 || stats.Evictions != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	// using c and then adding d evicts a, wiping its value
+	sc.cache.get("c")
+	if _, err := sc.Get("d"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if _, ok := sc.cache.get("a"); ok {
+		t.Fatalf("a should have been evicted")
+	}
+	if string(evicted) != "\x00\x00\x00" {
+		t.Fatalf("evicted value should be wiped: %q", evicted)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 4 {
+		t.Fatalf("expected 4 backend calls, got %v", n)
+	}
+}
+
+func TestCacheMaxEntriesRequiresCache(t *testing.T) {
+	if _, err := NewSecretsClient(WithNoopBackend(), WithCacheMaxEntries(10)); err == nil {
+		t.Fatalf("should have failed without WithCache")
+	}
+}
+
+func TestGetCachedIntoParsedHit(t *testing.T) {
+	rs := &recordingSink{}
+	sc := &SecretsClient{backend: &countingBackend{value: []byte(`{"User": "alice"}`)}, cache: newSecretCache(time.Minute), auditSink: rs.record}
+	for i := 0; i < 2; i++ {
+		var c countingCreds
+		if err := sc.GetCachedInto("creds", &c); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if len(rs.events) != 2 || !rs.events[1].Success || rs.events[1].ID != "creds" {
+		t.Fatalf("decoded cache hits should be audited: %+v", rs.events)
+	}
+	if s := sc.CacheStats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("decoded cache hits should be counted: %+v", s)
+	}
+	sc.allowedIDs = map[string]struct{}{"other": {}}
+	var c countingCreds
+	if err := sc.GetCachedInto("creds", &c); !errors.Is(err, ErrIDNotAllowed) {
+		t.Fatalf("expected ErrIDNotAllowed for a decoded cache hit, got %v", err)
+	}
+	if len(rs.events) != 3 || rs.events[2].Success {
+		t.Fatalf("disallowed reads should be audited: %+v", rs.events)
+	}
+}
@@ -0,0 +1,62 @@
+package pvc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// GetCertificate returns the TLS certificate whose PEM-encoded certificate chain is the secret certID and private key
+// is the secret keyID, eg for tls.Config.Certificates. Errors don't include either value.
+func (sc *SecretsClient) GetCertificate(certID, keyID string) (tls.Certificate, error) {
+	certPEM, err := sc.Get(certID)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if _, err := decodePEM(certID, certPEM, "CERTIFICATE"); err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := sc.Get(keyID)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if _, err := decodePEM(keyID, keyPEM, ""); err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("secrets %v and %v are not a valid certificate and key: %v", certID, keyID, err)
+	}
+	return cert, nil
+}
+
+// GetX509 returns the value of a secret parsed as a PEM-encoded X.509 certificate. If the value holds a chain, the
+// first certificate is returned.
+func (sc *SecretsClient) GetX509(id string) (*x509.Certificate, error) {
+	v, err := sc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decodePEM(id, v, "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(b.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("secret %v is not a valid certificate: %v", id, err)
+	}
+	return cert, nil
+}
+
+// decodePEM returns the first PEM block in the value of secret id, which must be of type typ if typ is set
+func decodePEM(id string, v []byte, typ string) (*pem.Block, error) {
+	b, _ := pem.Decode(v)
+	switch {
+	case b == nil:
+		return nil, fmt.Errorf("secret %v is not PEM-encoded", id)
+	case typ != "" && b.Type != typ:
+		return nil, fmt.Errorf("secret %v is a PEM %v, not a %v", id, b.Type, typ)
+	}
+	return b, nil
+}
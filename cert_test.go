@@ -0,0 +1,94 @@
+package pvc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertPEM returns a self-signed certificate and its private key, PEM-encoded
+func testCertPEM(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pvc.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error encoding key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+	return string(certPEM), string(keyPEM)
+}
+
+func testCertClient(t *testing.T, env map[string]string) *SecretsClient {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(env))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	return sc
+}
+
+func TestGetCertificate(t *testing.T) {
+	certPEM, keyPEM := testCertPEM(t)
+	sc := testCertClient(t, map[string]string{"SECRET_TLS_CERT": certPEM, "SECRET_TLS_KEY": keyPEM})
+	cert, err := sc.GetCertificate("tls_cert", "tls_key")
+	if err != nil {
+		t.Fatalf("get certificate failed: %v", err)
+	}
+	if len(cert.Certificate) != 1 || cert.PrivateKey == nil {
+		t.Fatalf("bad certificate: %+v", cert)
+	}
+	x, err := sc.GetX509("tls_cert")
+	if err != nil {
+		t.Fatalf("get X.509 failed: %v", err)
+	}
+	if x.Subject.CommonName != "pvc.test" {
+		t.Fatalf("bad subject: %v", x.Subject)
+	}
+}
+
+func TestGetCertificateMalformed(t *testing.T) {
+	certPEM, keyPEM := testCertPEM(t)
+	sc := testCertClient(t, map[string]string{
+		"SECRET_CERT":    certPEM,
+		"SECRET_KEY":     keyPEM,
+		"SECRET_NOT_PEM": "hunter2",
+	})
+	for _, c := range []struct {
+		certID, keyID, want string
+	}{
+		{"not_pem", "key", "not_pem is not PEM-encoded"},
+		{"key", "key", "is a PEM EC PRIVATE KEY, not a CERTIFICATE"},
+		{"cert", "not_pem", "not_pem is not PEM-encoded"},
+		{"cert", "cert", "are not a valid certificate and key"},
+	} {
+		_, err := sc.GetCertificate(c.certID, c.keyID)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%v/%v: expected error containing %q: %v", c.certID, c.keyID, c.want, err)
+		}
+		if strings.Contains(err.Error(), "hunter2") {
+			t.Fatalf("error shouldn't include the value: %v", err)
+		}
+	}
+	if _, err := sc.GetX509("not_pem"); err == nil {
+		t.Fatalf("should have failed with a value that isn't PEM")
+	}
+}
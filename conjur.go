@@ -0,0 +1,160 @@
+package pvc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default mapping for this backend
+const (
+	DefaultConjurMapping = "{{ .ID }}"
+)
+
+type conjurBackendGetter struct {
+	httpClient *http.Client
+	mapper     SecretMapper
+	config     *conjurBackend
+	tokenMu    sync.Mutex
+	token      string // base64-encoded access token, empty if not yet authenticated
+}
+
+func newConjurBackendGetter(cb *conjurBackend) (*conjurBackendGetter, error) {
+	if cb.applianceURL == "" {
+		return nil, fmt.Errorf("Conjur appliance URL is required")
+	}
+	if cb.account == "" {
+		return nil, fmt.Errorf("Conjur account is required")
+	}
+	if cb.login == "" || cb.apiKey == "" {
+		return nil, fmt.Errorf("Conjur login and API key are required")
+	}
+	if cb.mapping == "" {
+		cb.mapping = DefaultConjurMapping
+	}
+	if cb.notFound == nil {
+		cb.notFound = DefaultNotFoundDetector
+	}
+	sm, err := newSecretMapper(cb.mapping, cb.mappingRules...)
+	if err != nil {
+		return nil, fmt.Errorf("error with mapping: %v", err)
+	}
+	return &conjurBackendGetter{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		mapper:     sm,
+		config:     cb,
+	}, nil
+}
+
+// authenticate exchanges the API key for a short-lived access token
+func (cbg *conjurBackendGetter) authenticate(ctx context.Context) (string, error) {
+	u := strings.TrimSuffix(cbg.config.applianceURL, "/") + "/authn/" + url.PathEscape(cbg.config.account) + "/" + url.PathEscape(cbg.config.login) + "/authenticate"
+	req, err := http.NewRequest("POST", u, strings.NewReader(cbg.config.apiKey))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept-Encoding", "base64")
+	resp, err := cbg.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error performing request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code from Conjur: %v", resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
+	}
+	// the token is returned base64-encoded when requested, but older servers ignore Accept-Encoding
+	token := strings.TrimSpace(string(b))
+	if strings.HasPrefix(token, "{") {
+		token = base64.StdEncoding.EncodeToString(b)
+	}
+	return token, nil
+}
+
+// getToken returns the current access token, authenticating if there isn't one or refresh is set
+func (cbg *conjurBackendGetter) getToken(ctx context.Context, refresh bool) (string, error) {
+	cbg.tokenMu.Lock()
+	defer cbg.tokenMu.Unlock()
+	if cbg.token != "" && !refresh {
+		return cbg.token, nil
+	}
+	token, err := cbg.authenticate(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error authenticating to Conjur: %v", err)
+	}
+	cbg.token = token
+	return token, nil
+}
+
+// retrieve reads the value of variable, returning the response body and status code
+func (cbg *conjurBackendGetter) retrieve(ctx context.Context, token, variable string) ([]byte, int, error) {
+	u := strings.TrimSuffix(cbg.config.applianceURL, "/") + "/secrets/" + url.PathEscape(cbg.config.account) + "/variable/" + url.PathEscape(variable)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", fmt.Sprintf("Token token=%q", token))
+	resp, err := cbg.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error performing request: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("error reading response: %v", err)
+	}
+	return b, resp.StatusCode, nil
+}
+
+// locate returns the ID of the Conjur variable holding id
+func (cbg *conjurBackendGetter) locate(id string) (string, error) {
+	variable, err := cbg.mapper.MapSecret(id)
+	if err != nil {
+		return "", fmt.Errorf("error mapping id to variable: %v", err)
+	}
+	return variable, nil
+}
+
+func (cbg *conjurBackendGetter) Get(id string) ([]byte, error) {
+	return cbg.GetContext(context.Background(), id)
+}
+
+func (cbg *conjurBackendGetter) GetContext(ctx context.Context, id string) ([]byte, error) {
+	variable, err := cbg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	token, err := cbg.getToken(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	v, status, err := cbg.retrieve(ctx, token, variable)
+	if err == nil && status == http.StatusUnauthorized {
+		// access tokens are short-lived, so get a new one and retry once
+		token, err = cbg.getToken(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		v, status, err = cbg.retrieve(ctx, token, variable)
+	}
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("error reading variable: %v", err)
+	case cbg.config.notFound(status, v):
+		return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, variable)
+	case status != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status code from Conjur: %v", status)
+	}
+	return v, nil
+}
@@ -0,0 +1,127 @@
+package pvc
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// testConjurServer mimics the Conjur authn and secrets endpoints, issuing a new token on every authentication.
+// Only the most recently issued token is accepted, so expiry can be simulated by setting current to "".
+type testConjurServer struct {
+	*httptest.Server
+	current atomic.Value
+	issued  int32
+}
+
+func newTestConjurServer(t *testing.T) *testConjurServer {
+	cs := &testConjurServer{}
+	cs.current.Store("")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authn/myorg/", func(w http.ResponseWriter, r *http.Request) {
+		key, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" || r.URL.EscapedPath() != "/authn/myorg/host%2Fmyapp/authenticate" || string(key) != "apikey" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&cs.issued, 1)
+		token := base64.StdEncoding.EncodeToString([]byte(`{"protected":"x","payload":"` + string(rune('0'+n)) + `"}`))
+		cs.current.Store(token)
+		w.Write([]byte(token))
+	})
+	mux.HandleFunc("/secrets/myorg/variable/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != `Token token="`+cs.current.Load().(string)+`"` {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/secrets/myorg/variable/prod%2Fdb%2Fpassword":
+			w.Write([]byte("hunter2"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	cs.Server = httptest.NewServer(mux)
+	return cs
+}
+
+func testConjurBackendGetter(t *testing.T, url string) *conjurBackendGetter {
+	cbg, err := newConjurBackendGetter(&conjurBackend{
+		applianceURL: url,
+		account:      "myorg",
+		login:        "host/myapp",
+		apiKey:       "apikey",
+	})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	return cbg
+}
+
+func TestNewConjurBackendGetterMissingConfig(t *testing.T) {
+	_, err := newConjurBackendGetter(&conjurBackend{applianceURL: "foo", account: "myorg"})
+	if err == nil {
+		t.Fatalf("should have failed without login")
+	}
+}
+
+func TestConjurBackendGetterGet(t *testing.T) {
+	cs := newTestConjurServer(t)
+	defer cs.Close()
+	cbg := testConjurBackendGetter(t, cs.URL)
+	for i := 0; i < 2; i++ {
+		s, err := cbg.Get("prod/db/password")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(s) != "hunter2" {
+			t.Fatalf("bad value: %v (expected hunter2)", string(s))
+		}
+	}
+	if n := atomic.LoadInt32(&cs.issued); n != 1 {
+		t.Fatalf("token should have been reused: %v authentications", n)
+	}
+}
+
+func TestConjurBackendGetterTokenExpiry(t *testing.T) {
+	cs := newTestConjurServer(t)
+	defer cs.Close()
+	cbg := testConjurBackendGetter(t, cs.URL)
+	if _, err := cbg.Get("prod/db/password"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	cs.current.Store("expired")
+	s, err := cbg.Get("prod/db/password")
+	if err != nil {
+		t.Fatalf("get should have re-authenticated: %v", err)
+	}
+	if string(s) != "hunter2" {
+		t.Fatalf("bad value: %v (expected hunter2)", string(s))
+	}
+	if n := atomic.LoadInt32(&cs.issued); n != 2 {
+		t.Fatalf("expected 2 authentications: %v", n)
+	}
+}
+
+func TestConjurBackendGetterNotFound(t *testing.T) {
+	cs := newTestConjurServer(t)
+	defer cs.Close()
+	cbg := testConjurBackendGetter(t, cs.URL)
+	if _, err := cbg.Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestConjurBackendGetterBadAPIKey(t *testing.T) {
+	cs := newTestConjurServer(t)
+	defer cs.Close()
+	cbg := testConjurBackendGetter(t, cs.URL)
+	cbg.config.apiKey = "wrong"
+	if _, err := cbg.Get("prod/db/password"); err == nil {
+		t.Fatalf("should have failed with bad API key")
+	}
+}
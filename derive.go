@@ -0,0 +1,25 @@
+package pvc
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+)
+
+// DeriveKey returns a key of length bytes derived from the secret masterID with HKDF-SHA256 (RFC 5869), so that one
+// high-entropy master secret can stand in for many per-purpose keys. info distinguishes the purposes: the same master
+// secret and info always give the same key. No salt is used, so the master secret must already be uniformly random.
+func (sc *SecretsClient) DeriveKey(masterID string, info []byte, length int) ([]byte, error) {
+	if length <= 0 || length > 255*sha256.Size {
+		return nil, fmt.Errorf("invalid key length: %v (must be between 1 and %v)", length, 255*sha256.Size)
+	}
+	master, err := sc.Get(masterID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hkdf.Key(sha256.New, master, nil, string(info), length)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %v", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,44 @@
+package pvc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 5869 test case 3: 22 0x0b bytes, no salt or info
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_MASTER": strings.Repeat("\x0b", 22)}))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	key, err := sc.DeriveKey("master", nil, 42)
+	if err != nil {
+		t.Fatalf("derive failed: %v", err)
+	}
+	if want := "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"; hex.EncodeToString(key) != want {
+		t.Fatalf("bad key: %x (expected %v)", key, want)
+	}
+	k1, err := sc.DeriveKey("master", []byte("session-cookies"), 32)
+	if err != nil {
+		t.Fatalf("derive failed: %v", err)
+	}
+	k2, err := sc.DeriveKey("master", []byte("session-cookies"), 32)
+	if err != nil {
+		t.Fatalf("derive failed: %v", err)
+	}
+	k3, err := sc.DeriveKey("master", []byte("csrf-tokens"), 32)
+	if err != nil {
+		t.Fatalf("derive failed: %v", err)
+	}
+	if !bytes.Equal(k1, k2) {
+		t.Fatalf("derivation should be deterministic: %x != %x", k1, k2)
+	}
+	if bytes.Equal(k1, k3) {
+		t.Fatalf("different info should give different keys")
+	}
+	if _, err := sc.DeriveKey("master", nil, 0); err == nil {
+		t.Fatalf("should have failed with zero length")
+	}
+}
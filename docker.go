@@ -0,0 +1,84 @@
+package pvc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Defaults for this backend
+const (
+	DefaultDockerSecretsMapping = "{{ .ID }}"
+	DefaultDockerSecretsDir     = "/run/secrets"
+)
+
+type dockerSecretsBackendGetter struct {
+	mapper SecretMapper
+	config *dockerSecretsBackend
+}
+
+func newDockerSecretsBackendGetter(db *dockerSecretsBackend) (*dockerSecretsBackendGetter, error) {
+	if db.dir == "" {
+		db.dir = DefaultDockerSecretsDir
+	}
+	if db.mapping == "" {
+		db.mapping = DefaultDockerSecretsMapping
+	}
+	sm, err := newSecretMapper(db.mapping, db.mappingRules...)
+	if err != nil {
+		return nil, fmt.Errorf("error with mapping: %v", err)
+	}
+	return &dockerSecretsBackendGetter{
+		mapper: sm,
+		config: db,
+	}, nil
+}
+
+// locate returns the path of the file holding id. Names that would refer to a file outside the secrets directory are rejected.
+func (dbg *dockerSecretsBackendGetter) locate(id string) (string, error) {
+	name, err := dbg.mapper.MapSecret(id)
+	if err != nil {
+		return "", fmt.Errorf("error mapping id to file: %v", err)
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid secret file name: %q", name)
+	}
+	return filepath.Join(dbg.config.dir, name), nil
+}
+
+// Stat returns the size and modification time of the file holding id
+func (dbg *dockerSecretsBackendGetter) Stat(id string) (SecretInfo, error) {
+	path, err := dbg.locate(id)
+	if err != nil {
+		return SecretInfo{}, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SecretInfo{}, nil
+		}
+		return SecretInfo{}, fmt.Errorf("error reading secret file: %v", err)
+	}
+	return SecretInfo{Exists: true, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+func (dbg *dockerSecretsBackendGetter) Get(id string) ([]byte, error) {
+	path, err := dbg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, path)
+		}
+		return nil, fmt.Errorf("error reading secret file: %v", err)
+	}
+	// files created with eg "echo secret | docker secret create" end in a newline that isn't part of the secret
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	b = bytes.TrimSuffix(b, []byte("\r"))
+	return b, nil
+}
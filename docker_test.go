@@ -0,0 +1,64 @@
+package pvc
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func testDockerSecretsDir(t *testing.T) string {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"db_password": "hunter2\n",
+		"api_key":     "k3y",
+		"cert":        "line1\nline2\n\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0400); err != nil {
+			t.Fatalf("error writing secret: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestDockerSecretsBackend(t *testing.T) {
+	sc, err := NewSecretsClient(WithDockerSecretsBackend(), WithDockerSecretsDir(testDockerSecretsDir(t)))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for id, want := range map[string]string{"db_password": "hunter2", "api_key": "k3y", "cert": "line1\nline2\n"} {
+		v, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(v) != want {
+			t.Fatalf("bad value for %v: %q", id, v)
+		}
+	}
+	if _, err := sc.Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestDockerSecretsBackendInvalidName(t *testing.T) {
+	sc, err := NewSecretsClient(WithDockerSecretsBackend(), WithDockerSecretsDir(testDockerSecretsDir(t)))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for _, id := range []string{"../etc/passwd", "..", "sub/file"} {
+		if _, err := sc.Get(id); err == nil || errors.Is(err, ErrSecretNotFound) {
+			t.Fatalf("%v should have been rejected: %v", id, err)
+		}
+	}
+}
+
+func TestDockerSecretsBackendDefaultDir(t *testing.T) {
+	dbg, err := newDockerSecretsBackendGetter(&dockerSecretsBackend{})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	path, err := dbg.locate("foo")
+	if err != nil || path != "/run/secrets/foo" {
+		t.Fatalf("bad path: %v: %v", path, err)
+	}
+}
@@ -0,0 +1,149 @@
+package pvc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults for this backend
+const (
+	DefaultDopplerMapping = "{{ .ID }}"
+	DefaultDopplerHost    = "https://api.doppler.com"
+)
+
+type dopplerBackendGetter struct {
+	httpClient *http.Client
+	mapper     SecretMapper
+	config     *dopplerBackend
+}
+
+func newDopplerBackendGetter(db *dopplerBackend) (*dopplerBackendGetter, error) {
+	if db.token == "" {
+		return nil, fmt.Errorf("Doppler token is required")
+	}
+	// service tokens are scoped to a config, other tokens need it given
+	if (db.project == "" || db.config == "") && !strings.HasPrefix(db.token, "dp.st.") {
+		return nil, fmt.Errorf("Doppler project and config are required")
+	}
+	if db.host == "" {
+		db.host = DefaultDopplerHost
+	}
+	if db.mapping == "" {
+		db.mapping = DefaultDopplerMapping
+	}
+	if db.notFound == nil {
+		db.notFound = DefaultNotFoundDetector
+	}
+	sm, err := newSecretMapper(db.mapping, db.mappingRules...)
+	if err != nil {
+		return nil, fmt.Errorf("error with mapping: %v", err)
+	}
+	return &dopplerBackendGetter{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		mapper:     sm,
+		config:     db,
+	}, nil
+}
+
+// locate returns the name of the Doppler secret holding id
+func (dbg *dopplerBackendGetter) locate(id string) (string, error) {
+	name, err := dbg.mapper.MapSecret(id)
+	if err != nil {
+		return "", fmt.Errorf("error mapping id to secret name: %v", err)
+	}
+	return name, nil
+}
+
+// request performs an authenticated GET of path in the configured project and config, with the additional query
+// parameters q, returning the response body. It returns ErrSecretNotFound if the server says the secret doesn't exist.
+func (dbg *dopplerBackendGetter) request(ctx context.Context, path string, q url.Values) ([]byte, error) {
+	if dbg.config.project != "" {
+		q.Set("project", dbg.config.project)
+	}
+	if dbg.config.config != "" {
+		q.Set("config", dbg.config.config)
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(dbg.config.host, "/")+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+dbg.config.token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := dbg.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error performing request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %v", err)
+	}
+	switch {
+	case dbg.config.notFound(resp.StatusCode, body):
+		return nil, ErrSecretNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status code from Doppler: %v", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func (dbg *dopplerBackendGetter) Get(id string) ([]byte, error) {
+	return dbg.GetContext(context.Background(), id)
+}
+
+// GetContext returns the computed value of the secret id, with any references to other secrets resolved
+func (dbg *dopplerBackendGetter) GetContext(ctx context.Context, id string) ([]byte, error) {
+	name, err := dbg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	body, err := dbg.request(ctx, "/v3/configs/config/secret", url.Values{"name": []string{name}})
+	if err != nil {
+		return nil, fmt.Errorf("error getting secret %v: %w", name, err)
+	}
+	out := struct {
+		Value *struct {
+			Computed string `json:"computed"`
+		} `json:"value"`
+	}{}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+	if out.Value == nil {
+		return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, name)
+	}
+	return []byte(out.Value.Computed), nil
+}
+
+// GetAll returns every secret in the config with a single request, keyed by name. As names can't be mapped back to
+// IDs, ErrNotSupported is returned unless the default mapping is used. The DOPPLER_PROJECT, DOPPLER_CONFIG and
+// DOPPLER_ENVIRONMENT variables Doppler adds are left out.
+func (dbg *dopplerBackendGetter) GetAll() (map[string][]byte, error) {
+	if dbg.config.mapping != DefaultDopplerMapping || len(dbg.config.mappingRules) > 0 {
+		return nil, ErrNotSupported
+	}
+	body, err := dbg.request(context.Background(), "/v3/configs/config/secrets/download", url.Values{"format": []string{"json"}})
+	if err != nil {
+		return nil, fmt.Errorf("error downloading secrets: %w", err)
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(body, &secrets); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+	all := make(map[string][]byte, len(secrets))
+	for name, v := range secrets {
+		switch name {
+		case "DOPPLER_PROJECT", "DOPPLER_CONFIG", "DOPPLER_ENVIRONMENT":
+			continue
+		}
+		all[name] = []byte(v)
+	}
+	return all, nil
+}
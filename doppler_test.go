@@ -0,0 +1,120 @@
+package pvc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testDopplerServer mimics the Doppler secret and download endpoints for config prd of project myapp
+func testDopplerServer(t *testing.T) *httptest.Server {
+	secrets := map[string]string{"DB_PASSWORD": "hunter2", "API_KEY": "abc123"}
+	check := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer dp.pt.footoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		if q := r.URL.Query(); q.Get("project") != "myapp" || q.Get("config") != "prd" {
+			w.WriteHeader(http.StatusBadRequest)
+			return false
+		}
+		return true
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/configs/config/secret", func(w http.ResponseWriter, r *http.Request) {
+		if !check(w, r) {
+			return
+		}
+		name := r.URL.Query().Get("name")
+		v, ok := secrets[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"messages": []string{"Could not find requested secret"}, "success": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":  name,
+			"value": map[string]string{"raw": v, "computed": v},
+		})
+	})
+	mux.HandleFunc("/v3/configs/config/secrets/download", func(w http.ResponseWriter, r *http.Request) {
+		if !check(w, r) {
+			return
+		}
+		if r.URL.Query().Get("format") != "json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		out := map[string]string{"DOPPLER_PROJECT": "myapp", "DOPPLER_CONFIG": "prd", "DOPPLER_ENVIRONMENT": "prd"}
+		for k, v := range secrets {
+			out[k] = v
+		}
+		json.NewEncoder(w).Encode(out)
+	})
+	return httptest.NewServer(mux)
+}
+
+func testDopplerClient(t *testing.T, host string, ops ...SecretsClientOption) *SecretsClient {
+	ops = append([]SecretsClientOption{
+		WithDopplerBackend(),
+		WithDopplerHost(host),
+		WithDopplerToken("dp.pt.footoken"),
+		WithDopplerProject("myapp"),
+		WithDopplerConfig("prd"),
+	}, ops...)
+	sc, err := NewSecretsClient(ops...)
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	return sc
+}
+
+func TestNewDopplerBackendGetterMissingConfig(t *testing.T) {
+	if _, err := newDopplerBackendGetter(&dopplerBackend{project: "myapp", config: "prd"}); err == nil {
+		t.Fatalf("should have failed without a token")
+	}
+	if _, err := newDopplerBackendGetter(&dopplerBackend{token: "dp.pt.foo", project: "myapp"}); err == nil {
+		t.Fatalf("should have failed without a config")
+	}
+	if _, err := newDopplerBackendGetter(&dopplerBackend{token: "dp.st.prd.foo"}); err != nil {
+		t.Fatalf("service token shouldn't need a project or config: %v", err)
+	}
+}
+
+func TestDopplerBackendGetterGet(t *testing.T) {
+	ts := testDopplerServer(t)
+	defer ts.Close()
+	s, err := testDopplerClient(t, ts.URL).Get("DB_PASSWORD")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "hunter2" {
+		t.Fatalf("bad value: %v (expected hunter2)", string(s))
+	}
+}
+
+func TestDopplerBackendGetterGetMissing(t *testing.T) {
+	ts := testDopplerServer(t)
+	defer ts.Close()
+	_, err := testDopplerClient(t, ts.URL).Get("MISSING")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestDopplerBackendGetterGetAll(t *testing.T) {
+	ts := testDopplerServer(t)
+	defer ts.Close()
+	all, err := testDopplerClient(t, ts.URL).GetAll()
+	if err != nil {
+		t.Fatalf("get all failed: %v", err)
+	}
+	if len(all) != 2 || string(all["DB_PASSWORD"]) != "hunter2" || string(all["API_KEY"]) != "abc123" {
+		t.Fatalf("bad secrets: %v", all)
+	}
+	if _, err := testDopplerClient(t, ts.URL, WithMapping("MYAPP_{{ .ID }}")).GetAll(); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("should have returned ErrNotSupported with a mapping: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package pvc
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewSecretsClientFromDSN returns a SecretsClient configured from a single URL-style connection string. Supported schemes:
+//
+//	vault://host:8200/secret?auth=token&token=...     Vault at https://host:8200 with default mapping "secret/{{ .ID }}"
+//	jsonfile:///etc/secrets.json                      JSON file backend reading /etc/secrets.json (or jsonfile:relative/path.json)
+//	env://?mapping=MYAPP_{{ .ID }}                    environment variable backend
+//
+// The mapping, timeout (eg, "5s") and cache (a cache TTL, eg "1m") query parameters apply to every scheme.
+// For vault, tls=false uses plain HTTP, auth is one of none, token, appid, approle or k8s (default: token), and the
+// credentials for the chosen method are given by the token, app_id, user_id, user_id_path, role_id, jwt and
+// k8s_auth_path parameters. auth_retries and auth_retry_delay (seconds) set the authentication retry behavior.
+// The dotenv scheme is recognized but there is no dotenv backend, so it is rejected.
+func NewSecretsClientFromDSN(dsn string) (*SecretsClient, error) {
+	ops, err := parseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing DSN: %v", err)
+	}
+	return NewSecretsClient(ops...)
+}
+
+// parseDSN returns the options equivalent to dsn
+func parseDSN(dsn string) ([]SecretsClientOption, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	ops := []SecretsClientOption{}
+	if m := q.Get("mapping"); m != "" {
+		ops = append(ops, WithMapping(m))
+	}
+	if t := q.Get("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %v", err)
+		}
+		ops = append(ops, WithTimeout(d))
+	}
+	if c := q.Get("cache"); c != "" {
+		d, err := time.ParseDuration(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache TTL: %v", err)
+		}
+		ops = append(ops, WithCache(d))
+	}
+	switch u.Scheme {
+	case "vault":
+		vops, err := parseVaultDSN(u, q)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, vops...)
+	case "jsonfile":
+		if u.Host != "" {
+			return nil, fmt.Errorf("jsonfile DSN must not have a host (use jsonfile:///absolute/path or jsonfile:relative/path)")
+		}
+		path := u.Path
+		if u.Opaque != "" {
+			path = u.Opaque
+		}
+		if path == "" {
+			return nil, fmt.Errorf("jsonfile DSN requires a file path")
+		}
+		ops = append(ops, WithJSONFileBackend(), WithJSONFileLocation(path))
+	case "env":
+		ops = append(ops, WithEnvVarBackend())
+	case "dotenv":
+		return nil, fmt.Errorf("dotenv backend is not supported")
+	default:
+		return nil, fmt.Errorf("unknown scheme: %q", u.Scheme)
+	}
+	return ops, nil
+}
+
+// parseVaultDSN returns the Vault backend options for a vault:// DSN
+func parseVaultDSN(u *url.URL, q url.Values) ([]SecretsClientOption, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("vault DSN requires a host")
+	}
+	scheme := "https"
+	if q.Get("tls") == "false" {
+		scheme = "http"
+	}
+	ops := []SecretsClientOption{
+		WithVaultBackend(),
+		WithVaultHost(scheme + "://" + u.Host),
+	}
+	if p := strings.Trim(u.Path, "/"); p != "" && q.Get("mapping") == "" {
+		ops = append(ops, WithMapping(p+"/{{ .ID }}"))
+	}
+	// require returns an error if any of params are missing
+	require := func(auth string, params ...string) error {
+		for _, p := range params {
+			if q.Get(p) == "" {
+				return fmt.Errorf("%v authentication requires the %v parameter", auth, p)
+			}
+		}
+		return nil
+	}
+	auth := q.Get("auth")
+	switch auth {
+	case "none":
+		ops = append(ops, WithVaultAuthentication(None))
+	case "", "token":
+		if err := require("token", "token"); err != nil {
+			return nil, err
+		}
+		ops = append(ops, WithVaultAuthentication(Token), WithVaultToken(q.Get("token")))
+	case "appid":
+		if err := require(auth, "app_id"); err != nil {
+			return nil, err
+		}
+		if q.Get("user_id") == "" && q.Get("user_id_path") == "" {
+			return nil, fmt.Errorf("appid authentication requires the user_id or user_id_path parameter")
+		}
+		ops = append(ops, WithVaultAuthentication(AppID), WithVaultAppID(q.Get("app_id")), WithVaultUserID(q.Get("user_id")), WithVaultUserIDPath(q.Get("user_id_path")))
+	case "approle":
+		if err := require(auth, "role_id"); err != nil {
+			return nil, err
+		}
+		ops = append(ops, WithVaultAuthentication(AppRole), WithVaultRoleID(q.Get("role_id")))
+	case "k8s":
+		if err := require(auth, "jwt", "role_id"); err != nil {
+			return nil, err
+		}
+		ops = append(ops, WithVaultK8sAuth(q.Get("jwt"), q.Get("role_id")))
+		if p := q.Get("k8s_auth_path"); p != "" {
+			ops = append(ops, WithVaultK8sAuthPath(p))
+		}
+	default:
+		return nil, fmt.Errorf("unknown vault authentication method: %q", auth)
+	}
+	for param, op := range map[string]func(uint) SecretsClientOption{
+		"auth_retries":     WithVaultAuthRetries,
+		"auth_retry_delay": WithVaultAuthRetryDelay,
+	} {
+		if v := q.Get(param); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %v: %v", param, err)
+			}
+			ops = append(ops, op(uint(n)))
+		}
+	}
+	return ops, nil
+}
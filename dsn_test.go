@@ -0,0 +1,116 @@
+package pvc
+
+import (
+	"testing"
+	"time"
+)
+
+func testParseDSN(t *testing.T, dsn string) *secretsClientConfig {
+	ops, err := parseDSN(dsn)
+	if err != nil {
+		t.Fatalf("error parsing %v: %v", dsn, err)
+	}
+	config := &secretsClientConfig{}
+	for _, op := range ops {
+		op(config)
+	}
+	return config
+}
+
+func TestParseDSNVault(t *testing.T) {
+	config := testParseDSN(t, "vault://vault.example.com:8200/secret/myapp?auth=approle&role_id=abcd&auth_retries=3&timeout=5s")
+	if len(config.enabledBackends) != 1 || config.enabledBackends[0] != vaultBackendName {
+		t.Fatalf("bad enabled backends: %v", config.enabledBackends)
+	}
+	vb := config.vaultBackend
+	if vb.host != "https://vault.example.com:8200" {
+		t.Fatalf("bad host: %v", vb.host)
+	}
+	if vb.authentication != AppRole || vb.roleid != "abcd" || vb.authRetries != 3 {
+		t.Fatalf("bad auth config: %+v", vb)
+	}
+	if config.mapping != "secret/myapp/{{ .ID }}" {
+		t.Fatalf("bad mapping: %v", config.mapping)
+	}
+	if config.timeout != 5*time.Second {
+		t.Fatalf("bad timeout: %v", config.timeout)
+	}
+}
+
+func TestParseDSNVaultExplicitMapping(t *testing.T) {
+	config := testParseDSN(t, "vault://localhost:8200/secret?auth=token&token=root&tls=false&mapping=secret/dev/{{.ID}}")
+	vb := config.vaultBackend
+	if vb.host != "http://localhost:8200" {
+		t.Fatalf("bad host: %v", vb.host)
+	}
+	if vb.authentication != Token || vb.token != "root" {
+		t.Fatalf("bad auth config: %+v", vb)
+	}
+	if config.mapping != "secret/dev/{{.ID}}" {
+		t.Fatalf("bad mapping: %v", config.mapping)
+	}
+}
+
+func TestParseDSNJSONFile(t *testing.T) {
+	config := testParseDSN(t, "jsonfile:///etc/secrets.json?cache=1m")
+	if len(config.enabledBackends) != 1 || config.enabledBackends[0] != jsonFileBackendName {
+		t.Fatalf("bad enabled backends: %v", config.enabledBackends)
+	}
+	if config.jsonFileBackend.fileLocation != "/etc/secrets.json" {
+		t.Fatalf("bad file location: %v", config.jsonFileBackend.fileLocation)
+	}
+	if config.cacheTTL != time.Minute {
+		t.Fatalf("bad cache TTL: %v", config.cacheTTL)
+	}
+}
+
+func TestParseDSNEnv(t *testing.T) {
+	config := testParseDSN(t, "env://?mapping=MYAPP_{{ .ID }}")
+	if len(config.enabledBackends) != 1 || config.enabledBackends[0] != envVarBackendName {
+		t.Fatalf("bad enabled backends: %v", config.enabledBackends)
+	}
+	if config.mapping != "MYAPP_{{ .ID }}" {
+		t.Fatalf("bad mapping: %v", config.mapping)
+	}
+}
+
+func TestParseDSNInvalid(t *testing.T) {
+	for _, dsn := range []string{
+		"vault:///secret?auth=none",                  // missing host
+		"vault://localhost:8200?auth=token",          // missing token
+		"vault://localhost:8200?auth=appid&app_id=x", // missing user id
+		"vault://localhost:8200?auth=k8s&jwt=x",      // missing role
+		"vault://localhost:8200?auth=bogus",
+		"vault://localhost:8200?auth=none&auth_retries=-1",
+		"jsonfile://",
+		"jsonfile://example/secrets.json", // host instead of path
+		"env://?timeout=forever",
+		"dotenv:///app/.env",
+		"ftp://foo",
+	} {
+		if _, err := parseDSN(dsn); err == nil {
+			t.Fatalf("should have failed: %v", dsn)
+		}
+	}
+}
+
+func TestNewSecretsClientFromDSN(t *testing.T) {
+	sc, err := NewSecretsClientFromDSN("jsonfile:example/secrets.json")
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	s, err := sc.Get("foo")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "bar" {
+		t.Fatalf("bad value: %v (expected bar)", string(s))
+	}
+	sc, err = NewSecretsClientFromDSN("env://?mapping=NEWCLIENT_{{ .ID }}")
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, ok := sc.backend.(*envVarBackendGetter); !ok {
+		t.Fatalf("wrong backend type: %T", sc.backend)
+	}
+}
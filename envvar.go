@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 // Default mapping for this backend
@@ -20,7 +21,7 @@ func newEnvVarBackendGetter(eb *envVarBackend) (*envVarBackendGetter, error) {
 	if eb.mapping == "" {
 		eb.mapping = DefaultEnvVarMapping
 	}
-	sm, err := newSecretMapper(eb.mapping)
+	sm, err := newSecretMapper(eb.mapping, eb.mappingRules...)
 	if err != nil {
 		return nil, fmt.Errorf("error with mapping: %v", err)
 	}
@@ -30,8 +31,13 @@ func newEnvVarBackendGetter(eb *envVarBackend) (*envVarBackendGetter, error) {
 	}, nil
 }
 
-// sanitizeName replaces any illegal characters with underscores
-func (ebg *envVarBackendGetter) sanitizeName(name string) string {
+// normalize uppercases the mapped name and replaces any illegal characters with underscores
+func (ebg *envVarBackendGetter) normalize(name string) string {
+	return envVarName(name)
+}
+
+// envVarName uppercases name and replaces any characters not allowed in an environment variable name with underscores
+func envVarName(name string) string {
 	name = strings.ToUpper(name)
 	f := func(r rune) rune {
 		i := int(r)
@@ -46,15 +52,132 @@ func (ebg *envVarBackendGetter) sanitizeName(name string) string {
 	return strings.Map(f, name)
 }
 
-func (ebg *envVarBackendGetter) Get(id string) ([]byte, error) {
+// lookup returns the value of the variable name from the configured env map, or the process environment if there isn't one
+func (ebg *envVarBackendGetter) lookup(name string) (string, bool) {
+	var v string
+	var ok bool
+	if ebg.config.env != nil {
+		v, ok = ebg.config.env[name]
+	} else {
+		v, ok = os.LookupEnv(name)
+	}
+	if ok || !ebg.config.caseInsensitive {
+		return v, ok
+	}
+	v, ok = foldKeys(ebg.environ(), strings.ToUpper)[strings.ToUpper(name)]
+	return v, ok
+}
+
+// environ returns all variables from the configured env map or the process environment
+func (ebg *envVarBackendGetter) environ() map[string]string {
+	if ebg.config.env != nil {
+		return ebg.config.env
+	}
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// GetAll returns every variable whose name starts with the configured prefix, keyed by the name with the prefix
+// stripped. ErrNotSupported is returned if there is no prefix.
+func (ebg *envVarBackendGetter) GetAll() (map[string][]byte, error) {
+	if ebg.config.prefix == "" {
+		return nil, ErrNotSupported
+	}
+	all := map[string][]byte{}
+	for name, v := range ebg.environ() {
+		if id := strings.TrimPrefix(name, ebg.config.prefix); id != name && id != "" {
+			dv, err := ebg.value(name, v)
+			if err != nil {
+				return nil, err
+			}
+			all[id] = dv
+		}
+	}
+	return all, nil
+}
+
+// GetGroup returns every variable whose name starts with the mapped prefix, keyed by the rest of the name
+func (ebg *envVarBackendGetter) GetGroup(prefix string) (map[string][]byte, error) {
+	vprefix, err := ebg.locate(prefix)
+	if err != nil {
+		return nil, err
+	}
+	group := map[string][]byte{}
+	for name, v := range ebg.environ() {
+		n := name
+		if ebg.config.caseInsensitive {
+			n = strings.ToUpper(name)
+		}
+		if strings.HasPrefix(n, vprefix) && len(n) > len(vprefix) {
+			dv, err := ebg.value(name, v)
+			if err != nil {
+				return nil, err
+			}
+			group[name[len(vprefix):]] = dv
+		}
+	}
+	return group, nil
+}
+
+// locate returns the name of the environment variable holding id
+func (ebg *envVarBackendGetter) locate(id string) (string, error) {
 	vname, err := ebg.mapper.MapSecret(id)
 	if err != nil {
-		return nil, fmt.Errorf("error mapping id to var name: %v", err)
+		return "", fmt.Errorf("error mapping id to var name: %v", err)
+	}
+	return ebg.normalize(vname), nil
+}
+
+// Stat returns the length of the variable holding id. Variables have no modification time.
+func (ebg *envVarBackendGetter) Stat(id string) (SecretInfo, error) {
+	return statValue(ebg.Get, id, time.Time{})
+}
+
+func (ebg *envVarBackendGetter) Get(id string) ([]byte, error) {
+	vname, err := ebg.locate(id)
+	if err != nil {
+		return nil, err
 	}
-	vname = ebg.sanitizeName(vname)
-	secret, exists := os.LookupEnv(vname)
+	secret, exists := ebg.lookup(vname)
 	if !exists {
-		return nil, fmt.Errorf("secret not found: %v", vname)
+		return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, vname)
+	}
+	return ebg.value(vname, secret)
+}
+
+// value returns the value of the variable name, which is raw, decrypted and expanded as configured
+func (ebg *envVarBackendGetter) value(name, raw string) ([]byte, error) {
+	v, err := ebg.decrypt(name, raw)
+	if err != nil || !ebg.config.expand {
+		return v, err
+	}
+	missing := []string{}
+	expanded := os.Expand(string(v), func(ref string) string {
+		r, ok := ebg.lookup(ref)
+		if !ok {
+			missing = append(missing, ref)
+		}
+		return r
+	})
+	if ebg.config.expandStrict && len(missing) > 0 {
+		return nil, fmt.Errorf("%v references unset variables: %v", name, strings.Join(missing, ", "))
+	}
+	return []byte(expanded), nil
+}
+
+// decrypt returns the value of the variable name, decrypted if a decryptor is configured
+func (ebg *envVarBackendGetter) decrypt(name, value string) ([]byte, error) {
+	if ebg.config.decryptor == nil {
+		return []byte(value), nil
+	}
+	v, err := ebg.config.decryptor([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting %v: %v", name, err)
 	}
-	return []byte(secret), nil
+	return v, nil
 }
@@ -0,0 +1,37 @@
+//go:build go1.26
+
+package pvc
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// HPKEDecryptor returns a decryptor for WithEnvVarDecryptor that opens base64-encoded values encrypted to the X25519
+// public key of privateKey (32 bytes) with HPKE (RFC 9180) in base mode, using DHKEM(X25519, HKDF-SHA256),
+// HKDF-SHA256 and ChaCha20-Poly1305 with empty info, as produced by eg hpke.Seal in Go. This is not libsodium's
+// crypto_box_seal, so values sealed with it can't be decrypted. Tampered values fail to decrypt. It requires Go 1.26.
+func HPKEDecryptor(privateKey []byte) (func([]byte) ([]byte, error), error) {
+	k, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 private key: %v", err)
+	}
+	hk, err := hpke.NewDHKEMPrivateKey(k)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 private key: %v", err)
+	}
+	return func(v []byte) ([]byte, error) {
+		ct, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(v)))
+		if err != nil {
+			return nil, fmt.Errorf("value is not base64-encoded: %v", err)
+		}
+		pt, err := hpke.Open(hk, hpke.HKDFSHA256(), hpke.ChaCha20Poly1305(), nil, ct)
+		if err != nil {
+			return nil, fmt.Errorf("error opening HPKE value: %v", err)
+		}
+		return pt, nil
+	}, nil
+}
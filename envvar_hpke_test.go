@@ -0,0 +1,53 @@
+//go:build go1.26
+
+package pvc
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestEnvVarHPKEDecryptor(t *testing.T) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	pk, err := hpke.NewDHKEMPublicKey(key.PublicKey())
+	if err != nil {
+		t.Fatalf("error getting public key: %v", err)
+	}
+	ct, err := hpke.Seal(pk, hpke.HKDFSHA256(), hpke.ChaCha20Poly1305(), nil, []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("error sealing value: %v", err)
+	}
+	tampered := append([]byte(nil), ct...)
+	tampered[len(tampered)-1] ^= 1
+	decrypt, err := HPKEDecryptor(key.Bytes())
+	if err != nil {
+		t.Fatalf("error getting decryptor: %v", err)
+	}
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvVarDecryptor(decrypt), WithEnvMap(map[string]string{
+		"SECRET_DB_PASSWORD": base64.StdEncoding.EncodeToString(ct),
+		"SECRET_TAMPERED":    base64.StdEncoding.EncodeToString(tampered),
+	}))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	v, err := sc.Get("db_password")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(v) != "hunter2" {
+		t.Fatalf("bad value: %v", string(v))
+	}
+	if _, err := sc.Get("tampered"); err == nil || !strings.Contains(err.Error(), "error decrypting SECRET_TAMPERED") {
+		t.Fatalf("tampered value should fail to decrypt: %v", err)
+	}
+	if _, err := HPKEDecryptor([]byte("short")); err == nil {
+		t.Fatalf("should have failed with an invalid key")
+	}
+}
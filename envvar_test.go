@@ -2,6 +2,7 @@ package pvc
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -71,3 +72,213 @@ func TestEnvVarBackendGetterGetFilteredName(t *testing.T) {
 		t.Fatalf("bad value: %v (expected %v)", string(s), value)
 	}
 }
+
+func TestEnvVarBackendGetterGetEnvMap(t *testing.T) {
+	eb := &envVarBackend{
+		mapping: "{{ .ID }}",
+		env: map[string]string{
+			"MY_SECRET": "frommap",
+		},
+	}
+
+	// must not be consulted when a map is present
+	os.Setenv("MY_SECRET", "fromenv")
+	defer os.Unsetenv("MY_SECRET")
+	os.Setenv("OTHER_SECRET", "fromenv")
+	defer os.Unsetenv("OTHER_SECRET")
+
+	evb, err := newEnvVarBackendGetter(eb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+
+	s, err := evb.Get("MY_SECRET")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "frommap" {
+		t.Fatalf("bad value: %v (expected frommap)", string(s))
+	}
+	_, err = evb.Get("OTHER_SECRET")
+	if err == nil {
+		t.Fatalf("should have failed for variable missing from map")
+	}
+}
+
+func TestEnvVarBackendGetterCaseInsensitive(t *testing.T) {
+	eb := &envVarBackend{
+		mapping: "{{ .ID }}",
+		env: map[string]string{
+			"db_password": "hunter2",
+			"Api_Key":     "mixed",
+			"api_key":     "lower",
+		},
+		caseInsensitive: true,
+	}
+	evb, err := newEnvVarBackendGetter(eb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	s, err := evb.Get("db_password")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "hunter2" {
+		t.Fatalf("bad value: %v (expected hunter2)", string(s))
+	}
+	// collision: "Api_Key" sorts before "api_key"
+	s, err = evb.Get("api_key")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "mixed" {
+		t.Fatalf("bad value: %v (expected mixed)", string(s))
+	}
+}
+
+func TestEnvVarBackendGetterCaseInsensitiveProcessEnv(t *testing.T) {
+	os.Setenv("pvc_test_lower", "foo")
+	defer os.Unsetenv("pvc_test_lower")
+	eb := &envVarBackend{
+		mapping:         "{{ .ID }}",
+		caseInsensitive: true,
+	}
+	evb, err := newEnvVarBackendGetter(eb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	s, err := evb.Get("pvc_test_lower")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "foo" {
+		t.Fatalf("bad value: %v (expected foo)", string(s))
+	}
+	eb.caseInsensitive = false
+	if _, err := evb.Get("pvc_test_lower"); err == nil {
+		t.Fatalf("should have failed without case-insensitive keys")
+	}
+}
+
+func TestEnvVarBackendGetterNormalize(t *testing.T) {
+	ebg, err := newEnvVarBackendGetter(&envVarBackend{mapping: "{{ .ID }}"})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	name, err := ebg.locate("//app//db-password.v2")
+	if err != nil {
+		t.Fatalf("locate failed: %v", err)
+	}
+	if name != "__APP__DB_PASSWORD_V2" {
+		t.Fatalf("bad name: %v", name)
+	}
+}
+
+func TestEnvVarBackendGetterGetAll(t *testing.T) {
+	t.Setenv("PVCTEST_SECRET_DB_PASSWORD", "hunter2")
+	t.Setenv("PVCTEST_SECRET_API_KEY", "k3y")
+	t.Setenv("PVCTEST_SECRET_", "no id")
+	t.Setenv("PVCTEST_OTHER", "unrelated")
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvVarPrefix("PVCTEST_SECRET_"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	all, err := sc.GetAll()
+	if err != nil {
+		t.Fatalf("get all failed: %v", err)
+	}
+	if len(all) != 2 || string(all["DB_PASSWORD"]) != "hunter2" || string(all["API_KEY"]) != "k3y" {
+		t.Fatalf("bad secrets: %v", all)
+	}
+	ids, err := sc.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "API_KEY" || ids[1] != "DB_PASSWORD" {
+		t.Fatalf("bad IDs: %v", ids)
+	}
+}
+
+func TestEnvVarBackendGetterGetAllNoPrefix(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.GetAll(); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+func TestEnvVarBackendGetterGetGroup(t *testing.T) {
+	t.Setenv("SECRET_PVCTEST_DB_HOST", "db.example.com")
+	t.Setenv("SECRET_PVCTEST_DB_PORT", "5432")
+	t.Setenv("SECRET_PVCTEST_DB_USER", "app")
+	t.Setenv("SECRET_PVCTEST_DB_", "no suffix")
+	t.Setenv("SECRET_PVCTEST_API_KEY", "unrelated")
+	sc, err := NewSecretsClient(WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	group, err := sc.GetGroup("pvctest_db_")
+	if err != nil {
+		t.Fatalf("get group failed: %v", err)
+	}
+	if len(group) != 3 || string(group["HOST"]) != "db.example.com" || string(group["PORT"]) != "5432" || string(group["USER"]) != "app" {
+		t.Fatalf("bad group: %v", group)
+	}
+	group, err = sc.GetGroup("pvctest_nothing_")
+	if err != nil {
+		t.Fatalf("get empty group failed: %v", err)
+	}
+	if len(group) != 0 {
+		t.Fatalf("group should be empty: %v", group)
+	}
+}
+
+func TestGetGroupNotSupported(t *testing.T) {
+	sc, err := NewSecretsClient(WithNoopBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.GetGroup("DB_"); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+func TestEnvVarExpansion(t *testing.T) {
+	env := map[string]string{
+		"DB_HOST":        "db.internal",
+		"SECRET_DSN":     "postgres://${DB_HOST}:5432/$DB_NAME",
+		"SECRET_LITERAL": "no references",
+	}
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(env), WithEnvVarExpansion())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	v, err := sc.Get("dsn")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(v) != "postgres://db.internal:5432/" {
+		t.Fatalf("bad value: %v", string(v))
+	}
+	sc, err = NewSecretsClient(WithEnvVarBackend(), WithEnvMap(env), WithEnvVarExpansionStrict())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	_, err = sc.Get("dsn")
+	if err == nil || !strings.Contains(err.Error(), "unset variables: DB_NAME") {
+		t.Fatalf("should have failed for the unset variable: %v", err)
+	}
+	env["DB_NAME"] = "app"
+	v, err = sc.Get("dsn")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(v) != "postgres://db.internal:5432/app" {
+		t.Fatalf("bad value: %v", string(v))
+	}
+	if v, err := sc.Get("literal"); err != nil || string(v) != "no references" {
+		t.Fatalf("bad value: %q: %v", v, err)
+	}
+}
@@ -0,0 +1,32 @@
+package pvc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+)
+
+// ExecWithSecrets adds the secrets in idToEnv (secret ID to environment variable name) to the environment of cmd,
+// before the caller runs it. If cmd.Env is nil, the current process environment is copied first so that it is still
+// inherited. Either every secret is added or, on error, cmd is left unchanged.
+func (sc *SecretsClient) ExecWithSecrets(cmd *exec.Cmd, idToEnv map[string]string) error {
+	ids := make([]string, 0, len(idToEnv))
+	for id := range idToEnv {
+		ids = append(ids, id)
+	}
+	values, err := sc.GetBatch(ids)
+	if err != nil {
+		return fmt.Errorf("error fetching secrets for command: %w", err)
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		env = append(env, idToEnv[id]+"="+string(values[id]))
+	}
+	cmd.Env = env
+	return nil
+}
@@ -0,0 +1,64 @@
+package pvc
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestExecWithSecrets(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{
+		"SECRET_DB_PASSWORD": "hunter2",
+		"SECRET_API_KEY":     "k3y",
+	}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	cmd := exec.Command("env")
+	cmd.Env = []string{"PATH=/bin"}
+	if err := sc.ExecWithSecrets(cmd, map[string]string{"db_password": "DB_PASSWORD", "api_key": "API_KEY"}); err != nil {
+		t.Fatalf("exec with secrets failed: %v", err)
+	}
+	expected := []string{"PATH=/bin", "API_KEY=k3y", "DB_PASSWORD=hunter2"}
+	if len(cmd.Env) != len(expected) {
+		t.Fatalf("bad env: %v", cmd.Env)
+	}
+	for i := range expected {
+		if cmd.Env[i] != expected[i] {
+			t.Fatalf("bad env: %v (expected %v)", cmd.Env, expected)
+		}
+	}
+}
+
+func TestExecWithSecretsInheritsEnv(t *testing.T) {
+	t.Setenv("PVCTEST_INHERITED", "yes")
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	cmd := exec.Command("env")
+	if err := sc.ExecWithSecrets(cmd, map[string]string{"foo": "FOO"}); err != nil {
+		t.Fatalf("exec with secrets failed: %v", err)
+	}
+	found := map[string]bool{}
+	for _, kv := range cmd.Env {
+		found[kv] = true
+	}
+	if !found["PVCTEST_INHERITED=yes"] || !found["FOO=bar"] {
+		t.Fatalf("env should contain the process environment and the secret: %v", cmd.Env)
+	}
+}
+
+func TestExecWithSecretsMissing(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	cmd := exec.Command("env")
+	cmd.Env = []string{"PATH=/bin"}
+	if err := sc.ExecWithSecrets(cmd, map[string]string{"foo": "FOO", "missing": "MISSING"}); err == nil {
+		t.Fatalf("should have failed with a missing secret")
+	}
+	if len(cmd.Env) != 1 {
+		t.Fatalf("env should be unchanged: %v", cmd.Env)
+	}
+}
@@ -0,0 +1,74 @@
+package pvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExportFormat is the output format of Export
+type ExportFormat int
+
+// Export formats
+const (
+	ExportDotenv ExportFormat = iota // KEY=value lines
+	ExportJSON                       // a JSON object of secret ID to value
+)
+
+// Export gets each of ids and writes them to w in format, eg to produce an env file for an app that can't use pvc.
+// Dotenv variable names are the location of each secret in the backend (see WithMapping) uppercased, with any
+// characters not allowed in a variable name replaced by underscores, or the ID if the backend has no mapping.
+// Values that aren't plain words are double-quoted and escaped. Nothing is written if any secret can't be retrieved.
+func (sc *SecretsClient) Export(ids []string, format ExportFormat, w io.Writer) error {
+	values := make([][]byte, len(ids))
+	for i, id := range ids {
+		v, err := sc.Get(id)
+		if err != nil {
+			return fmt.Errorf("error getting %v: %w", id, err)
+		}
+		values[i] = v
+	}
+	buf := &bytes.Buffer{}
+	switch format {
+	case ExportDotenv:
+		names := map[string]string{}
+		for i, id := range ids {
+			name := sc.resolve(sc.prefix + id)
+			if name == "" {
+				name = sc.prefix + id
+			}
+			name = envVarName(name)
+			if other, ok := names[name]; ok && other != id {
+				return fmt.Errorf("secrets %v and %v would both be exported as %v", other, id, name)
+			}
+			names[name] = id
+			fmt.Fprintf(buf, "%v=%v\n", name, dotenvValue(values[i]))
+		}
+	case ExportJSON:
+		out := map[string]string{}
+		for i, id := range ids {
+			out[id] = string(values[i])
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding secrets: %v", err)
+		}
+		buf.Write(append(b, '\n'))
+	default:
+		return fmt.Errorf("unknown export format: %v", format)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// dotenvValue returns v as it should appear after the = of a dotenv line
+func dotenvValue(v []byte) string {
+	s := string(v)
+	if s != "" && !strings.ContainsAny(s, " \t\r\n\"'\\#$`=") && strconv.CanBackquote(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,59 @@
+package pvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExportDotenv(t *testing.T) {
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONReader(strings.NewReader(`{"myapp/db/password": "hunter2", "myapp/api-key": "two words\n"}`)), WithMapping("myapp/{{ .ID }}"))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := sc.Export([]string{"db/password", "api-key"}, ExportDotenv, buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	want := "MYAPP_DB_PASSWORD=hunter2\nMYAPP_API_KEY=\"two words\\n\"\n"
+	if buf.String() != want {
+		t.Fatalf("bad output: %q", buf.String())
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar", "SECRET_BAZ": "qux"}))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := sc.Export([]string{"foo", "baz"}, ExportJSON, buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	out := map[string]string{}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("output isn't JSON: %v: %v", err, buf.String())
+	}
+	if len(out) != 2 || out["foo"] != "bar" || out["baz"] != "qux" {
+		t.Fatalf("bad output: %v", out)
+	}
+}
+
+func TestExportFailureWritesNothing(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	for _, format := range []ExportFormat{ExportDotenv, ExportJSON} {
+		buf := &bytes.Buffer{}
+		err := sc.Export([]string{"foo", "missing"}, format, buf)
+		if !errors.Is(err, ErrSecretNotFound) {
+			t.Fatalf("expected ErrSecretNotFound, got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("nothing should be written on failure: %q", buf.String())
+		}
+	}
+}
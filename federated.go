@@ -0,0 +1,135 @@
+package pvc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Default mapping for this backend
+const (
+	DefaultFederatedMapping = "{{ .ID }}"
+)
+
+// federatedEntry is the value of a key in a federated config file. Exactly one field is set.
+type federatedEntry struct {
+	Vault *string `json:"vault"` // path of a Vault secret, read like the Vault backend reads one
+	Value *string `json:"value"` // the secret itself
+	Env   *string `json:"env"`   // name of an environment variable holding the secret
+}
+
+type federatedBackendGetter struct {
+	mapper  SecretMapper
+	config  *federatedBackend
+	entries map[string]federatedEntry
+	vault   *vaultBackendGetter  // nil if there are no Vault entries
+	env     *envVarBackendGetter // looks up the variables of env entries
+}
+
+func newFederatedBackendGetter(fb *federatedBackend, vb *vaultBackend, eb *envVarBackend) (*federatedBackendGetter, error) {
+	var r io.Reader
+	switch {
+	case fb.reader != nil && fb.fileLocation != "":
+		return nil, fmt.Errorf("only one of a federated config file location and reader may be set")
+	case fb.reader != nil:
+		r = fb.reader
+	default:
+		f, err := os.Open(fb.fileLocation)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	entries := map[string]federatedEntry{}
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("error decoding file (must be a JSON object of objects): %v", err)
+	}
+	var hasVault bool
+	for k, e := range entries {
+		n := 0
+		for _, f := range []*string{e.Vault, e.Value, e.Env} {
+			if f != nil {
+				n++
+			}
+		}
+		if n != 1 {
+			return nil, fmt.Errorf("entry %v must have exactly one of vault, value or env", k)
+		}
+		hasVault = hasVault || e.Vault != nil
+	}
+	if fb.mapping == "" {
+		fb.mapping = DefaultFederatedMapping
+	}
+	sm, err := newSecretMapper(fb.mapping, fb.mappingRules...)
+	if err != nil {
+		return nil, fmt.Errorf("error with mapping: %v", err)
+	}
+	if eb == nil {
+		eb = &envVarBackend{}
+	}
+	fbg := &federatedBackendGetter{
+		mapper:  sm,
+		config:  fb,
+		entries: entries,
+		env:     &envVarBackendGetter{config: eb},
+	}
+	if hasVault {
+		if vb == nil {
+			return nil, fmt.Errorf("file has Vault entries but Vault isn't configured (see WithVaultHost)")
+		}
+		vc, err := newVaultClient(vb)
+		if err != nil {
+			return nil, fmt.Errorf("error creating vault client: %v", err)
+		}
+		vbg, err := newVaultBackendGetter(vb, vc)
+		if err != nil {
+			return nil, fmt.Errorf("error getting vault backend: %v", err)
+		}
+		fbg.vault = vbg
+	}
+	return fbg, nil
+}
+
+// locate returns the key of the entry for id
+func (fbg *federatedBackendGetter) locate(id string) (string, error) {
+	key, err := fbg.mapper.MapSecret(id)
+	if err != nil {
+		return "", fmt.Errorf("error mapping id to entry key: %v", err)
+	}
+	return key, nil
+}
+
+func (fbg *federatedBackendGetter) Get(id string) ([]byte, error) {
+	return fbg.GetContext(context.Background(), id)
+}
+
+// GetContext returns the inline value of the entry for id, or reads it from the Vault path or environment
+// variable the entry refers to
+func (fbg *federatedBackendGetter) GetContext(ctx context.Context, id string) ([]byte, error) {
+	key, err := fbg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := fbg.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, key)
+	}
+	switch {
+	case e.Value != nil:
+		return []byte(*e.Value), nil
+	case e.Env != nil:
+		v, ok := fbg.env.lookup(*e.Env)
+		if !ok {
+			return nil, fmt.Errorf("%w: environment variable %v", ErrSecretNotFound, *e.Env)
+		}
+		return []byte(v), nil
+	default:
+		v, _, err := fbg.vault.getPathWithTTL(ctx, id, joinPath(*e.Vault))
+		return v, err
+	}
+}
@@ -0,0 +1,117 @@
+package pvc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testFederatedConfig = `{
+	"api-key": {"value": "abc123"},
+	"db-password": {"vault": "secret/myapp/db"},
+	"token": {"env": "MYAPP_TOKEN"},
+	"unset": {"env": "MYAPP_UNSET"}
+}`
+
+func TestFederatedBackendGet(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/myapp/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "hunter2"}}`))
+	}))
+	defer ts.Close()
+	f := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(f, []byte(testFederatedConfig), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	sc, err := NewSecretsClient(
+		WithFederatedBackend(),
+		WithFederatedFileLocation(f),
+		WithVaultHost(ts.URL),
+		WithVaultAuthentication(None),
+		WithEnvMap(map[string]string{"MYAPP_TOKEN": "from env"}),
+	)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	for id, want := range map[string]string{"api-key": "abc123", "db-password": "hunter2", "token": "from env"} {
+		v, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get %v failed: %v", id, err)
+		}
+		if string(v) != want {
+			t.Fatalf("bad value for %v: %q (expected %q)", id, v, want)
+		}
+	}
+	for _, id := range []string{"missing", "unset"} {
+		if _, err := sc.Get(id); !errors.Is(err, ErrSecretNotFound) {
+			t.Fatalf("expected ErrSecretNotFound for %v, got %v", id, err)
+		}
+	}
+}
+
+func TestFederatedBackendVaultRetries(t *testing.T) {
+	var reads int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/myapp/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if atomic.AddInt32(&reads, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "hunter2"}}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(
+		WithFederatedBackend(),
+		WithFederatedReader(strings.NewReader(testFederatedConfig)),
+		WithVaultHost(ts.URL),
+		WithVaultAuthentication(None),
+		WithVaultReadRetries(1),
+		WithVaultReadRetryDelay(time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	v, err := sc.Get("db-password")
+	if err != nil {
+		t.Fatalf("Vault entries should be read with the Vault backend's retries: %v", err)
+	}
+	if string(v) != "hunter2" {
+		t.Fatalf("bad value: %q", v)
+	}
+}
+
+func TestFederatedBackendBadConfig(t *testing.T) {
+	for _, c := range []string{
+		`{"foo": "bar"}`,
+		`{"foo": {}}`,
+		`{"foo": {"value": "bar", "env": "BAR"}}`,
+		`{"foo": {"file": "/etc/passwd"}}`,
+	} {
+		if _, err := NewSecretsClient(WithFederatedBackend(), WithFederatedReader(strings.NewReader(c))); err == nil {
+			t.Fatalf("should have failed: %v", c)
+		}
+	}
+	// Vault entries need Vault to be configured
+	if _, err := NewSecretsClient(WithFederatedBackend(), WithFederatedReader(strings.NewReader(testFederatedConfig))); err == nil {
+		t.Fatalf("should have failed without Vault configured")
+	}
+	sc, err := NewSecretsClient(WithFederatedBackend(), WithFederatedReader(strings.NewReader(`{"foo": {"value": "bar"}}`)))
+	if err != nil {
+		t.Fatalf("Vault shouldn't be needed without Vault entries: %v", err)
+	}
+	if v, err := sc.Get("foo"); err != nil || string(v) != "bar" {
+		t.Fatalf("bad value: %q: %v", v, err)
+	}
+}
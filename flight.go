@@ -0,0 +1,53 @@
+package pvc
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// flightGroup collapses concurrent fetches of the same secret into a single backend call, so that a cache miss
+// under load doesn't cause a fetch per caller. It is a minimal version of golang.org/x/sync/singleflight.
+type flightGroup struct {
+	sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a fetch in progress. Its results are set before done is closed.
+type flightCall struct {
+	done  chan struct{}
+	value []byte
+	ttl   time.Duration
+	err   error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: map[string]*flightCall{}}
+}
+
+// do calls fetch for id, unless a call for id is already in progress in which case it waits for that call's results.
+// A caller stops waiting when ctx is done, but the call it is waiting on is unaffected. As fetch runs with the
+// context of the caller that started it, waiters also get its error if that caller's context is done first.
+func (g *flightGroup) do(ctx context.Context, id string, fetch func() ([]byte, time.Duration, error)) ([]byte, time.Duration, error) {
+	g.Lock()
+	if c, ok := g.calls[id]; ok {
+		g.Unlock()
+		select {
+		case <-c.done:
+			return bytes.Clone(c.value), c.ttl, c.err
+		case <-ctx.Done():
+			return nil, 0, contextError(ctx)
+		}
+	}
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[id] = c
+	g.Unlock()
+	c.value, c.ttl, c.err = fetch()
+	g.Lock()
+	delete(g.calls, id)
+	g.Unlock()
+	close(c.done)
+	// waiters copy c.value once done is closed, so the caller gets its own copy too
+	return bytes.Clone(c.value), c.ttl, c.err
+}
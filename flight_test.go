@@ -0,0 +1,125 @@
+package pvc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedBackend blocks every Get until release is closed, counting the calls made
+type gatedBackend struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (gb *gatedBackend) Get(id string) ([]byte, error) {
+	atomic.AddInt32(&gb.calls, 1)
+	<-gb.release
+	return []byte("value of " + id), nil
+}
+
+func TestFlightGroupCollapsesConcurrentGets(t *testing.T) {
+	gb := &gatedBackend{release: make(chan struct{})}
+	sc := &SecretsClient{backend: gb, flights: newFlightGroup()}
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	values := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := sc.Get("foo")
+			errs <- err
+			values <- string(v)
+		}()
+	}
+	// let every goroutine join the in-flight call before it completes
+	for atomic.LoadInt32(&gb.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gb.release)
+	wg.Wait()
+	close(errs)
+	close(values)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	for v := range values {
+		if v != "value of foo" {
+			t.Fatalf("bad value: %v", v)
+		}
+	}
+	if calls := atomic.LoadInt32(&gb.calls); calls != 1 {
+		t.Fatalf("expected 1 backend call, got %v", calls)
+	}
+	if _, err := sc.Get("foo"); err != nil || atomic.LoadInt32(&gb.calls) != 2 {
+		t.Fatalf("a later Get should call the backend again: %v", err)
+	}
+}
+
+func TestFlightGroupWaiterContext(t *testing.T) {
+	g := newFlightGroup()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go g.do(context.Background(), "foo", func() ([]byte, time.Duration, error) {
+		close(started)
+		<-release
+		return []byte("bar"), 0, nil
+	})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := g.do(ctx, "foo", nil); err != ErrTimeout {
+		t.Fatalf("waiter should have timed out: %v", err)
+	}
+	close(release)
+}
+
+func TestFlightGroupLeaderOwnsValue(t *testing.T) {
+	g := newFlightGroup()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fetch := func() ([]byte, time.Duration, error) {
+		close(started)
+		<-release
+		return []byte("bar"), 0, nil
+	}
+	leader := make(chan struct{})
+	go func() {
+		defer close(leader)
+		v, _, _ := g.do(context.Background(), "foo", fetch)
+		// the leader's caller may do as it likes with its value
+		for i := range v {
+			v[i] = 'x'
+		}
+	}()
+	<-started
+	const n = 10
+	var wg sync.WaitGroup
+	values := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _, _ := g.do(context.Background(), "foo", nil)
+			values <- string(v)
+		}()
+	}
+	// let the waiters join the in-flight call before it completes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	<-leader
+	close(values)
+	for v := range values {
+		if v != "bar" {
+			t.Fatalf("waiter got a value changed by the leader: %q", v)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package pvc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Format enumerates the encodings GetFormatted can return secret values in
+type Format int
+
+// Supported output formats
+const (
+	Raw    Format = iota // value as stored
+	Base64               // standard base64 encoding of the value
+	Hex                  // lowercase hex encoding of the value
+)
+
+// GetFormatted returns the value of a secret encoded according to format
+func (sc *SecretsClient) GetFormatted(id string, format Format) ([]byte, error) {
+	v, err := sc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case Raw:
+		return v, nil
+	case Base64:
+		out := make([]byte, base64.StdEncoding.EncodedLen(len(v)))
+		base64.StdEncoding.Encode(out, v)
+		return out, nil
+	case Hex:
+		out := make([]byte, hex.EncodedLen(len(v)))
+		hex.Encode(out, v)
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unknown format: %v", format)
+	}
+}
+
+// GetURL returns the value of a secret parsed as an absolute URL, eg a database connection string.
+// Parse errors don't include the value, as it may contain credentials.
+func (sc *SecretsClient) GetURL(id string) (*url.URL, error) {
+	v, err := sc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(string(v))
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return nil, fmt.Errorf("secret %v is not a valid URL: %v", id, err)
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("secret %v is not a valid URL: missing scheme", id)
+	}
+	return u, nil
+}
+
+// GetBool returns the value of a secret parsed as a boolean, eg a feature flag. Surrounding whitespace is ignored and
+// true, yes, on, y, t and 1 (or false, no, off, n, f and 0) are accepted in any case.
+func (sc *SecretsClient) GetBool(id string) (bool, error) {
+	v, err := sc.Get(id)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(string(v))) {
+	case "true", "yes", "on", "y", "t", "1":
+		return true, nil
+	case "false", "no", "off", "n", "f", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("secret %v is not a valid boolean", id)
+	}
+}
+
+// GetInt returns the value of a secret parsed as a base 10 integer, ignoring surrounding whitespace.
+// Parse errors don't include the value.
+func (sc *SecretsClient) GetInt(id string) (int64, error) {
+	v, err := sc.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+	if err != nil {
+		var ne *strconv.NumError
+		if errors.As(err, &ne) {
+			err = ne.Err
+		}
+		return 0, fmt.Errorf("secret %v is not a valid integer: %v", id, err)
+	}
+	return n, nil
+}
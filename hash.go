@@ -0,0 +1,35 @@
+package pvc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+// HashSecret returns the hex-encoded SHA-256 of the value of a secret, eg to verify a rotation without exposing the value
+func (sc *SecretsClient) HashSecret(id string) (string, error) {
+	v, err := sc.Get(id)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(v)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CompareSecret reports whether the SHA-256 of the value of a secret matches expectedHash (hex-encoded)
+func (sc *SecretsClient) CompareSecret(id string, expectedHash string) (bool, error) {
+	expected, err := hex.DecodeString(expectedHash)
+	if err != nil {
+		return false, fmt.Errorf("invalid hash: %v", err)
+	}
+	if len(expected) != sha256.Size {
+		return false, fmt.Errorf("invalid hash: expected %v bytes, got %v", sha256.Size, len(expected))
+	}
+	v, err := sc.Get(id)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(v)
+	return subtle.ConstantTimeCompare(sum[:], expected) == 1, nil
+}
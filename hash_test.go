@@ -0,0 +1,55 @@
+package pvc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// sha256 of "pa55w0rd"
+const testSecretHash = "56965e2a1a995c74da500088947af11dfa27951cc350d0b97d0633075969c31b"
+
+func testHashSecretsClient(t *testing.T) *SecretsClient {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_FOO": "pa55w0rd"}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	return sc
+}
+
+func TestHashSecret(t *testing.T) {
+	sc := testHashSecretsClient(t)
+	h, err := sc.HashSecret("foo")
+	if err != nil {
+		t.Fatalf("hash failed: %v", err)
+	}
+	if h != testSecretHash {
+		t.Fatalf("bad hash: %v", h)
+	}
+	if _, err := sc.HashSecret("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestCompareSecret(t *testing.T) {
+	sc := testHashSecretsClient(t)
+	cases := map[string]bool{
+		testSecretHash:                  true,
+		strings.ToUpper(testSecretHash): true,
+		"0dbc5e3e9a3c6c6c3be7b0ba5d4d05b4c8b6d8dde2720e6523e0f5e23ff09f47": false,
+	}
+	for hash, expected := range cases {
+		match, err := sc.CompareSecret("foo", hash)
+		if err != nil {
+			t.Fatalf("compare failed: %v", err)
+		}
+		if match != expected {
+			t.Fatalf("bad result for %v: %v (expected %v)", hash, match, expected)
+		}
+	}
+	for _, hash := range []string{"not hex", "abcd"} {
+		if _, err := sc.CompareSecret("foo", hash); err == nil {
+			t.Fatalf("should have failed for invalid hash %q", hash)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// health records the outcome of the latest backend calls, for LastError, LastSuccess and Healthy
+type health struct {
+	sync.Mutex
+	now         func() time.Time
+	lastErr     error
+	lastSuccess time.Time
+	failing     bool // the latest backend call failed
+}
+
+func newHealth(now func() time.Time) *health {
+	if now == nil {
+		now = time.Now
+	}
+	return &health{now: now}
+}
+
+// record notes the outcome of a backend call. A secret not existing is a success, as the backend answered, and a
+// call given up on by the caller isn't counted at all.
+func (h *health) record(err error) {
+	if h == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	if err != nil && !errors.Is(err, ErrSecretNotFound) {
+		h.lastErr, h.failing = err, true
+		return
+	}
+	h.lastSuccess, h.failing = h.now(), false
+}
+
+// LastError returns the error of the most recent failed backend call, or nil if none has failed. It isn't cleared by
+// later successes; see Healthy. Gets served from the cache don't call the backend, so don't affect it.
+func (sc *SecretsClient) LastError() error {
+	if sc.health == nil {
+		return nil
+	}
+	sc.health.Lock()
+	defer sc.health.Unlock()
+	return sc.redactError(sc.health.lastErr)
+}
+
+// LastSuccess returns when a backend call last succeeded (including finding that a secret doesn't exist), or the
+// zero time if none has
+func (sc *SecretsClient) LastSuccess() time.Time {
+	if sc.health == nil {
+		return time.Time{}
+	}
+	sc.health.Lock()
+	defer sc.health.Unlock()
+	return sc.health.lastSuccess
+}
+
+// Healthy reports whether the most recent backend call succeeded, or none has been made yet, without calling the
+// backend, eg for a readiness probe. A client serving stale values from the cache (see WithServeStaleOnError) while the backend
+// fails is unhealthy.
+func (sc *SecretsClient) Healthy() bool {
+	if sc.health == nil {
+		return true
+	}
+	sc.health.Lock()
+	defer sc.health.Unlock()
+	return !sc.health.failing
+}
@@ -0,0 +1,70 @@
+package pvc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	clock := newFakeClock()
+	mb := NewMemoryBackend(map[string][]byte{"foo": []byte("bar")})
+	sc, err := NewSecretsClient(WithMemoryBackend(mb), withClock(clock))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	if !sc.Healthy() || sc.LastError() != nil || !sc.LastSuccess().IsZero() {
+		t.Fatalf("a new client should be healthy with no history")
+	}
+	mb.SetErrorTimes("foo", 1)
+	if _, err := sc.Get("foo"); err == nil {
+		t.Fatalf("get should have failed")
+	}
+	if sc.Healthy() || !errors.Is(sc.LastError(), ErrSimulatedFailure) || !sc.LastSuccess().IsZero() {
+		t.Fatalf("should be unhealthy after a failure: %v", sc.LastError())
+	}
+	clock.advance(time.Minute)
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if !sc.Healthy() || !sc.LastSuccess().Equal(clock.now()) {
+		t.Fatalf("should be healthy after a success: %v", sc.LastSuccess())
+	}
+	if !errors.Is(sc.LastError(), ErrSimulatedFailure) {
+		t.Fatalf("last error should be kept after a success: %v", sc.LastError())
+	}
+	// the backend answering that a secret doesn't exist isn't a failure
+	if _, err := sc.Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+	if !sc.Healthy() {
+		t.Fatalf("should be healthy after a secret isn't found")
+	}
+}
+
+func TestHealthConcurrent(t *testing.T) {
+	mb := NewMemoryBackend(map[string][]byte{"foo": []byte("bar")})
+	sc, err := NewSecretsClient(WithMemoryBackend(mb))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			sc.Get("foo")
+		}()
+		go func() {
+			defer wg.Done()
+			sc.Healthy()
+			sc.LastError()
+			sc.LastSuccess()
+		}()
+	}
+	wg.Wait()
+	if !sc.Healthy() {
+		t.Fatalf("should be healthy: %v", sc.LastError())
+	}
+}
@@ -0,0 +1,102 @@
+package pvc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultVaultHostCooldown is how long a Vault host that couldn't be connected to is skipped for (see WithVaultHosts)
+const DefaultVaultHostCooldown = 30 * time.Second
+
+// hostPool is a transport that sends each request to the next of several equivalent hosts, skipping ones that
+// recently failed. Requests for the first host are redirected; others (eg, redirects to a specific node) are left alone.
+type hostPool struct {
+	sync.Mutex
+	rt        http.RoundTripper
+	hosts     []*url.URL
+	downUntil []time.Time // when each host may be tried again after failing
+	next      int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+func newHostPool(hosts []string, rt http.RoundTripper) (*hostPool, error) {
+	hp := &hostPool{
+		rt:        rt,
+		downUntil: make([]time.Time, len(hosts)),
+		cooldown:  DefaultVaultHostCooldown,
+		now:       time.Now,
+	}
+	for _, h := range hosts {
+		u, err := url.Parse(h)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid Vault host: %q", h)
+		}
+		hp.hosts = append(hp.hosts, u)
+	}
+	return hp, nil
+}
+
+// pick returns the index of the next host to use, preferring ones that haven't recently failed.
+// If they all have, the next in turn is used anyway.
+func (hp *hostPool) pick() int {
+	hp.Lock()
+	defer hp.Unlock()
+	now := hp.now()
+	for n := 0; n < len(hp.hosts); n++ {
+		i := (hp.next + n) % len(hp.hosts)
+		if !now.Before(hp.downUntil[i]) {
+			hp.next = i + 1
+			return i
+		}
+	}
+	i := hp.next % len(hp.hosts)
+	hp.next = i + 1
+	return i
+}
+
+// markDown skips host i until the cooldown has passed
+func (hp *hostPool) markDown(i int) {
+	hp.Lock()
+	defer hp.Unlock()
+	hp.downUntil[i] = hp.now().Add(hp.cooldown)
+}
+
+func (hp *hostPool) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Scheme != hp.hosts[0].Scheme || r.URL.Host != hp.hosts[0].Host {
+		return hp.rt.RoundTrip(r)
+	}
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %v", err)
+		}
+		body = b
+	}
+	var err error
+	for attempt := 0; attempt < len(hp.hosts); attempt++ {
+		i := hp.pick()
+		hr := r.Clone(r.Context())
+		hr.URL.Scheme, hr.URL.Host, hr.Host = hp.hosts[i].Scheme, hp.hosts[i].Host, ""
+		if body != nil {
+			hr.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		var resp *http.Response
+		resp, err = hp.rt.RoundTrip(hr)
+		if err == nil {
+			return resp, nil
+		}
+		if r.Context().Err() != nil {
+			return nil, err
+		}
+		hp.markDown(i)
+	}
+	return nil, err
+}
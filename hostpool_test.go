@@ -0,0 +1,68 @@
+package pvc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingVaultServer returns a Vault server for the secret "foo", counting the requests made
+func countingVaultServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+}
+
+func TestVaultHostsRoundRobin(t *testing.T) {
+	var calls1, calls2 int32
+	ts1, ts2 := countingVaultServer(&calls1), countingVaultServer(&calls2)
+	defer ts1.Close()
+	defer ts2.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHosts([]string{ts1.URL, ts2.URL}), WithVaultAuthentication(None))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := sc.Get("foo"); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if calls1 != 2 || calls2 != 2 {
+		t.Fatalf("requests should be spread across hosts: %v, %v", calls1, calls2)
+	}
+}
+
+func TestVaultHostsFailover(t *testing.T) {
+	var calls1, calls2 int32
+	ts1, ts2 := countingVaultServer(&calls1), countingVaultServer(&calls2)
+	defer ts2.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHosts([]string{ts1.URL, ts2.URL}), WithVaultAuthentication(None))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	hp := sc.backend.(*vaultBackendGetter).vc.(*vaultClient).httpClient.Transport.(*hostPool)
+	now := time.Now()
+	hp.now = func() time.Time { return now }
+	ts1.Close()
+	for i := 0; i < 4; i++ {
+		if _, err := sc.Get("foo"); err != nil {
+			t.Fatalf("get should have failed over: %v", err)
+		}
+	}
+	if calls2 != 4 {
+		t.Fatalf("all requests should have gone to the healthy host: %v", calls2)
+	}
+	now = now.Add(DefaultVaultHostCooldown)
+	if hp.pick() != 0 {
+		t.Fatalf("failed host should be tried again after the cooldown")
+	}
+}
+
+func TestNewHostPoolInvalid(t *testing.T) {
+	if _, err := newHostPool([]string{"https://vault1:8200", "vault2"}, http.DefaultTransport); err == nil {
+		t.Fatalf("should have failed with a host without scheme")
+	}
+}
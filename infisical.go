@@ -0,0 +1,117 @@
+package pvc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults for this backend
+const (
+	DefaultInfisicalMapping = "{{ .ID }}"
+	DefaultInfisicalHost    = "https://app.infisical.com"
+)
+
+type infisicalBackendGetter struct {
+	httpClient *http.Client
+	mapper     SecretMapper
+	config     *infisicalBackend
+}
+
+func newInfisicalBackendGetter(ib *infisicalBackend) (*infisicalBackendGetter, error) {
+	if ib.token == "" && ib.serviceToken == "" {
+		return nil, fmt.Errorf("Infisical token or service token is required")
+	}
+	if ib.token != "" && ib.serviceToken != "" {
+		return nil, fmt.Errorf("only one of Infisical token and service token may be used")
+	}
+	// service tokens are scoped to a project, other tokens need it given
+	if ib.projectID == "" && ib.serviceToken == "" {
+		return nil, fmt.Errorf("Infisical project ID is required")
+	}
+	if ib.environment == "" {
+		return nil, fmt.Errorf("Infisical environment is required")
+	}
+	if ib.host == "" {
+		ib.host = DefaultInfisicalHost
+	}
+	if ib.mapping == "" {
+		ib.mapping = DefaultInfisicalMapping
+	}
+	if ib.notFound == nil {
+		ib.notFound = DefaultNotFoundDetector
+	}
+	sm, err := newSecretMapper(ib.mapping, ib.mappingRules...)
+	if err != nil {
+		return nil, fmt.Errorf("error with mapping: %v", err)
+	}
+	return &infisicalBackendGetter{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		mapper:     sm,
+		config:     ib,
+	}, nil
+}
+
+// locate returns the name of the Infisical secret holding id
+func (ibg *infisicalBackendGetter) locate(id string) (string, error) {
+	name, err := ibg.mapper.MapSecret(id)
+	if err != nil {
+		return "", fmt.Errorf("error mapping id to secret name: %v", err)
+	}
+	return name, nil
+}
+
+func (ibg *infisicalBackendGetter) Get(id string) ([]byte, error) {
+	return ibg.GetContext(context.Background(), id)
+}
+
+func (ibg *infisicalBackendGetter) GetContext(ctx context.Context, id string) ([]byte, error) {
+	name, err := ibg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{"environment": []string{ibg.config.environment}, "secretPath": []string{"/"}}
+	if ibg.config.projectID != "" {
+		q.Set("workspaceId", ibg.config.projectID)
+	}
+	u := strings.TrimSuffix(ibg.config.host, "/") + "/api/v3/secrets/raw/" + url.PathEscape(name) + "?" + q.Encode()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	token := ibg.config.token
+	if token == "" {
+		token = ibg.config.serviceToken
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := ibg.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error performing request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %v", err)
+	}
+	switch {
+	case ibg.config.notFound(resp.StatusCode, body):
+		return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, name)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status code from Infisical: %v", resp.StatusCode)
+	}
+	out := struct {
+		Secret struct {
+			SecretValue string `json:"secretValue"`
+		} `json:"secret"`
+	}{}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+	return []byte(out.Secret.SecretValue), nil
+}
@@ -0,0 +1,81 @@
+package pvc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testInfisicalServer mimics the Infisical raw secrets endpoint for a single secret in project proj123, environment prod
+func testInfisicalServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/secrets/raw/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer footoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("workspaceId") != "proj123" || q.Get("environment") != "prod" || q.Get("secretPath") != "/" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path != "/api/v3/secrets/raw/DB_PASSWORD" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Secret not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"secret": map[string]string{"secretKey": "DB_PASSWORD", "secretValue": "hunter2", "environment": "prod"},
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func testInfisicalClient(t *testing.T, host string) *SecretsClient {
+	sc, err := NewSecretsClient(
+		WithInfisicalBackend(),
+		WithInfisicalHost(host),
+		WithInfisicalToken("footoken"),
+		WithInfisicalProjectID("proj123"),
+		WithInfisicalEnvironment("prod"),
+	)
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	return sc
+}
+
+func TestNewInfisicalBackendGetterMissingConfig(t *testing.T) {
+	if _, err := newInfisicalBackendGetter(&infisicalBackend{token: "foo", environment: "prod"}); err == nil {
+		t.Fatalf("should have failed without project ID")
+	}
+	if _, err := newInfisicalBackendGetter(&infisicalBackend{serviceToken: "foo", environment: "prod"}); err != nil {
+		t.Fatalf("service token shouldn't need a project ID: %v", err)
+	}
+	if _, err := newInfisicalBackendGetter(&infisicalBackend{token: "foo", serviceToken: "bar", projectID: "proj123", environment: "prod"}); err == nil {
+		t.Fatalf("should have failed with a token and a service token")
+	}
+}
+
+func TestInfisicalBackendGetterGet(t *testing.T) {
+	ts := testInfisicalServer(t)
+	defer ts.Close()
+	s, err := testInfisicalClient(t, ts.URL).Get("DB_PASSWORD")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "hunter2" {
+		t.Fatalf("bad value: %v (expected hunter2)", string(s))
+	}
+}
+
+func TestInfisicalBackendGetterGetMissing(t *testing.T) {
+	ts := testInfisicalServer(t)
+	defer ts.Close()
+	_, err := testInfisicalClient(t, ts.URL).Get("MISSING")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
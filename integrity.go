@@ -0,0 +1,41 @@
+package pvc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// integrityCheck is the configuration of WithIntegrityCheck
+type integrityCheck struct {
+	key    []byte
+	suffix string
+}
+
+// verifyIntegrity checks value, fetched for id, against the HMAC in its sidecar
+func (sc *SecretsClient) verifyIntegrity(ctx context.Context, id string, value []byte) error {
+	if sc.integrity.suffix == "" {
+		return fmt.Errorf("%w: no sidecar suffix configured", ErrIntegrityCheckFailed)
+	}
+	sidecar, _, err := sc.getFromBackend(ctx, id+sc.integrity.suffix)
+	switch {
+	case errors.Is(err, ErrSecretNotFound):
+		return fmt.Errorf("%w: %v: HMAC not found", ErrIntegrityCheckFailed, id)
+	case err != nil:
+		return fmt.Errorf("error getting HMAC of %v: %w", id, err)
+	}
+	expected, err := hex.DecodeString(strings.TrimSpace(string(sidecar)))
+	if err != nil {
+		return fmt.Errorf("%w: %v: HMAC isn't hex-encoded", ErrIntegrityCheckFailed, id)
+	}
+	mac := hmac.New(sha256.New, sc.integrity.key)
+	mac.Write(value)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("%w: %v", ErrIntegrityCheckFailed, id)
+	}
+	return nil
+}
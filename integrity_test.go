@@ -0,0 +1,45 @@
+package pvc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func testHMAC(key, value string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestIntegrityCheck(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{
+		"SECRET_GOOD":        "hunter2",
+		"SECRET_GOOD_HMAC":   testHMAC("k3y", "hunter2") + "\n",
+		"SECRET_BAD":         "tampered",
+		"SECRET_BAD_HMAC":    testHMAC("k3y", "hunter2"),
+		"SECRET_NOHMAC":      "hunter2",
+		"SECRET_BADHEX":      "hunter2",
+		"SECRET_BADHEX_HMAC": "not hex",
+	}), WithIntegrityCheck([]byte("k3y"), "_hmac"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	v, err := sc.Get("good")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(v) != "hunter2" {
+		t.Fatalf("bad value: %v", string(v))
+	}
+	for _, id := range []string{"bad", "nohmac", "badhex"} {
+		if _, err := sc.Get(id); !errors.Is(err, ErrIntegrityCheckFailed) {
+			t.Fatalf("%v should have failed the integrity check: %v", id, err)
+		}
+	}
+	if _, err := sc.Get("missing"); !errors.Is(err, ErrSecretNotFound) || errors.Is(err, ErrIntegrityCheckFailed) {
+		t.Fatalf("a missing secret should be reported as not found: %v", err)
+	}
+}
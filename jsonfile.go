@@ -1,9 +1,18 @@
 package pvc
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Default mapping for this backend
@@ -11,45 +20,331 @@ const (
 	DefaultJSONFileMapping = "{{ .ID }}"
 )
 
+// DefaultJSONFlattenSeparator is used to join nested keys when flattening is enabled without a separator
+const DefaultJSONFlattenSeparator = "."
+
 type jsonFileBackendGetter struct {
+	mu       sync.RWMutex // guards contents and folded, and writes to the file
 	mapper   SecretMapper
 	config   *jsonFileBackend
 	contents map[string]string
+	folded   map[string]string // contents keyed by lowercased key, if keys are case-insensitive
+	document interface{}       // the whole decoded file, if IDs are JSON Pointers
+	modTime  time.Time         // when the file was last modified, or contents set
 }
 
 func newjsonFileBackendGetter(jb *jsonFileBackend) (*jsonFileBackendGetter, error) {
-	f, err := os.Open(jb.fileLocation)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
+	var r io.Reader
+	var modTime time.Time
+	switch {
+	case jb.reader != nil && jb.fileLocation != "":
+		return nil, fmt.Errorf("only one of a JSON file location and reader may be set")
+	case jb.reader != nil && (jb.sops || jb.persist):
+		return nil, fmt.Errorf("a JSON reader can't be decrypted with SOPS or persisted")
+	case jb.reader != nil:
+		r = jb.reader
+	case jb.sops:
+		if jb.persist {
+			return nil, fmt.Errorf("SOPS-encrypted files can't be persisted")
+		}
+		b, err := decryptSOPS(jb.fileLocation, jb.sopsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if fi, err := os.Stat(jb.fileLocation); err == nil {
+			modTime = fi.ModTime()
+		}
+		r = bytes.NewReader(b)
+	default:
+		f, err := os.Open(jb.fileLocation)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file: %v", err)
+		}
+		defer f.Close()
+		if fi, err := f.Stat(); err == nil {
+			modTime = fi.ModTime()
+		}
+		r = f
 	}
-	defer f.Close()
+	var err error
 	c := map[string]string{}
-	d := json.NewDecoder(f)
-	err = d.Decode(&c)
-	if err != nil {
-		return nil, fmt.Errorf("error decoding file (must be a JSON object): %v", err)
+	var doc interface{}
+	d := json.NewDecoder(r)
+	d.UseNumber() // so that numbers are returned exactly as written, rather than rounded to a float64
+	switch {
+	case jb.flatten && jb.pointer:
+		return nil, fmt.Errorf("JSON flattening and pointers can't be combined")
+	case jb.pointer:
+		err = d.Decode(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding file: %v", err)
+		}
+	case jb.flatten:
+		if jb.flattenSeparator == "" {
+			jb.flattenSeparator = DefaultJSONFlattenSeparator
+		}
+		obj := map[string]interface{}{}
+		err = d.Decode(&obj)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding file (must be a JSON object): %v", err)
+		}
+		err = flattenJSON(c, "", jb.flattenSeparator, obj)
+		if err != nil {
+			return nil, fmt.Errorf("error flattening file: %v", err)
+		}
+	default:
+		obj := map[string]interface{}{}
+		err = d.Decode(&obj)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding file (must be a JSON object): %v", err)
+		}
+		for k, v := range obj {
+			switch v := v.(type) {
+			case string:
+				c[k] = v
+			case json.Number:
+				c[k] = v.String()
+			case nil:
+				c[k] = ""
+			default:
+				return nil, fmt.Errorf("error decoding file: value of %v must be a string or number", k)
+			}
+		}
+	}
+	if jb.reader != nil {
+		// drain the rest, so that anything writing to a pipe isn't blocked
+		if _, err := io.Copy(ioutil.Discard, jb.reader); err != nil {
+			return nil, fmt.Errorf("error reading JSON: %v", err)
+		}
 	}
 	if jb.mapping == "" {
 		jb.mapping = DefaultJSONFileMapping
 	}
-	sm, err := newSecretMapper(jb.mapping)
+	sm, err := newSecretMapper(jb.mapping, jb.mappingRules...)
 	if err != nil {
 		return nil, fmt.Errorf("error with mapping: %v", err)
 	}
-	return &jsonFileBackendGetter{
+	jbg := &jsonFileBackendGetter{
 		mapper:   sm,
 		config:   jb,
 		contents: c,
-	}, nil
+		document: doc,
+		modTime:  modTime,
+	}
+	if jb.caseInsensitive {
+		jbg.folded = foldKeys(c, strings.ToLower)
+	}
+	return jbg, nil
 }
 
-func (jbg *jsonFileBackendGetter) Get(id string) ([]byte, error) {
+// decryptSOPS returns the decrypted contents of the SOPS-encrypted JSON file at path, using the age key file keyFile if set
+func decryptSOPS(path, keyFile string) ([]byte, error) {
+	cmd := exec.Command("sops", "--decrypt", "--input-type", "json", "--output-type", "json", path)
+	if keyFile != "" {
+		cmd.Env = append(os.Environ(), "SOPS_AGE_KEY_FILE="+keyFile)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting SOPS file %v: %v: %v", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// locate returns the object key holding id
+func (jbg *jsonFileBackendGetter) locate(id string) (string, error) {
 	key, err := jbg.mapper.MapSecret(id)
 	if err != nil {
-		return nil, fmt.Errorf("error mapping id to object key: %v", err)
+		return "", fmt.Errorf("error mapping id to object key: %v", err)
 	}
+	return jbg.normalize(key), nil
+}
+
+// normalize returns the mapped key unchanged, as any string is a legal JSON object key
+func (jbg *jsonFileBackendGetter) normalize(key string) string {
+	return key
+}
+
+func (jbg *jsonFileBackendGetter) Get(id string) ([]byte, error) {
+	v, err := jbg.get(id)
+	if err != nil || !jbg.config.autoUnescape {
+		return v, err
+	}
+	return unescapeJSONString(v), nil
+}
+
+func (jbg *jsonFileBackendGetter) get(id string) ([]byte, error) {
+	key, err := jbg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	if jbg.config.pointer {
+		return resolveJSONPointer(jbg.document, key)
+	}
+	jbg.mu.RLock()
+	defer jbg.mu.RUnlock()
 	if val, ok := jbg.contents[key]; ok {
 		return []byte(val), nil
 	}
-	return nil, fmt.Errorf("secret not found: %v", key)
+	if val, ok := jbg.folded[strings.ToLower(key)]; ok {
+		return []byte(val), nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, key)
+}
+
+// unescapeJSONString returns the decoded string if v is a JSON string literal containing escape sequences, otherwise v
+func unescapeJSONString(v []byte) []byte {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' || !bytes.ContainsRune(v, '\\') {
+		return v
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return v
+	}
+	return []byte(s)
+}
+
+// Set stores value as the secret id, writing the file if persistence is enabled. Flattened files and
+// files addressed by JSON Pointer can't be written, as their structure would be lost.
+func (jbg *jsonFileBackendGetter) Set(id string, value []byte) error {
+	if jbg.config.flatten || jbg.config.pointer {
+		return ErrNotSupported
+	}
+	key, err := jbg.locate(id)
+	if err != nil {
+		return err
+	}
+	jbg.mu.Lock()
+	defer jbg.mu.Unlock()
+	c := make(map[string]string, len(jbg.contents)+1)
+	for k, v := range jbg.contents {
+		c[k] = v
+	}
+	c[key] = string(value)
+	if jbg.config.persist {
+		if err := writeFileAtomic(jbg.config.fileLocation, c); err != nil {
+			return fmt.Errorf("error writing file: %v", err)
+		}
+	}
+	jbg.contents = c
+	jbg.modTime = time.Now()
+	if jbg.config.caseInsensitive {
+		jbg.folded = foldKeys(c, strings.ToLower)
+	}
+	return nil
+}
+
+// Stat returns the length of the value of id, and when the file was last modified (or a value last set)
+func (jbg *jsonFileBackendGetter) Stat(id string) (SecretInfo, error) {
+	jbg.mu.RLock()
+	modTime := jbg.modTime
+	jbg.mu.RUnlock()
+	return statValue(jbg.Get, id, modTime)
+}
+
+// writeFileAtomic replaces the file at path with contents encoded as a JSON object, by writing a temporary file
+// in the same directory and renaming it over path, so readers never see a partially written file
+func writeFileAtomic(path string, contents map[string]string) error {
+	b, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding contents: %v", err)
+	}
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// resolveJSONPointer returns the value in doc that the RFC 6901 JSON Pointer ptr refers to. String values are returned
+// as-is, others in their JSON representation. ErrSecretNotFound is returned if ptr doesn't refer to a value.
+func resolveJSONPointer(doc interface{}, ptr string) ([]byte, error) {
+	if ptr != "" && !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer (must be empty or start with /): %v", ptr)
+	}
+	v := doc
+	if ptr != "" {
+		for _, token := range strings.Split(ptr[1:], "/") {
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			switch cv := v.(type) {
+			case map[string]interface{}:
+				var ok bool
+				v, ok = cv[token]
+				if !ok {
+					return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, ptr)
+				}
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(cv) || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+					return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, ptr)
+				}
+				v = cv[i]
+			default:
+				return nil, fmt.Errorf("%w: %v", ErrSecretNotFound, ptr)
+			}
+		}
+	}
+	if s, ok := v.(string); ok {
+		return []byte(s), nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding value at %v: %v", ptr, err)
+	}
+	return b, nil
+}
+
+// flattenJSON walks the decoded JSON value v and stores every leaf in out, keyed by the path to it joined by sep.
+// String leaves are stored as-is, other scalars are stored in their JSON representation.
+func flattenJSON(out map[string]string, prefix, sep string, v interface{}) error {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + sep + k
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, cv := range v {
+			if err := flattenJSON(out, join(k), sep, cv); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, cv := range v {
+			if err := flattenJSON(out, join(strconv.Itoa(i)), sep, cv); err != nil {
+				return err
+			}
+		}
+	case string:
+		out[prefix] = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("error encoding value for key %v: %v", prefix, err)
+		}
+		out[prefix] = string(b)
+	}
+	return nil
 }
@@ -1,6 +1,17 @@
 package pvc
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
 
 func TestNewjsonFileBackendGetter(t *testing.T) {
 	jb := &jsonFileBackend{
@@ -30,3 +41,455 @@ func TestJSONFileBackendGetterGet(t *testing.T) {
 		t.Fatalf("bad value: %v (expected %v)", string(s), value)
 	}
 }
+
+func TestJSONFileBackendGetterFlatten(t *testing.T) {
+	jb := &jsonFileBackend{
+		fileLocation: "testing/nested_secrets.json",
+		flatten:      true,
+	}
+	jbg, err := newjsonFileBackendGetter(jb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	cases := map[string]string{
+		"foo":                "bar",
+		"db.password":        "x",
+		"db.port":            "5432",
+		"db.hosts.0":         "db1",
+		"db.hosts.1":         "db2",
+		"db.replicas.0.user": "ro",
+	}
+	for sid, value := range cases {
+		s, err := jbg.Get(sid)
+		if err != nil {
+			t.Fatalf("get failed for %v: %v", sid, err)
+		}
+		if string(s) != value {
+			t.Fatalf("bad value for %v: %v (expected %v)", sid, string(s), value)
+		}
+	}
+	if _, err := jbg.Get("db"); err == nil {
+		t.Fatalf("should have failed for non-leaf key")
+	}
+}
+
+func TestJSONFileBackendGetterFlattenSeparator(t *testing.T) {
+	jb := &jsonFileBackend{
+		fileLocation:     "testing/nested_secrets.json",
+		flatten:          true,
+		flattenSeparator: "/",
+	}
+	jbg, err := newjsonFileBackendGetter(jb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	s, err := jbg.Get("db/hosts/1")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "db2" {
+		t.Fatalf("bad value: %v (expected db2)", string(s))
+	}
+}
+
+func TestNewjsonFileBackendGetterNestedWithoutFlatten(t *testing.T) {
+	jb := &jsonFileBackend{
+		fileLocation: "testing/nested_secrets.json",
+	}
+	_, err := newjsonFileBackendGetter(jb)
+	if err == nil {
+		t.Fatalf("should have failed decoding nested file")
+	}
+}
+
+func TestJSONFileBackendGetterCaseInsensitive(t *testing.T) {
+	jb := &jsonFileBackend{
+		fileLocation:    "testing/mixed_case_secrets.json",
+		caseInsensitive: true,
+	}
+	jbg, err := newjsonFileBackendGetter(jb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	cases := map[string]string{
+		"dbpassword": "hunter2",
+		"DBPASSWORD": "hunter2",
+		"apiKey":     "lower", // exact match
+		"APIKEY":     "upper", // exact match
+		"ApiKey":     "upper", // collision: "APIKEY" sorts first
+	}
+	for sid, value := range cases {
+		s, err := jbg.Get(sid)
+		if err != nil {
+			t.Fatalf("get failed for %v: %v", sid, err)
+		}
+		if string(s) != value {
+			t.Fatalf("bad value for %v: %v (expected %v)", sid, string(s), value)
+		}
+	}
+}
+
+func TestJSONFileBackendGetterCaseSensitive(t *testing.T) {
+	jb := &jsonFileBackend{
+		fileLocation: "testing/mixed_case_secrets.json",
+	}
+	jbg, err := newjsonFileBackendGetter(jb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if _, err := jbg.Get("dbpassword"); err == nil {
+		t.Fatalf("should have failed")
+	}
+}
+
+func TestJSONFileBackendGetterNormalize(t *testing.T) {
+	jbg, err := newjsonFileBackendGetter(&jsonFileBackend{fileLocation: "example/secrets.json", mapping: "{{ .ID }}"})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	key, err := jbg.locate("//app//db-password.v2")
+	if err != nil {
+		t.Fatalf("locate failed: %v", err)
+	}
+	if key != "//app//db-password.v2" {
+		t.Fatalf("key should be unchanged: %v", key)
+	}
+}
+
+func TestJSONFileBackendGetterPointer(t *testing.T) {
+	jb := &jsonFileBackend{
+		fileLocation: "testing/nested_secrets.json",
+		pointer:      true,
+	}
+	jbg, err := newjsonFileBackendGetter(jb)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	cases := map[string]string{
+		"/foo":                "bar",
+		"/db/password":        "x",
+		"/db/port":            "5432",
+		"/db/hosts/0":         "db1",
+		"/db/hosts/1":         "db2",
+		"/db/replicas/0/user": "ro",
+		"/db/hosts":           `["db1","db2"]`,
+		"/db/replicas/0":      `{"user":"ro"}`,
+	}
+	for sid, value := range cases {
+		s, err := jbg.Get(sid)
+		if err != nil {
+			t.Fatalf("get failed for %v: %v", sid, err)
+		}
+		if string(s) != value {
+			t.Fatalf("bad value for %v: %v (expected %v)", sid, string(s), value)
+		}
+	}
+	for _, sid := range []string{"/missing", "/db/hosts/2", "/db/hosts/-", "/db/hosts/01", "/db/hosts/x", "/foo/bar", "/db/password/0"} {
+		if _, err := jbg.Get(sid); !errors.Is(err, ErrSecretNotFound) {
+			t.Fatalf("should have returned ErrSecretNotFound for %v: %v", sid, err)
+		}
+	}
+	if _, err := jbg.Get("db/password"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have failed for an invalid pointer: %v", err)
+	}
+}
+
+func TestResolveJSONPointerEscapes(t *testing.T) {
+	doc := map[string]interface{}{"a/b": map[string]interface{}{"m~n": "x"}}
+	s, err := resolveJSONPointer(doc, "/a~1b/m~0n")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if string(s) != "x" {
+		t.Fatalf("bad value: %v (expected x)", string(s))
+	}
+}
+
+func TestJSONFileBackendGetterPointerAndFlatten(t *testing.T) {
+	_, err := newjsonFileBackendGetter(&jsonFileBackend{fileLocation: "testing/nested_secrets.json", pointer: true, flatten: true})
+	if err == nil {
+		t.Fatalf("should have failed with both pointer and flatten")
+	}
+}
+
+func TestJSONFileBackendSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := ioutil.WriteFile(path, []byte(`{"foo": "bar"}`), 0640); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONFileLocation(path), WithJSONFilePersist())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if err := sc.Set("foo", []byte("rotated")); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if err := sc.Set("new", []byte("value")); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	for id, value := range map[string]string{"foo": "rotated", "new": "value"} {
+		s, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get failed for %v: %v", id, err)
+		}
+		if string(s) != value {
+			t.Fatalf("bad value for %v: %v (expected %v)", id, string(s), value)
+		}
+	}
+	// a new client reads the persisted values
+	sc, err = NewSecretsClient(WithJSONFileBackend(), WithJSONFileLocation(path))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if s, err := sc.Get("foo"); err != nil || string(s) != "rotated" {
+		t.Fatalf("value should have been persisted: %v (%v)", string(s), err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("error checking file: %v", err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("file mode should be preserved: %v", fi.Mode())
+	}
+	if leftover, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".secrets.json.tmp*")); len(leftover) > 0 {
+		t.Fatalf("temporary files should be removed: %v", leftover)
+	}
+}
+
+func TestJSONFileBackendSetInMemory(t *testing.T) {
+	jbg, err := newjsonFileBackendGetter(&jsonFileBackend{fileLocation: "example/secrets.json"})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if err := jbg.Set("foo", []byte("changed")); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if s, err := jbg.Get("foo"); err != nil || string(s) != "changed" {
+		t.Fatalf("bad value: %v (%v)", string(s), err)
+	}
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONFileLocation("example/secrets.json"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if s, err := sc.Get("foo"); err != nil || string(s) != "bar" {
+		t.Fatalf("file should be unchanged without persistence: %v (%v)", string(s), err)
+	}
+}
+
+func TestJSONFileBackendSetNotSupported(t *testing.T) {
+	jbg, err := newjsonFileBackendGetter(&jsonFileBackend{fileLocation: "testing/nested_secrets.json", flatten: true})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if err := jbg.Set("foo", []byte("x")); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+	sc, err := NewSecretsClient(WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if err := sc.Set("foo", []byte("x")); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+func TestJSONFileBackendSetConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := ioutil.WriteFile(path, []byte(`{}`), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	jbg, err := newjsonFileBackendGetter(&jsonFileBackend{fileLocation: path, persist: true})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strconv.Itoa(i)
+			if err := jbg.Set(id, []byte(id)); err != nil {
+				t.Errorf("set failed: %v", err)
+			}
+			jbg.Get(id)
+		}(i)
+	}
+	wg.Wait()
+	jbg, err = newjsonFileBackendGetter(&jsonFileBackend{fileLocation: path})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if len(jbg.contents) != 10 {
+		t.Fatalf("every value should have been persisted: %v", jbg.contents)
+	}
+}
+
+func TestJSONFileBackendGetterNumbers(t *testing.T) {
+	cases := map[string]string{
+		"account_id": "9007199254740993",
+		"max_int64":  "9223372036854775807",
+		"ratio":      "0.10000000000000000555",
+	}
+	for _, jb := range []*jsonFileBackend{
+		{fileLocation: "testing/number_secrets.json", flatten: true},
+		{fileLocation: "testing/number_secrets.json", pointer: true},
+	} {
+		jbg, err := newjsonFileBackendGetter(jb)
+		if err != nil {
+			t.Fatalf("should have succeeded: %v", err)
+		}
+		ids := map[string]string{"nested.account_id": "18446744073709551615"}
+		if jb.pointer {
+			ids = map[string]string{"/nested/account_id": "18446744073709551615"}
+		}
+		for sid, value := range cases {
+			if jb.pointer {
+				sid = "/" + sid
+			}
+			ids[sid] = value
+		}
+		for sid, value := range ids {
+			s, err := jbg.Get(sid)
+			if err != nil {
+				t.Fatalf("get failed for %v: %v", sid, err)
+			}
+			if string(s) != value {
+				t.Fatalf("bad value for %v: %v (expected %v)", sid, string(s), value)
+			}
+		}
+	}
+}
+
+func TestJSONFileBackendGetterNumbersUnflattened(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := ioutil.WriteFile(path, []byte(`{"account_id": 9007199254740993, "name": "x"}`), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	jbg, err := newjsonFileBackendGetter(&jsonFileBackend{fileLocation: path})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if s, err := jbg.Get("account_id"); err != nil || string(s) != "9007199254740993" {
+		t.Fatalf("bad value: %v (%v)", string(s), err)
+	}
+	if _, err := newjsonFileBackendGetter(&jsonFileBackend{fileLocation: "testing/nested_secrets.json"}); err == nil {
+		t.Fatalf("should have failed for nested objects without flattening")
+	}
+}
+
+// fakeSOPS puts a sops command on the PATH that "decrypts" any file to a fixed document when given the age key
+// file keyFile (via SOPS_AGE_KEY_FILE), and fails otherwise
+func fakeSOPS(t *testing.T, keyFile string) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ "$SOPS_AGE_KEY_FILE" != "` + keyFile + `" ]; then
+	echo "Failed to get the data key required to decrypt the SOPS file." >&2
+	exit 128
+fi
+echo '{"db_password": "hunter2", "port": 5432}'
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "sops"), []byte(script), 0755); err != nil {
+		t.Fatalf("error writing fake sops: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSOPSJSONBackend(t *testing.T) {
+	fakeSOPS(t, "/keys/age.txt")
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	sc, err := NewSecretsClient(WithSOPSJSONBackend(), WithJSONFileLocation("secrets.enc.json"), WithSOPSKeyFile("/keys/age.txt"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for id, want := range map[string]string{"db_password": "hunter2", "port": "5432"} {
+		v, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(v) != want {
+			t.Fatalf("bad value for %v: %v", id, string(v))
+		}
+	}
+}
+
+func TestSOPSJSONBackendDecryptionFailure(t *testing.T) {
+	fakeSOPS(t, "/keys/age.txt")
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	_, err := NewSecretsClient(WithSOPSJSONBackend(), WithJSONFileLocation("secrets.enc.json"), WithSOPSKeyFile("/keys/wrong.txt"))
+	if err == nil || !strings.Contains(err.Error(), "error decrypting SOPS file") || !strings.Contains(err.Error(), "data key") {
+		t.Fatalf("should have failed with the sops error: %v", err)
+	}
+	_, err = NewSecretsClient(WithSOPSJSONBackend(), WithJSONFileLocation("secrets.enc.json"), WithSOPSKeyFile("/keys/age.txt"), WithJSONFilePersist())
+	if err == nil {
+		t.Fatalf("should have failed with persistence")
+	}
+}
+
+func TestJSONReader(t *testing.T) {
+	buf := bytes.NewBufferString(`{"foo": "bar", "port": 5432}` + "\n")
+	for _, r := range []io.Reader{strings.NewReader(`{"foo": "bar", "port": 5432}`), buf} {
+		sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONReader(r))
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		for id, want := range map[string]string{"foo": "bar", "port": "5432"} {
+			v, err := sc.Get(id)
+			if err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			if string(v) != want {
+				t.Fatalf("bad value for %v: %v", id, string(v))
+			}
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("reader should have been drained: %q", buf.String())
+	}
+}
+
+func TestJSONReaderAndFileLocation(t *testing.T) {
+	_, err := NewSecretsClient(WithJSONFileBackend(), WithJSONReader(strings.NewReader(`{}`)), WithJSONFileLocation("example/secrets.json"))
+	if err == nil {
+		t.Fatalf("should have failed with both a reader and a file location")
+	}
+	_, err = NewSecretsClient(WithJSONFileBackend(), WithJSONReader(strings.NewReader(`["foo"]`)))
+	if err == nil {
+		t.Fatalf("should have failed for a non-object")
+	}
+}
+
+func TestJSONAutoUnescape(t *testing.T) {
+	doc := `{"double": "\"line1\\nline2 \\\"quoted\\\"\"", "plain": "hunter2", "quoted": "\"as-is\"", "number": 5}`
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONReader(strings.NewReader(doc)), WithJSONAutoUnescape())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for id, want := range map[string]string{
+		"double": "line1\nline2 \"quoted\"",
+		"plain":  "hunter2",
+		"quoted": `"as-is"`,
+		"number": "5",
+	} {
+		v, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get %v failed: %v", id, err)
+		}
+		if string(v) != want {
+			t.Fatalf("bad value for %v: %q (expected %q)", id, v, want)
+		}
+	}
+}
+
+func TestJSONAutoUnescapeDisabled(t *testing.T) {
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONReader(strings.NewReader(`{"double": "\"a\\nb\""}`)))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	v, err := sc.Get("double")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(v) != `"a\nb"` {
+		t.Fatalf("value should be returned as stored: %q", v)
+	}
+}
@@ -0,0 +1,178 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MinMaskLength is the length below which secret values aren't masked, as replacing them would redact too much
+const MinMaskLength = 4
+
+// MaskReplacement replaces secret values in strings masked by a Masker
+const MaskReplacement = "***"
+
+// idSet is a set of secret IDs safe for concurrent use. A nil *idSet ignores additions.
+type idSet struct {
+	sync.Mutex
+	ids    map[string]struct{}
+	values map[string]struct{} // values read without an ID that can be fetched again, by GetAll and GetPath
+}
+
+func newIDSet() *idSet {
+	return &idSet{ids: map[string]struct{}{}, values: map[string]struct{}{}}
+}
+
+func (s *idSet) addValue(v []byte) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.values[string(v)] = struct{}{}
+}
+
+func (s *idSet) add(id string) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.ids[id] = struct{}{}
+}
+
+// list returns the IDs in the set, sorted
+func (s *idSet) list() []string {
+	if s == nil {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	ids := make([]string, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// listValues returns the values added with addValue
+func (s *idSet) listValues() []string {
+	if s == nil {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	values := make([]string, 0, len(s.values))
+	for v := range s.values {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Masker redacts known secret values from strings, eg before they're logged
+type Masker struct {
+	sc     *SecretsClient
+	mu     sync.RWMutex
+	values []string // longest first, so that a value containing another is masked entirely
+}
+
+// Masker returns a Masker for the values of the secrets in the allowlist (see WithAllowedIDs) and those already
+// retrieved by this client or any client scoped from it, including with GetAll, GetGroup and GetPath. Call Refresh
+// to pick up secrets retrieved later, or rotated values (except those read with GetAll and GetPath, which are masked
+// as read).
+func (sc *SecretsClient) Masker() (*Masker, error) {
+	root := *sc
+	root.prefix = ""
+	m := &Masker{sc: &root}
+	if err := m.Refresh(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Refresh fetches the current values of the secrets to mask. Secrets that no longer exist are ignored, whatever
+// the missing secret policy. If any other error occurs the previous values are kept. Refresh's reads aren't audited
+// or counted in metrics.
+func (m *Masker) Refresh() error {
+	ids := m.sc.fetched.list()
+	for id := range m.sc.allowedIDs {
+		ids = append(ids, id)
+	}
+	seen := map[string]bool{}
+	values := []string{}
+	for _, s := range m.sc.fetched.listValues() {
+		if len(s) >= MinMaskLength && !seen[s] {
+			seen[s] = true
+			values = append(values, s)
+		}
+	}
+	for _, id := range ids {
+		v, err := m.sc.maskValue(id)
+		switch {
+		case errors.Is(err, ErrSecretNotFound):
+			continue
+		case err != nil:
+			return fmt.Errorf("error fetching secret %v: %w", id, err)
+		}
+		if s := string(v); len(s) >= MinMaskLength && !seen[s] {
+			seen[s] = true
+			values = append(values, s)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values = values
+	return nil
+}
+
+// maskValue returns the current value of id for Refresh, from the caches or the backend as Get would. Unlike Get it
+// isn't audited, counted in metrics or shadow read, and a missing secret is returned as ErrSecretNotFound regardless
+// of the missing secret policy, as these reads are the Masker's rather than the application's.
+func (sc *SecretsClient) maskValue(id string) ([]byte, error) {
+	if err := sc.checkAllowed(id); err != nil {
+		return nil, err
+	}
+	loc := sc.resolve(id)
+	if sc.dryRun {
+		_, err := sc.locate(id)
+		return nil, err
+	}
+	if sc.envOverride {
+		if _, v, ok := sc.lookupOverride(id, loc); ok {
+			return v, nil
+		}
+	}
+	if sc.cache != nil {
+		if v, ok := sc.cache.peek(id); ok {
+			return v, nil
+		}
+	}
+	ctx := context.Background()
+	if timeout := sc.getTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if sc.shared != nil {
+		if v, ok := sc.getShared(ctx, id, loc); ok {
+			return v, nil
+		}
+	}
+	v, _, err := sc.fetch(ctx, id)
+	return v, sc.redactError(err)
+}
+
+// Mask returns s with every occurrence of a known secret value replaced by MaskReplacement
+func (m *Masker) Mask(s string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, v := range m.values {
+		s = strings.Replace(s, v, MaskReplacement, -1)
+	}
+	return s
+}
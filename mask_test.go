@@ -0,0 +1,191 @@
+package pvc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMasker(t *testing.T) {
+	env := map[string]string{
+		"SECRET_DB_PASSWORD": "hunter2",
+		"SECRET_API_KEY":     "k3y-hunter2-k3y",
+		"SECRET_SHORT":       "abc",
+		"SECRET_EMPTY":       "",
+	}
+	sc, err := NewSecretsClient(WithEnvMap(env), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for _, id := range []string{"db_password", "short", "empty"} {
+		if _, err := sc.Get(id); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if _, err := sc.Scoped("api_").Get("key"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	m, err := sc.Masker()
+	if err != nil {
+		t.Fatalf("error getting Masker: %v", err)
+	}
+	line := "login failed for user abc with password hunter2 (key k3y-hunter2-k3y)"
+	expected := "login failed for user abc with password *** (key ***)"
+	if s := m.Mask(line); s != expected {
+		t.Fatalf("bad masked line: %q (expected %q)", s, expected)
+	}
+	if s := m.Mask("nothing secret here"); s != "nothing secret here" {
+		t.Fatalf("line without secrets should be unchanged: %q", s)
+	}
+}
+
+func TestMaskerRefresh(t *testing.T) {
+	env := map[string]string{"SECRET_TOKEN": "oldtoken"}
+	sc, err := NewSecretsClient(WithEnvMap(env), WithEnvVarBackend(), WithAllowedIDs("token", "missing"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	m, err := sc.Masker()
+	if err != nil {
+		t.Fatalf("error getting Masker: %v", err)
+	}
+	if s := m.Mask("token=oldtoken"); s != "token=***" {
+		t.Fatalf("allowed secrets should be masked: %q", s)
+	}
+	env["SECRET_TOKEN"] = "newtoken"
+	if err := m.Refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if s := m.Mask("token=newtoken token=oldtoken"); s != "token=*** token=oldtoken" {
+		t.Fatalf("refresh should pick up the new value: %q", s)
+	}
+}
+
+func TestMaskerSharedCache(t *testing.T) {
+	shared := newMemorySharedCache()
+	shared.values["envvar:SECRET_TOKEN"] = []byte("fromshared")
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_TOKEN": "frombackend"}), WithEnvVarBackend(), WithCache(time.Minute), WithSharedCache(shared))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	r, err := sc.GetDetailed("token")
+	if err != nil || !r.FromCache || string(r.Value) != "fromshared" {
+		t.Fatalf("should have been served by the shared cache: %+v: %v", r, err)
+	}
+	m, err := sc.Masker()
+	if err != nil {
+		t.Fatalf("error getting Masker: %v", err)
+	}
+	if s := m.Mask("token=fromshared"); s != "token=***" {
+		t.Fatalf("values from the shared cache should be masked: %q", s)
+	}
+}
+
+func TestMaskerBulkReads(t *testing.T) {
+	t.Setenv("PVCTEST_SECRET_DB_PASSWORD", "hunter2")
+	t.Setenv("SECRET_PVCTEST_DB_USER", "dbadmin")
+	rs := &recordingSink{}
+	all, err := NewSecretsClient(WithEnvVarBackend(), WithEnvVarPrefix("PVCTEST_SECRET_"), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	group, err := NewSecretsClient(WithEnvVarBackend(), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"api_key": "k3y-from-vault"}}`))
+	}))
+	defer ts.Close()
+	path, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := all.GetAll(); err != nil {
+		t.Fatalf("get all failed: %v", err)
+	}
+	if _, err := group.GetGroup("pvctest_db_"); err != nil {
+		t.Fatalf("get group failed: %v", err)
+	}
+	if _, err := path.GetPath("secret/app"); err != nil {
+		t.Fatalf("get path failed: %v", err)
+	}
+	if len(rs.events) != 3 || rs.events[0].ID != "DB_PASSWORD" || rs.events[1].ID != "pvctest_db_USER" || rs.events[2].ID != "secret/app" {
+		t.Fatalf("bulk reads should be audited: %+v", rs.events)
+	}
+	for _, c := range []struct {
+		sc   *SecretsClient
+		line string
+	}{
+		{all, "password hunter2"},
+		{group, "user dbadmin"},
+		{path, "key k3y-from-vault"},
+	} {
+		m, err := c.sc.Masker()
+		if err != nil {
+			t.Fatalf("error getting Masker: %v", err)
+		}
+		if s := m.Mask(c.line); !strings.HasSuffix(s, " ***") {
+			t.Fatalf("values from bulk reads should be masked: %q", s)
+		}
+	}
+}
+
+func TestMaskerGetFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {"username": "admin", "password": "hunter2"}}`))
+	}))
+	defer ts.Close()
+	rs := &recordingSink{}
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.GetFields("db"); err != nil {
+		t.Fatalf("get fields failed: %v", err)
+	}
+	if _, err := sc.GetFields("missing"); err == nil {
+		t.Fatalf("get fields of a missing secret should have failed")
+	}
+	if len(rs.events) != 2 || !rs.events[0].Success || rs.events[0].ID != "db" || rs.events[1].Success {
+		t.Fatalf("get fields should be audited: %+v", rs.events)
+	}
+	m, err := sc.Masker()
+	if err != nil {
+		t.Fatalf("error getting Masker: %v", err)
+	}
+	if s := m.Mask("password hunter2"); s != "password ***" {
+		t.Fatalf("values from GetFields should be masked: %q", s)
+	}
+}
+
+func TestMaskerRefreshNotAudited(t *testing.T) {
+	mb := NewMemoryBackend(map[string][]byte{"db": []byte("hunter2")})
+	rs := &recordingSink{}
+	sc, err := NewSecretsClient(WithMemoryBackend(mb), WithAllowedIDs("db", "missing"), WithMissingSecretPolicy(PolicyPanic), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("db"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	m, err := sc.Masker()
+	if err != nil {
+		t.Fatalf("error getting Masker: %v", err)
+	}
+	if err := m.Refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if len(rs.events) != 1 {
+		t.Fatalf("only the application's Get should be audited: %+v", rs.events)
+	}
+	if s := m.Mask("password hunter2"); s != "password ***" {
+		t.Fatalf("bad mask: %q", s)
+	}
+}
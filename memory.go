@@ -0,0 +1,113 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSimulatedFailure is returned by a MemoryBackend set to fail with SetErrorTimes without an error given to SetError
+var ErrSimulatedFailure = errors.New("simulated backend failure")
+
+// MemoryBackend is a backend holding secrets in memory, for tests. Besides serving fixed values, it can delay
+// responses and fail on demand, so that timeout and retry handling can be tested deterministically.
+// Use it with WithMemoryBackend. It is safe for concurrent use, and may be changed while a client is using it.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	values   map[string][]byte
+	latency  map[string]time.Duration
+	errs     map[string]error
+	failures map[string]int // remaining Gets that fail, if limited with SetErrorTimes
+}
+
+// NewMemoryBackend returns a MemoryBackend holding values, keyed by secret ID
+func NewMemoryBackend(values map[string][]byte) *MemoryBackend {
+	mb := &MemoryBackend{
+		values:   map[string][]byte{},
+		latency:  map[string]time.Duration{},
+		errs:     map[string]error{},
+		failures: map[string]int{},
+	}
+	for id, v := range values {
+		mb.values[id] = append([]byte(nil), v...)
+	}
+	return mb
+}
+
+// Set stores value as the secret id
+func (mb *MemoryBackend) Set(id string, value []byte) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.values[id] = append([]byte(nil), value...)
+	return nil
+}
+
+// SetLatency delays every Get of id by d (zero removes the delay). A Get with a context gives up when it is done.
+func (mb *MemoryBackend) SetLatency(id string, d time.Duration) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.latency[id] = d
+}
+
+// SetError makes every Get of id fail with err, until it is called again with a nil err or the failures set with
+// SetErrorTimes run out
+func (mb *MemoryBackend) SetError(id string, err error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.errs[id] = err
+	delete(mb.failures, id)
+}
+
+// SetErrorTimes makes the next n Gets of id fail with the error set with SetError (or ErrSimulatedFailure), after
+// which they succeed
+func (mb *MemoryBackend) SetErrorTimes(id string, n int) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.errs[id] == nil {
+		mb.errs[id] = ErrSimulatedFailure
+	}
+	mb.failures[id] = n
+}
+
+func (mb *MemoryBackend) Get(id string) ([]byte, error) {
+	return mb.GetContext(context.Background(), id)
+}
+
+func (mb *MemoryBackend) GetContext(ctx context.Context, id string) ([]byte, error) {
+	mb.mu.Lock()
+	d := mb.latency[id]
+	mb.mu.Unlock()
+	if d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if err := mb.errs[id]; err != nil {
+		if n, ok := mb.failures[id]; ok {
+			if n <= 0 {
+				delete(mb.failures, id)
+				delete(mb.errs, id)
+				return mb.get(id)
+			}
+			mb.failures[id] = n - 1
+		}
+		return nil, err
+	}
+	return mb.get(id)
+}
+
+// get returns a copy of the value of id. The caller must hold the lock.
+func (mb *MemoryBackend) get(id string) ([]byte, error) {
+	v, ok := mb.values[id]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
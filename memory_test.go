@@ -0,0 +1,103 @@
+package pvc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// getWithRetries calls Get until it succeeds or attempts run out, returning the number of attempts made
+func getWithRetries(sc *SecretsClient, id string, attempts int) ([]byte, int, error) {
+	var v []byte
+	var err error
+	for i := 1; i <= attempts; i++ {
+		v, err = sc.Get(id)
+		if err == nil {
+			return v, i, nil
+		}
+	}
+	return nil, attempts, err
+}
+
+func TestMemoryBackendErrorTimes(t *testing.T) {
+	mb := NewMemoryBackend(map[string][]byte{"foo": []byte("bar")})
+	sc, err := NewSecretsClient(WithMemoryBackend(mb))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	mb.SetErrorTimes("foo", 2)
+	v, attempts, err := getWithRetries(sc, "foo", 5)
+	if err != nil {
+		t.Fatalf("get should have succeeded after retrying: %v", err)
+	}
+	if string(v) != "bar" || attempts != 3 {
+		t.Fatalf("expected bar on the 3rd attempt, got %q on attempt %v", v, attempts)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get should keep succeeding: %v", err)
+	}
+	// the error set with SetError is the one returned
+	errBoom := errors.New("boom")
+	mb.SetError("foo", errBoom)
+	mb.SetErrorTimes("foo", 2)
+	if _, _, err := getWithRetries(sc, "foo", 2); !errors.Is(err, errBoom) {
+		t.Fatalf("expected the configured error, got %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get should succeed once the failures run out: %v", err)
+	}
+}
+
+func TestMemoryBackendError(t *testing.T) {
+	mb := NewMemoryBackend(map[string][]byte{"foo": []byte("bar")})
+	sc, err := NewSecretsClient(WithMemoryBackend(mb))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	mb.SetError("foo", ErrSecretNotFound)
+	for i := 0; i < 3; i++ {
+		if _, err := sc.Get("foo"); !errors.Is(err, ErrSecretNotFound) {
+			t.Fatalf("expected ErrSecretNotFound, got %v", err)
+		}
+	}
+	mb.SetError("foo", nil)
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed after clearing the error: %v", err)
+	}
+	if _, err := sc.Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestMemoryBackendLatency(t *testing.T) {
+	mb := NewMemoryBackend(map[string][]byte{"slow": []byte("bar"), "fast": []byte("baz")})
+	sc, err := NewSecretsClient(WithMemoryBackend(mb), WithTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	mb.SetLatency("slow", time.Minute)
+	if _, err := sc.Get("slow"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if _, err := sc.Get("fast"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	mb.SetLatency("slow", 0)
+	if _, err := sc.Get("slow"); err != nil {
+		t.Fatalf("get failed after removing the latency: %v", err)
+	}
+}
+
+func TestMemoryBackendSet(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	sc, err := NewSecretsClient(WithMemoryBackend(mb))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	if err := sc.Set("foo", []byte("bar")); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if v, err := sc.Get("foo"); err != nil || string(v) != "bar" {
+		t.Fatalf("bad value: %q: %v", v, err)
+	}
+}
@@ -0,0 +1,112 @@
+package pvc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsBuckets are the upper bounds, in seconds, of the buckets of the pvc_get_duration_seconds histogram
+var MetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metrics are counts of Gets by backend, exposed by MetricsHandler
+type metrics struct {
+	sync.Mutex
+	gets      map[string]uint64
+	errors    map[[2]string]uint64 // keyed by backend and error kind
+	durations map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket of MetricsBuckets, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		gets:      map[string]uint64{},
+		errors:    map[[2]string]uint64{},
+		durations: map[string]*histogram{},
+	}
+}
+
+// observe records a Get from backend that took d and returned err
+func (m *metrics) observe(backend string, err error, d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.gets[backend]++
+	if err != nil {
+		m.errors[[2]string{backend, errorKind(err)}]++
+	}
+	h, ok := m.durations[backend]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(MetricsBuckets))}
+		m.durations[backend] = h
+	}
+	s := d.Seconds()
+	if i := sort.SearchFloat64s(MetricsBuckets, s); i < len(MetricsBuckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += s
+}
+
+// write writes the metrics to w in the Prometheus text exposition format
+func (m *metrics) write(w *strings.Builder) {
+	m.Lock()
+	defer m.Unlock()
+	backends := make([]string, 0, len(m.gets))
+	for b := range m.gets {
+		backends = append(backends, b)
+	}
+	sort.Strings(backends)
+	w.WriteString("# HELP pvc_gets_total Secret Gets, successful or not.\n# TYPE pvc_gets_total counter\n")
+	for _, b := range backends {
+		fmt.Fprintf(w, "pvc_gets_total{backend=%q} %v\n", b, m.gets[b])
+	}
+	errs := make([][2]string, 0, len(m.errors))
+	for k := range m.errors {
+		errs = append(errs, k)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i][0] < errs[j][0] || (errs[i][0] == errs[j][0] && errs[i][1] < errs[j][1])
+	})
+	w.WriteString("# HELP pvc_errors_total Failed secret Gets.\n# TYPE pvc_errors_total counter\n")
+	for _, k := range errs {
+		fmt.Fprintf(w, "pvc_errors_total{backend=%q,kind=%q} %v\n", k[0], k[1], m.errors[k])
+	}
+	w.WriteString("# HELP pvc_get_duration_seconds Duration of secret Gets.\n# TYPE pvc_get_duration_seconds histogram\n")
+	for _, b := range backends {
+		h := m.durations[b]
+		var cumulative uint64
+		for i, le := range MetricsBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "pvc_get_duration_seconds_bucket{backend=%q,le=%q} %v\n", b, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "pvc_get_duration_seconds_bucket{backend=%q,le=\"+Inf\"} %v\n", b, h.count)
+		fmt.Fprintf(w, "pvc_get_duration_seconds_sum{backend=%q} %v\n", b, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "pvc_get_duration_seconds_count{backend=%q} %v\n", b, h.count)
+	}
+}
+
+// MetricsHandler returns a handler serving the client's metrics in the Prometheus text format, for scraping without
+// a Prometheus client library: pvc_gets_total and pvc_errors_total (by error kind, see AuditEvent.ErrorKind) counters
+// and a pvc_get_duration_seconds histogram, all labeled by backend. Metrics must be enabled with WithMetrics,
+// otherwise the handler responds 404.
+func (sc *SecretsClient) MetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if sc.metrics == nil {
+			http.Error(w, "metrics not enabled", http.StatusNotFound)
+			return
+		}
+		b := &strings.Builder{}
+		sc.metrics.write(b)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write([]byte(b.String()))
+	}
+}
@@ -0,0 +1,59 @@
+package pvc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithMetrics())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for _, id := range []string{"foo", "foo", "missing"} {
+		sc.Get(id)
+	}
+	ts := httptest.NewServer(sc.MetricsHandler())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("bad content type: %v", ct)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("error reading response: %v", err)
+	}
+	body := string(b)
+	for _, line := range []string{
+		"# TYPE pvc_gets_total counter",
+		`pvc_gets_total{backend="envvar"} 3`,
+		`pvc_errors_total{backend="envvar",kind="not_found"} 1`,
+		"# TYPE pvc_get_duration_seconds histogram",
+		`pvc_get_duration_seconds_bucket{backend="envvar",le="10"} 3`,
+		`pvc_get_duration_seconds_bucket{backend="envvar",le="+Inf"} 3`,
+		`pvc_get_duration_seconds_count{backend="envvar"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing %q in:\n%v", line, body)
+		}
+	}
+}
+
+func TestMetricsHandlerNotEnabled(t *testing.T) {
+	sc, err := NewSecretsClient(WithNoopBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	w := httptest.NewRecorder()
+	sc.MetricsHandler()(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("bad status: %v", w.Code)
+	}
+}
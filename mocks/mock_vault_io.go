@@ -4,7 +4,12 @@
 package mocks
 
 import (
+	context "context"
+	http "net/http"
+	time "time"
+
 	gomock "github.com/golang/mock/gomock"
+	api "github.com/hashicorp/vault/api"
 )
 
 // Mock of vaultIO interface
@@ -68,6 +73,49 @@ func (_mr *_MockvaultIORecorder) K8sAuth(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "K8sAuth", arg0, arg1)
 }
 
+func (_m *MockvaultIO) AgentTokenSinkAuth(path string) error {
+	ret := _m.ctrl.Call(_m, "AgentTokenSinkAuth", path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockvaultIORecorder) AgentTokenSinkAuth(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AgentTokenSinkAuth", arg0)
+}
+
+func (_m *MockvaultIO) JWTAuth(jwt string, role string) error {
+	ret := _m.ctrl.Call(_m, "JWTAuth", jwt, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockvaultIORecorder) JWTAuth(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "JWTAuth", arg0, arg1)
+}
+
+func (_m *MockvaultIO) GetRaw(path string) (*api.Secret, http.Header, error) {
+	ret := _m.ctrl.Call(_m, "GetRaw", path)
+	ret0, _ := ret[0].(*api.Secret)
+	ret1, _ := ret[1].(http.Header)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockvaultIORecorder) GetRaw(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRaw", arg0)
+}
+
+func (_m *MockvaultIO) TransitEncrypt(mount string, key string, plaintext []byte) (string, error) {
+	ret := _m.ctrl.Call(_m, "TransitEncrypt", mount, key, plaintext)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockvaultIORecorder) TransitEncrypt(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TransitEncrypt", arg0, arg1, arg2)
+}
+
 func (_m *MockvaultIO) GetStringValue(path string) (string, error) {
 	ret := _m.ctrl.Call(_m, "GetStringValue", path)
 	ret0, _ := ret[0].(string)
@@ -89,3 +137,45 @@ func (_m *MockvaultIO) GetBase64Value(path string) ([]byte, error) {
 func (_mr *_MockvaultIORecorder) GetBase64Value(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetBase64Value", arg0)
 }
+
+func (_m *MockvaultIO) TokenTTL() (time.Duration, error) {
+	ret := _m.ctrl.Call(_m, "TokenTTL")
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockvaultIORecorder) TokenTTL() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TokenTTL")
+}
+
+func (_m *MockvaultIO) GetValues(path string) (map[string]interface{}, error) {
+	ret := _m.ctrl.Call(_m, "GetValues", path)
+	ret0, _ := ret[0].(map[string]interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockvaultIORecorder) GetValues(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetValues", arg0)
+}
+
+func (_m *MockvaultIO) GetStringValueWithTTL(ctx context.Context, path string) (string, time.Duration, error) {
+	ret := _m.ctrl.Call(_m, "GetStringValueWithTTL", ctx, path)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(time.Duration)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockvaultIORecorder) GetStringValueWithTTL(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetStringValueWithTTL", arg0, arg1)
+}
+
+func (_m *MockvaultIO) SetToken(token string) {
+	_m.ctrl.Call(_m, "SetToken", token)
+}
+
+func (_mr *_MockvaultIORecorder) SetToken(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetToken", arg0)
+}
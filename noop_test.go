@@ -0,0 +1,39 @@
+package pvc
+
+import (
+	"testing"
+)
+
+func TestNoopBackend(t *testing.T) {
+	sc, err := NewSecretsClient(WithNoopBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	v, err := sc.Get("anything")
+	if err != nil || v == nil || len(v) != 0 {
+		t.Fatalf("should have returned an empty value: %q: %v", v, err)
+	}
+}
+
+func TestNoopBackendValue(t *testing.T) {
+	sc, err := NewSecretsClient(WithNoopBackend(), WithNoopValue([]byte("placeholder")))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for _, id := range []string{"foo", "db/password", ""} {
+		v, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(v) != "placeholder" {
+			t.Fatalf("bad value for %q: %v", id, string(v))
+		}
+		v[0] = 'x' // must not affect the placeholder
+	}
+}
+
+func TestNoopBackendCountsAsBackend(t *testing.T) {
+	if _, err := NewSecretsClient(WithNoopBackend(), WithEnvVarBackend()); err == nil {
+		t.Fatalf("should have failed with multiple backends")
+	}
+}
@@ -0,0 +1,158 @@
+package pvc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Default mapping for this backend
+const (
+	DefaultOnePasswordMapping = "{{ .ID }}"
+)
+
+type onePasswordBackendGetter struct {
+	httpClient *http.Client
+	mapper     SecretMapper
+	config     *onePasswordBackend
+}
+
+// onePasswordItem is the subset of a 1Password Connect item used by the backend
+type onePasswordItem struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Fields []struct {
+		ID      string `json:"id"`
+		Label   string `json:"label"`
+		Purpose string `json:"purpose"`
+		Value   string `json:"value"`
+	} `json:"fields"`
+}
+
+func newOnePasswordBackendGetter(ob *onePasswordBackend) (*onePasswordBackendGetter, error) {
+	if ob.host == "" {
+		return nil, fmt.Errorf("1Password Connect host is required")
+	}
+	if ob.token == "" {
+		return nil, fmt.Errorf("1Password Connect token is required")
+	}
+	if ob.vaultID == "" {
+		return nil, fmt.Errorf("1Password vault is required")
+	}
+	if ob.mapping == "" {
+		ob.mapping = DefaultOnePasswordMapping
+	}
+	if ob.notFound == nil {
+		ob.notFound = DefaultNotFoundDetector
+	}
+	sm, err := newSecretMapper(ob.mapping, ob.mappingRules...)
+	if err != nil {
+		return nil, fmt.Errorf("error with mapping: %v", err)
+	}
+	return &onePasswordBackendGetter{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		mapper:     sm,
+		config:     ob,
+	}, nil
+}
+
+// request performs an authenticated GET against the Connect API and decodes the JSON response into out.
+// It returns ErrSecretNotFound if the server responds with 404.
+func (obg *onePasswordBackendGetter) request(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := strings.TrimSuffix(obg.config.host, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+obg.config.token)
+	resp, err := obg.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error performing request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+	switch {
+	case obg.config.notFound(resp.StatusCode, body):
+		return ErrSecretNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status code from 1Password Connect: %v", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
+
+// getItem fetches the item whose title or UUID is name
+func (obg *onePasswordBackendGetter) getItem(ctx context.Context, name string) (*onePasswordItem, error) {
+	itemsPath := "/v1/vaults/" + url.PathEscape(obg.config.vaultID) + "/items"
+	items := []onePasswordItem{}
+	err := obg.request(ctx, itemsPath, url.Values{"filter": []string{fmt.Sprintf("title eq %q", name)}}, &items)
+	if err != nil {
+		return nil, fmt.Errorf("error listing items: %w", err)
+	}
+	id := name
+	if len(items) > 0 {
+		id = items[0].ID
+	}
+	item := &onePasswordItem{}
+	err = obg.request(ctx, itemsPath+"/"+url.PathEscape(id), nil, item)
+	if err != nil {
+		return nil, fmt.Errorf("error getting item: %w", err)
+	}
+	return item, nil
+}
+
+// fieldValue returns the value of the configured field of item
+func (obg *onePasswordBackendGetter) fieldValue(item *onePasswordItem) (string, bool) {
+	for _, f := range item.Fields {
+		if obg.config.field == "" && f.Purpose == "PASSWORD" {
+			return f.Value, true
+		}
+		if obg.config.field != "" && f.Label == obg.config.field {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+func (obg *onePasswordBackendGetter) Get(id string) ([]byte, error) {
+	return obg.GetContext(context.Background(), id)
+}
+
+// locate returns the title or UUID of the item holding id
+func (obg *onePasswordBackendGetter) locate(id string) (string, error) {
+	name, err := obg.mapper.MapSecret(id)
+	if err != nil {
+		return "", fmt.Errorf("error mapping id to item: %v", err)
+	}
+	return name, nil
+}
+
+func (obg *onePasswordBackendGetter) GetContext(ctx context.Context, id string) ([]byte, error) {
+	name, err := obg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	item, err := obg.getItem(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := obg.fieldValue(item)
+	if !ok {
+		return nil, fmt.Errorf("%w: item %v has no matching field", ErrSecretNotFound, name)
+	}
+	return []byte(v), nil
+}
@@ -0,0 +1,130 @@
+package pvc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testOnePasswordVault = "vault123"
+
+// testOnePasswordServer mimics the 1Password Connect item endpoints for a single item
+func testOnePasswordServer(t *testing.T) *httptest.Server {
+	item := map[string]interface{}{
+		"id":    "item123",
+		"title": "db",
+		"fields": []map[string]string{
+			{"id": "username", "label": "username", "value": "admin"},
+			{"id": "password", "label": "password", "purpose": "PASSWORD", "value": "hunter2"},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/vaults/"+testOnePasswordVault+"/items", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer footoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		items := []map[string]interface{}{}
+		if r.URL.Query().Get("filter") == `title eq "db"` {
+			items = append(items, item)
+		}
+		json.NewEncoder(w).Encode(items)
+	})
+	mux.HandleFunc("/v1/vaults/"+testOnePasswordVault+"/items/item123", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(item)
+	})
+	mux.HandleFunc("/v1/vaults/"+testOnePasswordVault+"/items/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+func testOnePasswordBackendGetter(t *testing.T, host, field string) *onePasswordBackendGetter {
+	ob := &onePasswordBackend{
+		host:    host,
+		token:   "footoken",
+		vaultID: testOnePasswordVault,
+		field:   field,
+	}
+	obg, err := newOnePasswordBackendGetter(ob)
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	return obg
+}
+
+func TestNewOnePasswordBackendGetterMissingConfig(t *testing.T) {
+	_, err := newOnePasswordBackendGetter(&onePasswordBackend{host: "foo", token: "bar"})
+	if err == nil {
+		t.Fatalf("should have failed without vault")
+	}
+}
+
+func TestOnePasswordBackendGetterGetByTitle(t *testing.T) {
+	ts := testOnePasswordServer(t)
+	defer ts.Close()
+	obg := testOnePasswordBackendGetter(t, ts.URL, "")
+	s, err := obg.Get("db")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "hunter2" {
+		t.Fatalf("bad value: %v (expected hunter2)", string(s))
+	}
+}
+
+func TestOnePasswordBackendGetterGetByUUID(t *testing.T) {
+	ts := testOnePasswordServer(t)
+	defer ts.Close()
+	obg := testOnePasswordBackendGetter(t, ts.URL, "")
+	s, err := obg.Get("item123")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "hunter2" {
+		t.Fatalf("bad value: %v (expected hunter2)", string(s))
+	}
+}
+
+func TestOnePasswordBackendGetterGetField(t *testing.T) {
+	ts := testOnePasswordServer(t)
+	defer ts.Close()
+	obg := testOnePasswordBackendGetter(t, ts.URL, "username")
+	s, err := obg.Get("db")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "admin" {
+		t.Fatalf("bad value: %v (expected admin)", string(s))
+	}
+	obg.config.field = "missing"
+	if _, err := obg.Get("db"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, received: %v", err)
+	}
+}
+
+func TestOnePasswordBackendGetterGetMissingItem(t *testing.T) {
+	ts := testOnePasswordServer(t)
+	defer ts.Close()
+	obg := testOnePasswordBackendGetter(t, ts.URL, "")
+	if _, err := obg.Get("nope"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, received: %v", err)
+	}
+}
+
+func TestOnePasswordBackendGetterNotFoundDetector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors": ["no such item"]}`))
+	}))
+	defer ts.Close()
+	obg := testOnePasswordBackendGetter(t, ts.URL, "")
+	obg.config.notFound = func(status int, body []byte) bool {
+		return status == http.StatusOK && strings.HasPrefix(string(body), `{"errors"`)
+	}
+	if _, err := obg.Get("db"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
@@ -0,0 +1,469 @@
+package pvc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+// GetPKCS12 returns the TLS certificate held in the secret id, a PKCS#12 (PFX) bundle protected by password, with the
+// certificate matching the private key first followed by any others in the bundle. Bundles must be DER-encoded and
+// use PBES2 (PBKDF2 with AES or 3DES, the OpenSSL 3 default) or pbeWithSHAAnd3-KeyTripleDES-CBC; the RC2 encryption
+// of older bundles (openssl pkcs12 -legacy) isn't supported, nor are iteration counts above 1048576. An error wrapping ErrIncorrectPassword is returned if
+// password is wrong. Errors don't include the value.
+func (sc *SecretsClient) GetPKCS12(id string, password string) (tls.Certificate, error) {
+	v, err := sc.Get(id)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := decodePKCS12(v, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("secret %v is not a valid PKCS#12 bundle: %w", id, err)
+	}
+	return cert, nil
+}
+
+// Object identifiers used in PKCS#12 bundles (RFC 7292, RFC 8018)
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPBEWithSHAAnd3DESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBES2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidDESEDE3CBC           = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA1                 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA512               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm asn1.RawValue // AlgorithmIdentifier, parsed with algorithmIdentifier
+	Digest    []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm algorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue   `asn1:"tag:0,explicit"`
+	Attributes []asn1.RawValue `asn1:"set,optional"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                 `asn1:"optional"`
+	PRF        algorithmIdentifier `asn1:"optional"`
+}
+
+// pkcs12MaxIterations bounds the iteration counts of the key derivations in a bundle, so that decoding a crafted one
+// can't take arbitrarily long. OpenSSL uses 2048 by default.
+const pkcs12MaxIterations = 1 << 20
+
+// checkIterations returns an error if n isn't a usable iteration count
+func checkIterations(n int) error {
+	if n < 1 || n > pkcs12MaxIterations {
+		return fmt.Errorf("unsupported iteration count: %v", n)
+	}
+	return nil
+}
+
+// decodePKCS12 returns the certificates and private key in the DER-encoded PKCS#12 bundle pfx
+func decodePKCS12(pfx []byte, password string) (tls.Certificate, error) {
+	p := pfxPDU{}
+	if rest, err := asn1.Unmarshal(pfx, &p); err != nil {
+		return tls.Certificate{}, fmt.Errorf("error decoding bundle: %v", err)
+	} else if len(rest) > 0 {
+		return tls.Certificate{}, fmt.Errorf("trailing data after bundle")
+	}
+	if p.Version != 3 {
+		return tls.Certificate{}, fmt.Errorf("unsupported version: %v", p.Version)
+	}
+	if !p.AuthSafe.ContentType.Equal(oidData) {
+		return tls.Certificate{}, fmt.Errorf("unsupported content type (only password integrity is supported): %v", p.AuthSafe.ContentType)
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return tls.Certificate{}, fmt.Errorf("error decoding content: %v", err)
+	}
+	if len(p.MacData.Mac.Digest) > 0 {
+		if err := verifyPKCS12MAC(p.MacData, authSafe, password); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	contents := []contentInfo{}
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return tls.Certificate{}, fmt.Errorf("error decoding content: %v", err)
+	}
+	var certs []*x509.Certificate
+	var key crypto.PrivateKey
+	for _, ci := range contents {
+		var data []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &data); err != nil {
+				return tls.Certificate{}, fmt.Errorf("error decoding content: %v", err)
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			ed := encryptedData{}
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return tls.Certificate{}, fmt.Errorf("error decoding encrypted content: %v", err)
+			}
+			var err error
+			data, err = pbeDecrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, password)
+			if err != nil {
+				return tls.Certificate{}, err
+			}
+		default:
+			return tls.Certificate{}, fmt.Errorf("unsupported content type: %v", ci.ContentType)
+		}
+		bags := []safeBag{}
+		if _, err := asn1.Unmarshal(data, &bags); err != nil {
+			return tls.Certificate{}, fmt.Errorf("error decoding bags: %v", err)
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				cb := certBag{}
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return tls.Certificate{}, fmt.Errorf("error decoding certificate bag: %v", err)
+				}
+				if !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				c, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return tls.Certificate{}, fmt.Errorf("error parsing certificate: %v", err)
+				}
+				certs = append(certs, c)
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidShroudedKeyBag):
+				if key != nil {
+					return tls.Certificate{}, fmt.Errorf("bundle holds more than one private key")
+				}
+				der := bag.Value.Bytes
+				if bag.ID.Equal(oidShroudedKeyBag) {
+					epki := encryptedPrivateKeyInfo{}
+					if _, err := asn1.Unmarshal(der, &epki); err != nil {
+						return tls.Certificate{}, fmt.Errorf("error decoding key bag: %v", err)
+					}
+					var err error
+					der, err = pbeDecrypt(epki.Algorithm, epki.EncryptedData, password)
+					if err != nil {
+						return tls.Certificate{}, err
+					}
+				}
+				k, err := x509.ParsePKCS8PrivateKey(der)
+				if err != nil {
+					return tls.Certificate{}, fmt.Errorf("error parsing private key: %v", err)
+				}
+				key = k
+			}
+		}
+	}
+	if key == nil {
+		return tls.Certificate{}, fmt.Errorf("bundle holds no private key")
+	}
+	return certificateForKey(certs, key)
+}
+
+// certificateForKey returns the TLS certificate with key and the certificate in certs matching it as its leaf,
+// followed by the rest of certs
+func certificateForKey(certs []*x509.Certificate, key crypto.PrivateKey) (tls.Certificate, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("unsupported private key type: %T", key)
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("unsupported public key type: %T", signer.Public())
+	}
+	cert := tls.Certificate{PrivateKey: key}
+	for i, c := range certs {
+		if pub.Equal(c.PublicKey) {
+			cert.Leaf = c
+			cert.Certificate = append([][]byte{c.Raw}, cert.Certificate...)
+			for _, other := range certs[i+1:] {
+				cert.Certificate = append(cert.Certificate, other.Raw)
+			}
+			return cert, nil
+		}
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return tls.Certificate{}, fmt.Errorf("bundle holds no certificate for the private key")
+}
+
+// verifyPKCS12MAC checks the MAC of content, which fails if password is wrong
+func verifyPKCS12MAC(md macData, content []byte, password string) error {
+	alg := algorithmIdentifier{}
+	if _, err := asn1.Unmarshal(md.Mac.Algorithm.FullBytes, &alg); err != nil {
+		return fmt.Errorf("error decoding MAC algorithm: %v", err)
+	}
+	var h func() hash.Hash
+	switch {
+	case alg.Algorithm.Equal(oidSHA1):
+		h = sha1.New
+	case alg.Algorithm.Equal(oidSHA256):
+		h = sha256.New
+	case alg.Algorithm.Equal(oidSHA512):
+		h = sha512.New
+	default:
+		return fmt.Errorf("unsupported MAC algorithm: %v", alg.Algorithm)
+	}
+	if err := checkIterations(md.Iterations); err != nil {
+		return err
+	}
+	key := pkcs12KDF(h, bmpPassword(password), md.MacSalt, 3, md.Iterations, h().Size())
+	mac := hmac.New(h, key)
+	mac.Write(content)
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// pbeDecrypt decrypts data encrypted with the password-based encryption scheme alg
+func pbeDecrypt(alg algorithmIdentifier, data []byte, password string) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case alg.Algorithm.Equal(oidPBEWithSHAAnd3DESCBC):
+		params := pbeParams{}
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("error decoding encryption parameters: %v", err)
+		}
+		if err := checkIterations(params.Iterations); err != nil {
+			return nil, err
+		}
+		pw := bmpPassword(password)
+		key := pkcs12KDF(sha1.New, pw, params.Salt, 1, params.Iterations, 24)
+		iv = pkcs12KDF(sha1.New, pw, params.Salt, 2, params.Iterations, 8)
+		var err error
+		if block, err = des.NewTripleDESCipher(key); err != nil {
+			return nil, err
+		}
+	case alg.Algorithm.Equal(oidPBES2):
+		var err error
+		if block, iv, err = pbes2Cipher(alg.Parameters.FullBytes, password); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encryption algorithm: %v", alg.Algorithm)
+	}
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("encrypted data is not a multiple of the block size")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	// a bundle without a MAC is only found to have the wrong password when the padding doesn't check out
+	n := int(out[len(out)-1])
+	if n == 0 || n > block.BlockSize() || !bytes.Equal(out[len(out)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, ErrIncorrectPassword
+	}
+	return out[:len(out)-n], nil
+}
+
+// pbes2Cipher returns the cipher and IV described by the PBES2 parameters params, keyed from password with PBKDF2
+func pbes2Cipher(params []byte, password string) (cipher.Block, []byte, error) {
+	p := pbes2Params{}
+	if _, err := asn1.Unmarshal(params, &p); err != nil {
+		return nil, nil, fmt.Errorf("error decoding PBES2 parameters: %v", err)
+	}
+	if !p.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("unsupported key derivation function: %v", p.KeyDerivationFunc.Algorithm)
+	}
+	kp := pbkdf2Params{}
+	if _, err := asn1.Unmarshal(p.KeyDerivationFunc.Parameters.FullBytes, &kp); err != nil {
+		return nil, nil, fmt.Errorf("error decoding PBKDF2 parameters: %v", err)
+	}
+	h := sha1.New
+	switch prf := kp.PRF.Algorithm; {
+	case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+	case prf.Equal(oidHMACWithSHA256):
+		h = sha256.New
+	case prf.Equal(oidHMACWithSHA512):
+		h = sha512.New
+	default:
+		return nil, nil, fmt.Errorf("unsupported PBKDF2 function: %v", prf)
+	}
+	var keyLen int
+	var newCipher func(key []byte) (cipher.Block, error)
+	switch alg := p.EncryptionScheme.Algorithm; {
+	case alg.Equal(oidAES128CBC):
+		keyLen, newCipher = 16, aes.NewCipher
+	case alg.Equal(oidAES192CBC):
+		keyLen, newCipher = 24, aes.NewCipher
+	case alg.Equal(oidAES256CBC):
+		keyLen, newCipher = 32, aes.NewCipher
+	case alg.Equal(oidDESEDE3CBC):
+		keyLen, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, nil, fmt.Errorf("unsupported encryption scheme: %v", alg)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(p.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, fmt.Errorf("error decoding IV: %v", err)
+	}
+	if err := checkIterations(kp.Iterations); err != nil {
+		return nil, nil, err
+	}
+	block, err := newCipher(pbkdf2Key(h, []byte(password), kp.Salt, kp.Iterations, keyLen))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, fmt.Errorf("bad IV length: %v", len(iv))
+	}
+	return block, iv, nil
+}
+
+// pbkdf2Key derives a key of size bytes from password and salt with PBKDF2 (RFC 8018 section 5.2) using HMAC with h
+func pbkdf2Key(h func() hash.Hash, password, salt []byte, iterations, size int) []byte {
+	prf := hmac.New(h, password)
+	out := make([]byte, 0, size+prf.Size())
+	for block := uint32(1); len(out) < size; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:size]
+}
+
+// bmpPassword returns password as a NUL-terminated big-endian UTF-16 string, as the PKCS#12 key derivation expects
+func bmpPassword(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	b := make([]byte, 0, 2*len(units)+2)
+	for _, u := range units {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return append(b, 0, 0)
+}
+
+// pkcs12KDF derives size bytes of key material for purpose id (1 for keys, 2 for IVs and 3 for MAC keys) from
+// password and salt, as described in RFC 7292 appendix B.2
+func pkcs12KDF(h func() hash.Hash, password, salt []byte, id byte, iterations, size int) []byte {
+	u := h().Size()
+	v := h().BlockSize()
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	d := bytes.Repeat([]byte{id}, v)
+	in := append(fill(salt), fill(password)...)
+	out := make([]byte, 0, size+u)
+	for len(out) < size {
+		hh := h()
+		hh.Write(d)
+		hh.Write(in)
+		a := hh.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			hh.Reset()
+			hh.Write(a)
+			a = hh.Sum(a[:0])
+		}
+		out = append(out, a...)
+		// add B+1, where B is a repeated to v bytes, to each v byte block of the input
+		b := make([]byte, v)
+		for i := range b {
+			b[i] = a[i%u]
+		}
+		for j := 0; j < len(in); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(in[j+k]) + int(b[k])
+				in[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return out[:size]
+}
@@ -0,0 +1,184 @@
+package pvc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha1"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// PKCS#12 bundles of a self-signed P-256 certificate for pvc.test and its key, protected with the password hunter2 and
+// made with openssl pkcs12 -export, with the OpenSSL 3 defaults (PBES2, AES-256 and a SHA-256 MAC) and with
+// -certpbe PBE-SHA1-3DES -keypbe PBE-SHA1-3DES -macalg sha1
+const (
+	testPKCS12AES = "MIIEDAIBAzCCA8IGCSqGSIb3DQEHAaCCA7MEggOvMIIDqzCCAmIGCSqGSIb3DQEHBqCCAlMwggJPAgEAMIICSAYJKoZIhvcNAQcB" +
+		"MFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAiVB1OAObD6IAICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEB98" +
+		"OcsPzrGPAvlfA7g3kfaAggHgdXucQ/ml2i33tZNubSV7Xpa3a84km7IzCfp6uQ6//UnsmGwB5EVYGVBYniTUikk9iHJ2YWnBQQqZ" +
+		"mOYl3f0O7UaZdqZt74jiATSkzHf9k9uQ0MS555DIqMplB/Aw7n9ZNWkiF9seX5K326EmSVikTzIhQP6HADGx6fugvn3wF0qUWpPF" +
+		"pdKULwBEFPAMaqN6pf2qr7T5pR/X+zeDhxmRcnRYksFkUfOVnWFzBRw3F4O4KgUQ2psiYqARdR/V/9e8d1+HC0HAkWmFF/mq+/qy" +
+		"qGpbr1Vu4C6cQKfQhXliYjDTHqcBhrCeyHQJBNUB+Rs/femSf2o262yrYYtO+EBtTNLSOHgXnw/WHzCsLd5/I3haMsiCAPbeCQlw" +
+		"m6Bxy+k01nwcyCTfQ4fMJqz6P59A0hwsInXLGRJXIbzozIZ1093PM8yfEFTBlrj027falUXPLEi4hy6T0EtU73mNCoZA/E8BPAVt" +
+		"dECOOuXfRjWzeZjxdI/l29wSybRoa6xdNZgPkxUyqx1pKkRyw/DtSQGmVZkmTu0OE5aIH7CWJN5YdHnsZ8X+QugAoBIARvSjVVC/" +
+		"yfeo3yV3gTM90i1V/6DNsqNhlcQze4o2v+0M0iObAXP84DQ1zULs1G86Fe4GdoJHMIIBQQYJKoZIhvcNAQcBoIIBMgSCAS4wggEq" +
+		"MIIBJgYLKoZIhvcNAQwKAQKgge8wgewwVwYJKoZIhvcNAQUNMEowKQYJKoZIhvcNAQUMMBwECNMbLM+E1siGAgIIADAMBggqhkiG" +
+		"9w0CCQUAMB0GCWCGSAFlAwQBKgQQzN3HXxVKW2UfQFYbCUZQQASBkHP8jT4cbTLGDyxzTnBgYe6mQTHD4b3nJUSh96/2RLVVKB0w" +
+		"25NdsXHW492y2k8ZogTE6kyCp96Mrazw3Jq9hME5bq0xHBfayXVqQrDVFA/Z0KDEzSy8z6YbXjq+/PGM0/RdKg1DlD1NE1y6jda7" +
+		"TdPd/b7nspcEL+3yglasT7Hhl25ODCVXBcbt48iVtbUmOTElMCMGCSqGSIb3DQEJFTEWBBRxNBwMQ5+Jt1EbvF7nARWjJvvP2zBB" +
+		"MDEwDQYJYIZIAWUDBAIBBQAEILn0kqisFxttarHzegjwDG7gEjKAnMWRJAc0lhBBkLN3BAheGncfdIkJywICCAA="
+	testPKCS12DES = "MIIDggIBAzCCA0gGCSqGSIb3DQEHAaCCAzkEggM1MIIDMTCCAicGCSqGSIb3DQEHBqCCAhgwggIUAgEAMIICDQYJKoZIhvcNAQcB" +
+		"MBwGCiqGSIb3DQEMAQMwDgQILfQInCjuyjACAggAgIIB4Jc0wd0ozz+yf9vQvwtFqmMTMIgyN7zUrUWfgYJFWM7hHkOIhtWBSe1G" +
+		"2mtj+qkGb/PKnmLVAUicZWBHGcXORrFAMQSGop51BATX+IdxSu2HmEl5pk8UOXDAull+I5SqX1CV+j3zosA6ATgXYqO3nPEBDync" +
+		"n+L8MV6Z+68/cb5gC+YbbXgKkCFtG3pd/gnfcl/y6caAEYM6Bjssy19FN7WSuBftVpOCE+EprdX0Tm/ssyRi9dmlb+Bbhimj53fj" +
+		"WR+xKyTuhQXjcfJ+HgxL88195sKJ0qIFuL+lI+4bi5PCo5qk9Cycs7czcFCaZSsWfpt1pHXp2P8D4v8QK25Np59P8JLp4PZWGyMB" +
+		"SiLRM9fK920Im/Z9aC0dQYrwJrebWoTNW4OVVvPbf7b0Px4ty/nOnr4dbweJMIICC4/8k6QPqX5ZG/BKN7XbNgRISN4uwCrDRjKy" +
+		"wsQWyeHrYmsZQ9p+PQvm1MfdpDsFjHbSR2heuCj9+ua4SQ8/HYuQu6JAr0j8PC7p1VjBKymdfaIohK0PT8fXtVzP2z6omBn9720t" +
+		"BA/Vqvnf0UiFn0QDQGT0+sirT4fROmr56dr7CpjYBJmvSF/pe4wH3ZV/zD4lpIE+gysPv26guYdb3mHuicVGuDCCAQIGCSqGSIb3" +
+		"DQEHAaCB9ASB8TCB7jCB6wYLKoZIhvcNAQwKAQKggbQwgbEwHAYKKoZIhvcNAQwBAzAOBAi1zR1Wb2tKYgICCAAEgZBAs85BdROP" +
+		"Ca4j8wajuf/Sf/xwxqAxeLM8lBicLFt3pFI4MKKQh570qMDpZiecpddJ1JTKajsLLnsEEfpz5BJn/jrOcFFouGnvhfqdLpBmF2if" +
+		"DXKDhNOBXZeVJVEN2ydAmgj2SepYaVMDV3XMSdcffv4Pj76eHy/PZpj+8c1Qa9crWNX45tTSIunWcH+rCcwxJTAjBgkqhkiG9w0B" +
+		"CRUxFgQUcTQcDEOfibdRG7xe5wEVoyb7z9swMTAhMAkGBSsOAwIaBQAEFNerkhDfMCeNp8NrqMzTeSTMRnV8BAimf9C9Zd7D3wIC" +
+		"CAA="
+)
+
+func testPKCS12Client(t *testing.T) *SecretsClient {
+	values := map[string][]byte{"garbage": []byte("not a bundle")}
+	for id, b64 := range map[string]string{"aes": testPKCS12AES, "des": testPKCS12DES} {
+		b, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			t.Fatalf("error decoding fixture: %v", err)
+		}
+		values[id] = b
+	}
+	sc, err := NewSecretsClient(WithMemoryBackend(NewMemoryBackend(values)))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	return sc
+}
+
+func TestGetPKCS12(t *testing.T) {
+	sc := testPKCS12Client(t)
+	for _, id := range []string{"aes", "des"} {
+		cert, err := sc.GetPKCS12(id, "hunter2")
+		if err != nil {
+			t.Fatalf("%v: get PKCS#12 failed: %v", id, err)
+		}
+		if len(cert.Certificate) != 1 || cert.Leaf == nil || cert.Leaf.Subject.CommonName != "pvc.test" {
+			t.Fatalf("%v: bad certificate: %+v", id, cert)
+		}
+		if _, ok := cert.PrivateKey.(*ecdsa.PrivateKey); !ok {
+			t.Fatalf("%v: bad private key: %T", id, cert.PrivateKey)
+		}
+	}
+}
+
+func TestGetPKCS12Invalid(t *testing.T) {
+	sc := testPKCS12Client(t)
+	for _, id := range []string{"aes", "des"} {
+		if _, err := sc.GetPKCS12(id, "hunter3"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("%v: expected ErrIncorrectPassword, got %v", id, err)
+		}
+	}
+	_, err := sc.GetPKCS12("garbage", "hunter2")
+	if err == nil || errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("should have failed to decode: %v", err)
+	}
+	if _, err := sc.GetPKCS12("missing", "hunter2"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+// testPKCS12Bundle returns the decoded fixture b64, changed by modify if set
+func testPKCS12Bundle(t testing.TB, b64 string, modify func(p *pfxPDU)) []byte {
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatalf("error decoding fixture: %v", err)
+	}
+	if modify == nil {
+		return b
+	}
+	p := pfxPDU{}
+	if _, err := asn1.Unmarshal(b, &p); err != nil {
+		t.Fatalf("error decoding fixture: %v", err)
+	}
+	if rb, err := asn1.Marshal(p); err != nil || !bytes.Equal(rb, b) {
+		t.Fatalf("fixture doesn't survive re-encoding: %v", err)
+	}
+	modify(&p)
+	b, err = asn1.Marshal(p)
+	if err != nil {
+		t.Fatalf("error encoding bundle: %v", err)
+	}
+	return b
+}
+
+func TestDecodePKCS12Malformed(t *testing.T) {
+	aes := testPKCS12Bundle(t, testPKCS12AES, nil)
+	md5, _ := asn1.Marshal(algorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 5}})
+	for name, c := range map[string]struct {
+		pfx  []byte
+		want string
+	}{
+		"truncated": {aes[:len(aes)/2], "error decoding bundle"},
+		"trailing":  {append(append([]byte(nil), aes...), 0), "trailing data"},
+		"unsupported MAC algorithm": {testPKCS12Bundle(t, testPKCS12AES, func(p *pfxPDU) {
+			p.MacData.Mac.Algorithm = asn1.RawValue{FullBytes: md5}
+		}), "unsupported MAC algorithm"},
+		"zero MAC iterations": {testPKCS12Bundle(t, testPKCS12AES, func(p *pfxPDU) {
+			p.MacData.Iterations = 0
+		}), "unsupported iteration count: 0"},
+		"too many MAC iterations": {testPKCS12Bundle(t, testPKCS12AES, func(p *pfxPDU) {
+			p.MacData.Iterations = 1 << 30
+		}), "unsupported iteration count"},
+	} {
+		if _, err := decodePKCS12(c.pfx, "hunter2"); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%v: expected an error containing %q, got %v", name, c.want, err)
+		}
+	}
+	for _, b64 := range []string{testPKCS12AES, testPKCS12DES} {
+		badMAC := testPKCS12Bundle(t, b64, func(p *pfxPDU) {
+			p.MacData.Mac.Digest[0] ^= 1
+		})
+		if _, err := decodePKCS12(badMAC, "hunter2"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("a bad MAC should fail as ErrIncorrectPassword: %v", err)
+		}
+	}
+}
+
+func TestPBEDecryptUnsupported(t *testing.T) {
+	rc2 := algorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}} // pbeWithSHAAnd40BitRC2-CBC
+	if _, err := pbeDecrypt(rc2, make([]byte, 16), "hunter2"); err == nil || !strings.Contains(err.Error(), "unsupported encryption algorithm") {
+		t.Fatalf("expected an unsupported algorithm error, got %v", err)
+	}
+	params, _ := asn1.Marshal(pbeParams{Salt: []byte("saltsalt"), Iterations: 0})
+	zero := algorithmIdentifier{Algorithm: oidPBEWithSHAAnd3DESCBC, Parameters: asn1.RawValue{FullBytes: params}}
+	if _, err := pbeDecrypt(zero, make([]byte, 16), "hunter2"); err == nil || !strings.Contains(err.Error(), "unsupported iteration count") {
+		t.Fatalf("expected an iteration count error, got %v", err)
+	}
+}
+
+func TestPBKDF2Key(t *testing.T) {
+	// RFC 6070 test vector 2
+	key := pbkdf2Key(sha1.New, []byte("password"), []byte("salt"), 2, 20)
+	if got := hex.EncodeToString(key); got != "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957" {
+		t.Fatalf("bad key: %v", got)
+	}
+}
+
+func FuzzDecodePKCS12(f *testing.F) {
+	for _, b64 := range []string{testPKCS12AES, testPKCS12DES} {
+		f.Add(testPKCS12Bundle(f, b64, nil), "hunter2")
+	}
+	f.Add([]byte("not a bundle"), "")
+	f.Fuzz(func(t *testing.T, pfx []byte, password string) {
+		cert, err := decodePKCS12(pfx, password)
+		if err == nil && (len(cert.Certificate) == 0 || cert.PrivateKey == nil) {
+			t.Fatalf("decoded a bundle without a certificate and key: %+v", cert)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package pvc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NewSecretsClientForEnv returns a SecretsClient configured with the options of the profile for env (eg, the value of
+// an APP_ENV environment variable), so that one call can select eg a JSON file in development and Vault in production:
+//
+//	sc, err := pvc.NewSecretsClientForEnv(os.Getenv("APP_ENV"), map[string][]pvc.SecretsClientOption{
+//		"development": {pvc.WithJSONFileBackend(), pvc.WithJSONFileLocation("secrets.json")},
+//		"production":  {pvc.WithVaultBackend(), pvc.WithVaultHost("https://vault:8200"), ...},
+//	})
+//
+// It returns an error if there is no profile for env.
+func NewSecretsClientForEnv(env string, profiles map[string][]SecretsClientOption) (*SecretsClient, error) {
+	ops, ok := profiles[env]
+	if !ok {
+		envs := make([]string, 0, len(profiles))
+		for e := range profiles {
+			envs = append(envs, fmt.Sprintf("%q", e))
+		}
+		sort.Strings(envs)
+		return nil, fmt.Errorf("no profile for environment %q (profiles: %v)", env, strings.Join(envs, ", "))
+	}
+	sc, err := NewSecretsClient(ops...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client for environment %q: %w", env, err)
+	}
+	return sc, nil
+}
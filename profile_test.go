@@ -0,0 +1,42 @@
+package pvc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testProfiles() map[string][]SecretsClientOption {
+	return map[string][]SecretsClientOption{
+		"development": {WithJSONFileBackend(), WithJSONFileLocation("example/secrets.json")},
+		"test":        {WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "from memory"})},
+		"broken":      {},
+	}
+}
+
+func TestNewSecretsClientForEnv(t *testing.T) {
+	for env, backend := range map[string]string{"development": jsonFileBackendName, "test": envVarBackendName} {
+		sc, err := NewSecretsClientForEnv(env, testProfiles())
+		if err != nil {
+			t.Fatalf("error getting SecretsClient for %v: %v", env, err)
+		}
+		r, err := sc.GetDetailed("foo")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if r.Backend != backend {
+			t.Fatalf("%v profile should use the %v backend: %v", env, backend, r.Backend)
+		}
+	}
+}
+
+func TestNewSecretsClientForEnvUnknown(t *testing.T) {
+	_, err := NewSecretsClientForEnv("staging", testProfiles())
+	if err == nil || !strings.Contains(err.Error(), `"staging"`) || !strings.Contains(err.Error(), `"development", "test"`) {
+		t.Fatalf("should have failed naming the environment and profiles: %v", err)
+	}
+	_, err = NewSecretsClientForEnv("broken", testProfiles())
+	if !errors.Is(err, ErrNoBackendConfigured) {
+		t.Fatalf("should have returned ErrNoBackendConfigured: %v", err)
+	}
+}
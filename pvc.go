@@ -2,244 +2,2097 @@ package pvc
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+)
+
+// Version is the version of this library, reported in the User-Agent of Vault requests
+const Version = "0.1.0"
+
+// Errors that may be returned (possibly wrapped) when retrieving secrets
+var (
+	ErrSecretNotFound           = errors.New("secret not found")
+	ErrTimeout                  = errors.New("timed out retrieving secret")
+	ErrNotSupported             = errors.New("operation not supported by backend")
+	ErrDryRun                   = errors.New("dry run: secret was not fetched")
+	ErrIDNotAllowed             = errors.New("secret ID not allowed")
+	ErrIntegrityCheckFailed     = errors.New("secret failed integrity check")
+	ErrNoRoute                  = errors.New("no route for secret ID")
+	ErrInsufficientCapabilities = errors.New("Vault token lacks the capabilities required")
+	ErrVaultSealed              = errors.New("Vault is sealed")
+	ErrReadOnly                 = errors.New("client is read-only")
+	ErrCannotReconfigureBackend = errors.New("option can't be changed after the client is created")
+	ErrIncorrectPassword        = errors.New("incorrect password")
+)
+
+// SecretError is the error returned when retrieving a secret fails. Err is the underlying error, so the errors above
+// can still be matched with errors.Is.
+type SecretError struct {
+	ID      string // ID of the secret, including any prefix (see Scoped)
+	Backend string // name of the backend, eg "vault"
+	Err     error
+
+	redact func(string) string // applied to the message of Err (see WithErrorRedaction)
+}
+
+func (e *SecretError) Error() string {
+	msg := e.Err.Error()
+	if e.redact != nil {
+		msg = e.redact(msg)
+	}
+	return fmt.Sprintf("error getting secret %v from %v backend: %v", e.ID, e.Backend, msg)
+}
+
+func (e *SecretError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returned by NewSecretsClient when the wrong number of backends are enabled
+var (
+	ErrNoBackendConfigured        = errors.New("exactly one backend must be enabled, but none were")
+	ErrMultipleBackendsConfigured = errors.New("exactly one backend must be enabled, but multiple were")
+)
+
+// Names of the backends, as reported in errors and audit events
+const (
+	vaultBackendName       = "vault"
+	envVarBackendName      = "envvar"
+	jsonFileBackendName    = "jsonfile"
+	federatedBackendName   = "federated"
+	onePasswordBackendName = "1password"
+	conjurBackendName      = "conjur"
+	infisicalBackendName   = "infisical"
+	dopplerBackendName     = "doppler"
+	ssmBackendName         = "ssm"
+	dockerBackendName      = "docker"
+	noopBackendName        = "noop"
+	memoryBackendName      = "memory"
+	customBackendName      = "custom"
+	routerBackendName      = "router"
 )
 
 // SecretsClient is the client that retrieves secret values
 type SecretsClient struct {
-	backend secretBackend
+	backend     Backend
+	backendName string
+	timeout     *atomicDuration // may be changed by Reconfigure
+	auditSink   func(AuditEvent)
+	dryRun      bool
+	prefix      string // prepended to every secret ID
+	cache       *secretCache
+	allowedIDs  map[string]struct{} // if not empty, the only IDs (including prefix) that may be retrieved
+	fetched     *idSet              // IDs (including prefix) retrieved successfully, for Masker
+	shared      SharedCache         // checked after cache, if set
+	missing     MissingSecretPolicy
+	flights     *flightGroup // if set, concurrent fetches of the same ID are collapsed
+	integrity   *integrityCheck
+	slots       chan struct{} // if set, holds a value for every backend call in flight (see WithMaxConcurrency)
+	metrics     *metrics
+	readOnly    bool
+	hashIDs     bool
+	idSalt      []byte
+	envOverride bool
+	redact      func(string) string // if set, applied to backend error messages (see WithErrorRedaction)
+	shadow      *SecretsClient      // if set, values fetched are compared with its (see WithShadowBackend)
+	onMismatch  func(ShadowMismatch)
+	health      *health // outcome of the latest backend calls (see Healthy)
+}
+
+// Get returns the value of a secret from the configured backend
+func (sc *SecretsClient) Get(id string) ([]byte, error) {
+	return sc.GetContext(context.Background(), id)
+}
+
+// GetContext returns the value of a secret from the configured backend, giving up when ctx is done.
+// If a timeout was configured with WithTimeout it is applied on top of any deadline ctx already has.
+func (sc *SecretsClient) GetContext(ctx context.Context, id string) ([]byte, error) {
+	r, err := sc.GetDetailedContext(ctx, id)
+	return r.Value, err
+}
+
+// Result is a secret value along with where it came from
+type Result struct {
+	Value        []byte
+	Backend      string // name of the backend that served the value, eg "vault"
+	ResolvedPath string // location of the secret in the backend (if the backend supports it), eg a Vault path
+	FromCache    bool   // whether the value was served from the cache (see WithCache)
+	Stale        bool   // whether the value was an expired cache entry served because the backend failed (see WithServeStaleOnError)
+}
+
+// GetDetailed returns the value of a secret along with its provenance
+func (sc *SecretsClient) GetDetailed(id string) (Result, error) {
+	return sc.GetDetailedContext(context.Background(), id)
+}
+
+// GetDetailedContext is GetDetailed with a context (see GetContext)
+func (sc *SecretsClient) GetDetailedContext(ctx context.Context, id string) (Result, error) {
+	if sc.metrics == nil {
+		return sc.getDetailed(ctx, id)
+	}
+	start := time.Now()
+	r, err := sc.getDetailed(ctx, id)
+	sc.metrics.observe(r.Backend, err, time.Since(start))
+	return r, err
+}
+
+func (sc *SecretsClient) getDetailed(ctx context.Context, id string) (Result, error) {
+	id = sc.prefix + id
+	r := Result{Backend: sc.backendFor(id)}
+	if err := sc.checkAllowed(id); err != nil {
+		sc.audit(ctx, id, nil, err)
+		return r, &SecretError{ID: id, Backend: r.Backend, Err: err, redact: sc.redact}
+	}
+	r.ResolvedPath = sc.resolve(id)
+	if sc.dryRun {
+		v, err := sc.locate(id)
+		r.Value = v
+		return r, err
+	}
+	if sc.envOverride {
+		if name, v, ok := sc.lookupOverride(id, r.ResolvedPath); ok {
+			r.Backend, r.ResolvedPath = envVarBackendName, name
+			return sc.finish(ctx, r, id, v, nil)
+		}
+	}
+	if timeout := sc.getTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if _, ok := namespaceFromContext(ctx); ok {
+		// the cache and in-flight fetches are keyed by ID alone, so can't be shared across namespaces
+		v, _, err := sc.fetch(ctx, id)
+		return sc.finish(ctx, r, id, v, err)
+	}
+	if sc.cache != nil {
+		if v, ok := sc.cache.get(id); ok {
+			sc.audit(ctx, id, v, nil)
+			r.Value, r.FromCache = v, true
+			return r, nil
+		}
+	}
+	if sc.shared != nil {
+		if v, ok := sc.getShared(ctx, id, r.ResolvedPath); ok {
+			// it may have been fetched by another process, so this client hasn't seen it yet
+			r.FromCache = true
+			return sc.finish(ctx, r, id, v, nil)
+		}
+	}
+	var v []byte
+	var ttl time.Duration
+	var err error
+	if sc.flights != nil {
+		v, ttl, err = sc.flights.do(ctx, id, func() ([]byte, time.Duration, error) {
+			return sc.fetch(ctx, id)
+		})
+	} else {
+		v, ttl, err = sc.fetch(ctx, id)
+	}
+	if err != nil && sc.cache != nil && !errors.Is(err, ErrSecretNotFound) {
+		if sv, ok := sc.cache.getStale(id); ok {
+			sc.audit(ctx, id, sv, nil)
+			r.Value, r.FromCache, r.Stale = sv, true, true
+			return r, nil
+		}
+	}
+	if err == nil {
+		if sc.cache != nil {
+			sc.cache.set(id, v, ttl)
+		}
+		if sc.shared != nil {
+			sc.setShared(ctx, id, r.ResolvedPath, v, ttl)
+		}
+		if sc.shadow != nil {
+			sc.shadowRead(id, v)
+		}
+	}
+	return sc.finish(ctx, r, id, v, err)
+}
+
+// finish completes the Get of id, which returned v and err from the backend, with the result r
+func (sc *SecretsClient) finish(ctx context.Context, r Result, id string, v []byte, err error) (Result, error) {
+	if err == nil {
+		sc.fetched.add(id)
+	}
+	sc.audit(ctx, id, v, err)
+	if errors.Is(err, ErrSecretNotFound) {
+		switch sc.missing {
+		case PolicyEmpty:
+			r.Value = []byte{}
+			return r, nil
+		case PolicyPanic:
+			panic(fmt.Errorf("pvc: required secret %v: %w", id, err))
+		}
+	}
+	r.Value = v
+	if err != nil {
+		return r, &SecretError{ID: id, Backend: r.Backend, Err: err, redact: sc.redact}
+	}
+	return r, nil
+}
+
+// DetectCollisions maps each of ids to its location in the backend and returns the locations that more than one ID
+// maps to (eg, "db-password" and "db_password" both map to the env var SECRET_DB_PASSWORD), with the IDs mapping to
+// each, so that aliasing can be caught at deployment time. Backends that can't report locations return ErrNotSupported.
+func (sc *SecretsClient) DetectCollisions(ids []string) (map[string][]string, error) {
+	sl, ok := sc.backend.(secretLocator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	byLoc := map[string][]string{}
+	for _, id := range ids {
+		loc, err := sl.locate(sc.prefix + id)
+		if err != nil {
+			return nil, sc.redactError(fmt.Errorf("error mapping %v: %v", id, err))
+		}
+		byLoc[loc] = append(byLoc[loc], id)
+	}
+	collisions := map[string][]string{}
+	for loc, locIDs := range byLoc {
+		if len(locIDs) > 1 {
+			sort.Strings(locIDs)
+			collisions[loc] = locIDs
+		}
+	}
+	return collisions, nil
+}
+
+// resolve returns the backend location of id, or "" if the backend can't report it
+func (sc *SecretsClient) resolve(id string) string {
+	sl, ok := sc.backend.(secretLocator)
+	if !ok {
+		return ""
+	}
+	loc, _ := sl.locate(id)
+	return loc
+}
+
+// lookupOverride returns the name and value of the environment variable overriding id, whose location in the backend
+// is loc (if known), and whether it is set (see WithEnvOverride)
+func (sc *SecretsClient) lookupOverride(id, loc string) (string, []byte, bool) {
+	if loc == "" {
+		loc = id
+	}
+	name := envVarName(loc)
+	v, ok := os.LookupEnv(name)
+	return name, []byte(v), ok
+}
+
+// hashID returns id as it appears in audit events and cache keys, which is hashed if WithIDHashing was given
+func (sc *SecretsClient) hashID(id string) string {
+	if !sc.hashIDs {
+		return id
+	}
+	return sha256Hex(append(append([]byte(nil), sc.idSalt...), id...))
+}
+
+// locate returns the backend location of id along with ErrDryRun
+func (sc *SecretsClient) locate(id string) ([]byte, error) {
+	sl, ok := sc.backend.(secretLocator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	loc, err := sl.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(loc), ErrDryRun
+}
+
+// GetFields returns every field of a multi-field secret as a map of field name to value. Only the Vault backend supports this, others return ErrNotSupported.
+func (sc *SecretsClient) GetFields(id string) (map[string][]byte, error) {
+	fb, ok := sc.backend.(fieldsSecretBackend)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	if err := sc.checkAllowed(sc.prefix + id); err != nil {
+		return nil, err
+	}
+	release, err := sc.acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	fields, err := fb.GetFields(sc.prefix + id)
+	sc.audit(context.Background(), sc.prefix+id, nil, err)
+	if err != nil {
+		return nil, sc.redactError(err)
+	}
+	// the fields can't be fetched again by ID, so Masker is given their values
+	for _, v := range fields {
+		sc.fetched.addValue(v)
+	}
+	return fields, nil
+}
+
+// Set stores value as the secret id, eg to use the JSON file backend as a simple read/write store in tests and tools.
+// Only the JSON file backend (without flattening or pointers) supports this, others return ErrNotSupported.
+// If caching is enabled, any cached value for id is replaced. A client created with WithReadOnly returns ErrReadOnly.
+func (sc *SecretsClient) Set(id string, value []byte) error {
+	if sc.readOnly {
+		return ErrReadOnly
+	}
+	wb, ok := sc.backend.(writableSecretBackend)
+	if !ok {
+		return ErrNotSupported
+	}
+	id = sc.prefix + id
+	if err := sc.checkAllowed(id); err != nil {
+		return err
+	}
+	if err := wb.Set(id, value); err != nil {
+		return sc.redactError(err)
+	}
+	if sc.cache != nil {
+		sc.cache.set(id, value, 0)
+	}
+	if sc.shared != nil {
+		sc.shared.Delete(context.Background(), sc.sharedKey(id, sc.resolve(id)))
+	}
+	return nil
+}
+
+// GetAll returns every secret the backend holds, keyed by ID. Only the environment variable backend with
+// WithEnvVarPrefix and the Doppler backend support this, others return ErrNotSupported. If WithAllowedIDs was used, only allowed secrets are returned.
+func (sc *SecretsClient) GetAll() (map[string][]byte, error) {
+	lb, ok := sc.backend.(listSecretBackend)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	all, err := lb.GetAll()
+	if err != nil {
+		return nil, sc.redactError(err)
+	}
+	for id, v := range all {
+		if sc.checkAllowed(id) != nil {
+			delete(all, id)
+			continue
+		}
+		// the IDs aren't necessarily ones Get can fetch (see WithEnvVarPrefix), so Masker is given the values
+		sc.fetched.addValue(v)
+		sc.audit(context.Background(), id, v, nil)
+	}
+	return all, nil
+}
+
+// GetGroup returns every secret whose ID starts with prefix, keyed by the rest of the ID, so that with the default
+// mapping GetGroup("DB_") returns the values of SECRET_DB_HOST, SECRET_DB_PORT, etc keyed by HOST, PORT, etc.
+// Only the environment variable backend supports this, others return ErrNotSupported. If WithAllowedIDs was used,
+// only allowed secrets are returned.
+func (sc *SecretsClient) GetGroup(prefix string) (map[string][]byte, error) {
+	gb, ok := sc.backend.(groupSecretBackend)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	release, err := sc.acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	group, err := gb.GetGroup(sc.prefix + prefix)
+	release()
+	if err != nil {
+		return nil, sc.redactError(err)
+	}
+	for k, v := range group {
+		if sc.checkAllowed(sc.prefix+prefix+k) != nil {
+			delete(group, k)
+			continue
+		}
+		sc.fetched.add(sc.prefix + prefix + k)
+		sc.audit(context.Background(), sc.prefix+prefix+k, v, nil)
+	}
+	return group, nil
+}
+
+// List returns the sorted IDs of every secret the backend holds (see GetAll)
+func (sc *SecretsClient) List() ([]string, error) {
+	all, err := sc.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// checkAllowed returns ErrIDNotAllowed if an allowlist was configured with WithAllowedIDs and id isn't in it
+func (sc *SecretsClient) checkAllowed(id string) error {
+	if len(sc.allowedIDs) == 0 {
+		return nil
+	}
+	if _, ok := sc.allowedIDs[id]; !ok {
+		return fmt.Errorf("%w: %v", ErrIDNotAllowed, id)
+	}
+	return nil
+}
+
+// Scoped returns a client that prepends prefix to every secret ID before it is mapped, so that
+// Scoped("myapp/").Get("password") is equivalent to Get("myapp/password"). The scoped client shares
+// the backend (and its authentication) of the parent. Scoping a scoped client appends to its prefix.
+func (sc *SecretsClient) Scoped(prefix string) *SecretsClient {
+	scoped := *sc
+	scoped.prefix = sc.prefix + prefix
+	scoped.timeout = newAtomicDuration(sc.getTimeout())
+	return &scoped
+}
+
+// fetch gets id from the backend, verifying it if an integrity check is configured
+func (sc *SecretsClient) fetch(ctx context.Context, id string) ([]byte, time.Duration, error) {
+	v, ttl, err := sc.getFromBackend(ctx, id)
+	sc.health.record(err)
+	if err != nil || sc.integrity == nil {
+		return v, ttl, err
+	}
+	if err := sc.verifyIntegrity(ctx, id, v); err != nil {
+		return nil, 0, err
+	}
+	return v, ttl, nil
+}
+
+// getFromBackend calls the backend, using its context-aware Get if it has one. If the backend suggests how long
+// the value may be cached for, that is returned as well (see ttlSecretBackend).
+func (sc *SecretsClient) getFromBackend(ctx context.Context, id string) ([]byte, time.Duration, error) {
+	release, err := sc.acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	var v []byte
+	var ttl time.Duration
+	switch b := sc.backend.(type) {
+	case ttlSecretBackend:
+		v, ttl, err = b.getWithTTL(ctx, id)
+		release()
+	case ContextBackend:
+		v, err = b.GetContext(ctx, id)
+		release()
+	default:
+		type result struct {
+			value []byte
+			err   error
+		}
+		rc := make(chan result, 1)
+		go func() {
+			// the slot is held until Get returns, even if it is abandoned
+			v, err := sc.backend.Get(id)
+			release()
+			rc <- result{value: v, err: err}
+		}()
+		select {
+		case r := <-rc:
+			return r.value, 0, r.err
+		case <-ctx.Done():
+			return nil, 0, contextError(ctx)
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, 0, contextError(ctx)
+	}
+	return v, ttl, err
+}
+
+// acquire waits for a free slot if WithMaxConcurrency was used, returning the function that frees it
+func (sc *SecretsClient) acquire(ctx context.Context) (func(), error) {
+	if sc.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case sc.slots <- struct{}{}:
+		return func() { <-sc.slots }, nil
+	case <-ctx.Done():
+		return nil, contextError(ctx)
+	}
+}
+
+// contextError returns the error for a Get abandoned because ctx is done
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
+
+// Backend is a source of secret values. Besides the built-in backends, any implementation may be used with
+// NewSecretsClientWithBackend, eg a decorator adding logging around another Backend or a SecretsClient.
+type Backend interface {
+	// Get returns the value of the secret id, or an error wrapping ErrSecretNotFound if it doesn't exist
+	Get(id string) ([]byte, error)
+}
+
+// ContextBackend is a Backend that can abort a Get itself when ctx is done. Backends that don't implement it are
+// abandoned when the context of a Get is done, but left running.
+type ContextBackend interface {
+	Backend
+	GetContext(ctx context.Context, id string) ([]byte, error)
+}
+
+// ttlSecretBackend is implemented by context-aware backends that can suggest how long a value may be cached for.
+// A zero TTL means no suggestion was made, a negative one that the value should not be cached.
+type ttlSecretBackend interface {
+	getWithTTL(ctx context.Context, id string) ([]byte, time.Duration, error)
+}
+
+// secretLocator is implemented by backends that can report where a secret ID is stored without fetching it
+type secretLocator interface {
+	locate(id string) (string, error)
+}
+
+// fieldsSecretBackend is implemented by backends that store multiple named fields per secret
+type fieldsSecretBackend interface {
+	GetFields(id string) (map[string][]byte, error)
+}
+
+// writableSecretBackend is implemented by backends that can store secrets
+type writableSecretBackend interface {
+	Set(id string, value []byte) error
+}
+
+// listSecretBackend is implemented by backends that can enumerate the secrets they hold
+type listSecretBackend interface {
+	GetAll() (map[string][]byte, error)
+}
+
+// groupSecretBackend is implemented by backends that can return every secret under a prefix at once
+type groupSecretBackend interface {
+	GetGroup(prefix string) (map[string][]byte, error)
+}
+
+// SecretDefinition defines a secret and how it can be accessed via the various backends
+type SecretDefinition struct {
+	ID         string // arbitrary identifier for this secret
+	VaultPath  string // path in Vault (no leading slash, eg "secret/foo/bar")
+	EnvVarName string // environment variable name
+	JSONKey    string // key in JSON object
+}
+
+type vaultBackend struct {
+	host                string
+	hosts               []string // if more than one, requests are spread across them (see WithVaultHosts)
+	authentication      VaultAuthentication
+	authRetries         uint
+	authRetryDelaySecs  uint
+	readRetries         uint64        // accessed atomically, as Reconfigure may change it
+	readRetryDelay      time.Duration // likewise
+	readRetriesSet      bool          // whether WithVaultReadRetries was given (see Reconfigure)
+	readRetryDelaySet   bool          // likewise for WithVaultReadRetryDelay
+	retryableStatuses   []int
+	retryableError      func(error) bool
+	transitMount        string
+	capabilityPrecheck  bool
+	tokenLookupCacheTTL time.Duration
+	now                 func() time.Time
+	minTLSVersion       uint16
+	traceHeaders        func(ctx context.Context, h http.Header)
+	forwardToActive     bool
+	namespace           string
+	dial                func(ctx context.Context, network, addr string) (net.Conn, error)
+	connPool            bool // keep connections open for reuse (see WithVaultConnectionPool)
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	agentTokenSink      string
+	jwt                 string
+	jwtPath             string
+	jwtRole             string
+	jwtAuthPath         string
+	jwtAudience         string
+	githubOIDC          bool // get the JWT from the GitHub Actions ID token endpoint
+	githubOIDCAudience  string
+	notFound            func(status int, body []byte) bool
+	warningHandler      func(id string, warnings []string)
+	userAgent           string
+	token               string
+	k8sjwt              string
+	k8sauthpath         string
+	k8sTokenPath        string
+	k8sTokenAudience    string
+	appid               string
+	userid              string
+	useridpath          string
+	roleid              string
+	mapping             string
+	mappingRules        []MappingRule
+}
+
+type envVarBackend struct {
+	mapping         string
+	mappingRules    []MappingRule
+	env             map[string]string
+	caseInsensitive bool
+	prefix          string
+	decryptor       func([]byte) ([]byte, error)
+	expand          bool // replace ${VAR} and $VAR in values (see WithEnvVarExpansion)
+	expandStrict    bool // fail if a referenced variable is unset
+}
+
+type jsonFileBackend struct {
+	fileLocation     string
+	mapping          string
+	mappingRules     []MappingRule
+	flatten          bool
+	flattenSeparator string
+	pointer          bool
+	persist          bool
+	reader           io.Reader
+	sops             bool
+	sopsKeyFile      string
+	caseInsensitive  bool
+	autoUnescape     bool
+}
+
+type federatedBackend struct {
+	fileLocation string
+	reader       io.Reader
+	mapping      string
+	mappingRules []MappingRule
+}
+
+type onePasswordBackend struct {
+	host         string
+	token        string
+	vaultID      string
+	field        string
+	mapping      string
+	mappingRules []MappingRule
+	notFound     func(status int, body []byte) bool
+}
+
+type conjurBackend struct {
+	applianceURL string
+	account      string
+	login        string
+	apiKey       string
+	mapping      string
+	mappingRules []MappingRule
+	notFound     func(status int, body []byte) bool
+}
+
+type infisicalBackend struct {
+	host         string
+	token        string
+	serviceToken string
+	projectID    string
+	environment  string
+	mapping      string
+	mappingRules []MappingRule
+	notFound     func(status int, body []byte) bool
+}
+
+type dopplerBackend struct {
+	host         string
+	token        string
+	project      string
+	config       string
+	mapping      string
+	mappingRules []MappingRule
+	notFound     func(status int, body []byte) bool
+}
+
+type ssmBackend struct {
+	region       string
+	skipDecrypt  bool
+	mapping      string
+	mappingRules []MappingRule
+	client       ssmIO
+}
+
+type dockerSecretsBackend struct {
+	dir          string
+	mapping      string
+	mappingRules []MappingRule
+}
+
+type noopBackend struct {
+	value []byte
+}
+
+type secretsClientConfig struct {
+	mapping            string
+	mappingRules       []MappingRule
+	selfTestIDs        []string
+	timeout            time.Duration
+	timeoutSet         bool // whether WithTimeout was given, so that Reconfigure can tell a zero timeout from none
+	maxConcurrency     int
+	metrics            bool
+	readOnly           bool
+	hashIDs            bool
+	idSalt             []byte
+	envOverride        bool
+	redact             func(string) string
+	shadowOps          []SecretsClientOption
+	shadowMismatch     func(ShadowMismatch)
+	now                func() time.Time // clock for TTLs, time.Now unless replaced in tests
+	auditSink          func(AuditEvent)
+	dryRun             bool
+	missing            MissingSecretPolicy
+	integrity          *integrityCheck
+	routes             []route
+	caseInsensitive    bool
+	notFound           func(status int, body []byte) bool
+	cacheTTL           time.Duration
+	cacheTTLSet        bool // likewise for WithCache
+	cacheTTLs          map[string]time.Duration
+	cacheMaxEntries    int
+	sharedCache        SharedCache
+	maxStale           time.Duration
+	allowedIDs         []string
+	enabledBackends    []string
+	vaultBackend       *vaultBackend
+	envVarBackend      *envVarBackend
+	jsonFileBackend    *jsonFileBackend
+	federatedBackend   *federatedBackend
+	onePasswordBackend *onePasswordBackend
+	conjurBackend      *conjurBackend
+	infisicalBackend   *infisicalBackend
+	dopplerBackend     *dopplerBackend
+	ssmBackend         *ssmBackend
+	dockerBackend      *dockerSecretsBackend
+	noopBackend        *noopBackend
+	memoryBackend      *MemoryBackend
+	customBackend      Backend
+}
+
+// SecretsClientOption defines options when creating a SecretsClient
+type SecretsClientOption func(*secretsClientConfig)
+
+// WithMapping sets the template string mapping to determine the location for each secret in the backend. The secret ID will be interpolated as ".ID".
+// Example (Vault Backend): "secret/foo/bar/{{ .ID }}".
+// Example (Env Var Backend): "MYAPP_SECRET_{{ .ID }}"
+// Example (JSON Backend): "{{ .ID }}"
+func WithMapping(mapping string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.mapping = mapping
+	}
+}
+
+// MappingRule maps the secret IDs matching the regular expression Match (eg, "^db/") with Template instead of the
+// mapping set with WithMapping (see WithMappingRules). Use ^ and $ to match the whole ID.
+type MappingRule struct {
+	Match    string
+	Template string
+}
+
+// WithMappingRules sets mappings for IDs matching particular patterns, eg because they are under a different Vault
+// mount. The Template of the first rule whose Match matches the ID is used, falling back to the mapping set with
+// WithMapping (or the backend's default mapping). May be used more than once, adding rules after those already set.
+func WithMappingRules(rules []MappingRule) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.mappingRules = append(s.mappingRules, rules...)
+	}
+}
+
+// WithMappingSelfTest makes NewSecretsClient render the mapping (and mapping rules) for each of ids, failing if any
+// don't render or render to an empty location. This catches mapping mistakes at startup without contacting the backend.
+func WithMappingSelfTest(ids ...string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.selfTestIDs = append(s.selfTestIDs, ids...)
+	}
+}
+
+// mappingSelfTest returns an error listing each of ids that mapping and rules don't map to a location
+func mappingSelfTest(mapping string, rules []MappingRule, ids []string) error {
+	if mapping == "" {
+		// the default mappings of all backends interpolate the ID and nothing else
+		mapping = "{{ .ID }}"
+	}
+	sm, err := newSecretMapper(mapping, rules...)
+	if err != nil {
+		return fmt.Errorf("error with mapping: %v", err)
+	}
+	failures := []string{}
+	for _, id := range ids {
+		loc, err := sm.MapSecret(id)
+		switch {
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("%v: %v", id, err))
+		case strings.TrimSpace(loc) == "":
+			failures = append(failures, fmt.Sprintf("%v: mapped to an empty location", id))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("mapping self-test failed for %v of %v IDs: %v", len(failures), len(ids), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// WithTimeout sets the maximum duration of every Get regardless of backend (default: no timeout). Get returns ErrTimeout when it is exceeded.
+func WithTimeout(d time.Duration) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.timeout = d
+		s.timeoutSet = true
+	}
+}
+
+// WithMaxConcurrency limits the number of backend calls in flight at once to n, so that GetBatch (or many concurrent
+// Gets) can't exhaust the backend's connections. The limit is shared by every call on the client, including
+// GetFields, GetGroup and simultaneous batches, and by clients derived from it with Scoped. Calls wait for a free
+// slot, giving up when their context is done.
+func WithMaxConcurrency(n int) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.maxConcurrency = n
+	}
+}
+
+// WithReadOnly makes every method that would modify the backend (see Set) return ErrReadOnly without contacting it,
+// as a guarantee that the client never changes the secrets it reads
+func WithReadOnly() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.readOnly = true
+	}
+}
+
+// WithMetrics enables counting of Gets and their durations, exposed by MetricsHandler
+func WithMetrics() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.metrics = true
+	}
+}
+
+// WithEnvOverride makes Get return the value of an environment variable, if it is set, instead of consulting the
+// backend, eg to override a Vault secret while debugging locally. The variable name is the location of the secret in
+// the backend uppercased, with characters not allowed in a variable name replaced by underscores (eg, the default
+// Vault mapping makes "foo" SECRET_FOO). Overridden values aren't cached.
+func WithEnvOverride() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.envOverride = true
+	}
+}
+
+// WithErrorRedaction passes the messages of errors from backends through redact before they are returned, eg to
+// keep Vault paths or response bodies that may contain sensitive data out of logs. A nil redact uses
+// DefaultErrorRedactor. Errors still unwrap to the original errors, so errors.Is works as before.
+func WithErrorRedaction(redact func(string) string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if redact == nil {
+			redact = DefaultErrorRedactor
+		}
+		s.redact = redact
+	}
+}
+
+// WithIDHashing replaces secret IDs in audit events, in-memory cache keys and shared cache keys (see WithSharedCache)
+// with the hex SHA-256 of salt followed by the ID, for when IDs themselves are sensitive (eg, they contain customer
+// names). The backend is still asked for the real ID.
+func WithIDHashing(salt []byte) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.hashIDs = true
+		s.idSalt = salt
+	}
+}
+
+// WithAuditSink sets a function that is called with an AuditEvent after every Get, successful or not.
+// Secret values are never included in the event. The sink is called synchronously so it should not block.
+func WithAuditSink(sink func(AuditEvent)) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.auditSink = sink
+	}
+}
+
+// WithDryRun prevents Get from contacting the backend. Instead it returns the location the secret ID maps to (eg, the Vault path or env var name)
+// along with ErrDryRun, so that mappings can be validated without access to the secrets.
+func WithDryRun() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.dryRun = true
+	}
+}
+
+// MissingSecretPolicy is what Get does when the backend reports that a secret doesn't exist (see WithMissingSecretPolicy)
+type MissingSecretPolicy int
+
+// Missing secret policies
+const (
+	PolicyError MissingSecretPolicy = iota // return an error wrapping ErrSecretNotFound
+	PolicyEmpty                            // return an empty value and no error
+	PolicyPanic                            // panic, eg for startup code that can't continue without its secrets
+)
+
+// WithMissingSecretPolicy sets what Get does when a secret doesn't exist (default: PolicyError). The audit event
+// still records the secret as not found.
+func WithMissingSecretPolicy(policy MissingSecretPolicy) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.missing = policy
+	}
+}
+
+// WithIntegrityCheck makes Get verify each secret against an HMAC stored alongside it in the backend as the secret whose
+// ID has sidecarSuffix appended (eg, "db_password.hmac" for "db_password" with the suffix ".hmac"). The sidecar must hold the
+// hex-encoded HMAC-SHA256 of the value with key. If it doesn't match, or is missing, ErrIntegrityCheckFailed is returned.
+func WithIntegrityCheck(key []byte, sidecarSuffix string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.integrity = &integrityCheck{key: append([]byte(nil), key...), suffix: sidecarSuffix}
+	}
+}
+
+// WithCaseInsensitiveKeys makes the env var and JSON file backends fall back to a case-insensitive match when no
+// variable or key exactly matches the mapped secret ID. If several variables or keys differ only by case, an exact
+// match is preferred, otherwise the one that sorts first (eg, "DB_PASSWORD" before "db_password") is used.
+func WithCaseInsensitiveKeys() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.caseInsensitive = true
+	}
+}
+
+// WithNotFoundDetector sets how the Vault, 1Password and Conjur backends decide that a response means the secret
+// doesn't exist, in which case ErrSecretNotFound is returned. detect is called with the HTTP status code and body of
+// every response to a read. The default is DefaultNotFoundDetector.
+func WithNotFoundDetector(detect func(status int, body []byte) bool) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.notFound = detect
+	}
+}
+
+// DefaultNotFoundDetector treats a 404 response as meaning the secret doesn't exist
+func DefaultNotFoundDetector(status int, body []byte) bool {
+	return status == http.StatusNotFound
+}
+
+// WithCache enables caching of secret values in memory for ttl (which must be positive). For the Vault backend,
+// a Cache-Control max-age header or lease duration returned by Vault for a secret takes precedence over ttl.
+func WithCache(ttl time.Duration) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.cacheTTL = ttl
+		s.cacheTTLSet = true
+	}
+}
+
+// WithCacheMaxEntries limits the cache (see WithCache, which is required) to n secrets. When it is full, the least
+// recently used entry is evicted and its value zeroed. Evictions are counted in CacheStats.
+func WithCacheMaxEntries(n int) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.cacheMaxEntries = n
+	}
+}
+
+// WithAllowedIDs restricts the client to retrieving only the secrets ids, any other returns ErrIDNotAllowed without
+// contacting the backend. IDs are matched after any Scoped prefix is applied. Without any ids, all IDs are allowed.
+// May be used more than once to add to the allowlist.
+func WithAllowedIDs(ids ...string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.allowedIDs = append(s.allowedIDs, ids...)
+	}
+}
+
+// WithCacheTTLFor sets the cache TTL for particular secret IDs (including any Scoped prefix), overriding the TTL
+// given to WithCache, which is required. As with WithCache, a TTL suggested by the Vault backend takes precedence.
+// May be used more than once.
+func WithCacheTTLFor(ttls map[string]time.Duration) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.cacheTTLs == nil {
+			s.cacheTTLs = map[string]time.Duration{}
+		}
+		for id, ttl := range ttls {
+			s.cacheTTLs[id] = ttl
+		}
+	}
+}
+
+// WithServeStaleOnError makes Get return an expired cached value when the backend fails (eg, Vault is unavailable),
+// as long as it expired less than maxStale ago. GetDetailed reports such values with Stale set. Errors meaning the
+// secret doesn't exist (ErrSecretNotFound) are still returned. Caching must be enabled with WithCache.
+func WithServeStaleOnError(maxStale time.Duration) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.maxStale = maxStale
+	}
+}
+
+// WithSharedCache adds a cache shared between clients (eg, in Redis) under the in-memory cache enabled by WithCache,
+// which is required. A secret missing from the in-memory cache is looked for in the shared cache before the backend,
+// and a secret fetched from the backend is written to both. See SharedCache for the keys used.
+func WithSharedCache(cache SharedCache) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.sharedCache = cache
+	}
+}
+
+// WithVaultBackend enables the Vault backend.
+func WithVaultBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, vaultBackendName)
+	}
+}
+
+// WithVaultHost sets the Vault server host (eg, https//my.vault.com:8300)
+func WithVaultHost(host string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.host = host
+	}
+}
+
+// WithVaultHosts sets several equivalent Vault server hosts, eg the nodes of an HA cluster. Requests are sent to
+// each in turn, and a host that can't be connected to is skipped for DefaultVaultHostCooldown, the request being
+// retried on the next one. A single host is the same as WithVaultHost.
+func WithVaultHosts(hosts []string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.hosts = hosts
+		if len(hosts) > 0 {
+			s.vaultBackend.host = hosts[0]
+		}
+	}
+}
+
+// WithVaultMinTLSVersion sets the minimum TLS version for connections to Vault (eg, tls.VersionTLS13). Versions older than TLS 1.2 are rejected (default: TLS 1.2).
+func WithVaultMinTLSVersion(version uint16) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.minTLSVersion = version
+	}
+}
+
+// WithTracePropagationHeaders sets a function called with the context of every Vault read (see GetContext) to add
+// trace propagation headers such as traceparent to the request. With OpenTelemetry for example:
+//
+//	pvc.WithTracePropagationHeaders(func(ctx context.Context, h http.Header) {
+//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+//	})
+//
+// The context is also passed to the Vault HTTP client, so instrumented transports see the caller's span.
+func WithTracePropagationHeaders(inject func(ctx context.Context, h http.Header)) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.traceHeaders = inject
+	}
+}
+
+// WithVaultAuthentication sets the Vault authentication method
+func WithVaultAuthentication(auth VaultAuthentication) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.authentication = auth
+	}
+}
+
+// WithVaultAuthRetries sets the number of retries if authentication fails (default: 0)
+func WithVaultAuthRetries(retries uint) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.authRetries = retries
+	}
+}
+
+// WithVaultAuthRetryDelay sets the delay in seconds between authentication attempts (default: 0)
+func WithVaultAuthRetryDelay(secs uint) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.authRetryDelaySecs = secs
+	}
+}
+
+// WithVaultForwardToActive makes secret reads ask a Vault Enterprise performance standby to forward them to the active
+// node (with the X-Vault-Forward header), eg for read-after-write consistency. This adds the latency of the extra hop,
+// so it is off by default.
+func WithVaultForwardToActive() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.forwardToActive = true
+	}
+}
+
+// WithVaultReadRetries sets the number of times a secret read is retried if it fails with a transient error
+// (default: 0). See WithRetryableStatusCodes and WithRetryableErrorFunc for which errors are transient.
+func WithVaultReadRetries(retries uint) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.readRetries = uint64(retries)
+		s.vaultBackend.readRetriesSet = true
+	}
+}
+
+// WithVaultReadRetryDelay sets the delay before the first read retry, which doubles for each subsequent one (default: DefaultVaultReadRetryDelay)
+func WithVaultReadRetryDelay(d time.Duration) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.readRetryDelay = d
+		s.vaultBackend.readRetryDelaySet = true
+	}
+}
+
+// WithRetryableStatusCodes sets the HTTP status codes of Vault responses for which reads are retried (see
+// WithVaultReadRetries), replacing the default of 412 (a performance standby not yet up to date), 429 and 5xx.
+func WithRetryableStatusCodes(codes ...int) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.retryableStatuses = codes
+	}
+}
+
+// WithRetryableErrorFunc sets a function that decides whether a Vault read that failed without a response (eg, the
+// connection was refused) is retried (see WithVaultReadRetries). By default all such errors are retried.
+func WithRetryableErrorFunc(retryable func(error) bool) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.retryableError = retryable
+	}
+}
+
+// WithVaultTransitMount sets the path the transit secrets engine is mounted at, for EncryptTransit (default: DefaultVaultTransitMount)
+func WithVaultTransitMount(mount string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.transitMount = mount
+	}
+}
+
+// WithVaultCapabilityPrecheck makes the first read of each Vault path check that the token has the read capability on
+// it (with sys/capabilities-self), returning ErrInsufficientCapabilities if not rather than Vault's generic permission
+// denied error. The result is cached per path until the token changes, but the first read of each makes two requests.
+func WithVaultCapabilityPrecheck() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.capabilityPrecheck = true
+	}
+}
+
+// WithVaultTokenLookupCacheTTL sets how long the result of a token lookup-self call is reused for token introspection such as VaultTokenTTL (default: 10s)
+func WithVaultTokenLookupCacheTTL(ttl time.Duration) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.tokenLookupCacheTTL = ttl
+	}
+}
+
+// WithVaultToken sets the token to use when using token auth
+func WithVaultToken(token string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.token = token
+	}
+}
+
+// WithVaultAppID sets the AppID to use when using AppID auth
+func WithVaultAppID(appid string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.appid = appid
+	}
+}
+
+// WithVaultK8sAuth sets the Kubernetes JWT and role to use for authentication
+func WithVaultK8sAuth(jwt, role string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.k8sjwt = jwt
+		s.vaultBackend.roleid = role
+		s.vaultBackend.authentication = K8s
+	}
+}
+
+// WithVaultK8sAuthPath sets the path for the k8s Vault auth backend (defaults to "kubernetes" otherwise)
+func WithVaultK8sAuthPath(path string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.k8sauthpath = path
+	}
+}
+
+// WithVaultK8sTokenAudience sets the audience that the token used for Kubernetes authentication must be issued for
+// (see WithVaultJWTAudience for JWT/OIDC authentication). If no JWT is given to WithVaultK8sAuth, the service account
+// token projected for aud is read from DefaultVaultK8sTokenDir/<aud> (or WithVaultK8sTokenPath). Authentication fails
+// before contacting Vault if the token's aud claim doesn't include aud.
+func WithVaultK8sTokenAudience(aud string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.k8sTokenAudience = aud
+	}
+}
+
+// WithVaultK8sTokenPath sets the file to read the service account token from for Kubernetes authentication, when no
+// JWT is given to WithVaultK8sAuth (eg, the path of a projected token volume)
+func WithVaultK8sTokenPath(path string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.k8sTokenPath = path
+	}
+}
+
+// WithVaultJWT sets the JWT to use for JWT/OIDC authentication, and enables it
+func WithVaultJWT(jwt string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.jwt = jwt
+		s.vaultBackend.authentication = JWT
+	}
+}
+
+// WithVaultJWTPath sets a file to read the JWT from for JWT/OIDC authentication (eg, a projected identity token), and enables it
+func WithVaultJWTPath(path string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.jwtPath = path
+		s.vaultBackend.authentication = JWT
+	}
+}
+
+// WithVaultJWTAudience sets the audience that the JWT used for JWT/OIDC authentication (see WithVaultJWT and
+// WithVaultJWTPath) must be issued for. Authentication fails before contacting Vault if the token's aud claim doesn't
+// include aud.
+func WithVaultJWTAudience(aud string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.jwtAudience = aud
+	}
+}
+
+// WithVaultGitHubOIDC enables JWT authentication with role using the OIDC ID token of a GitHub Actions job, which
+// is requested from ACTIONS_ID_TOKEN_REQUEST_URL (the job needs the id-token: write permission). The token is issued
+// for the audience set with WithVaultGitHubOIDCAudience, if any. The mount path defaults to "jwt" as usual.
+func WithVaultGitHubOIDC(role string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.githubOIDC = true
+		s.vaultBackend.jwtRole = role
+		s.vaultBackend.authentication = JWT
+	}
+}
+
+// WithVaultGitHubOIDCAudience sets the audience that the GitHub Actions ID token (see WithVaultGitHubOIDC) is
+// requested for, which should match the bound_audiences of the Vault role. Authentication fails before contacting
+// Vault if the token's aud claim doesn't include aud.
+func WithVaultGitHubOIDCAudience(aud string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.githubOIDCAudience = aud
+	}
+}
+
+// WithVaultJWTRole sets the role to log in with when using JWT/OIDC authentication
+func WithVaultJWTRole(role string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.jwtRole = role
+	}
+}
+
+// WithVaultJWTAuthPath sets the mount path of the JWT auth method (default: "jwt")
+func WithVaultJWTAuthPath(path string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.jwtAuthPath = path
+	}
+}
+
+// WithVaultUserID sets the UserID to use when using AppID auth
+func WithVaultUserID(userid string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.userid = userid
+	}
+}
+
+// WithVaultUserIDPath sets the path to the file containing UserID when using AppID auth
+func WithVaultUserIDPath(useridpath string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.useridpath = useridpath
+	}
+}
+
+// WithVaultAgentTokenSink authenticates with the token Vault Agent's auto-auth writes to the sink file at path,
+// re-reading it if Vault denies a request because the agent has rotated the token
+func WithVaultAgentTokenSink(path string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.authentication = VaultAgent
+		s.vaultBackend.agentTokenSink = path
+	}
+}
+
+// WithVaultWarningHandler sets a function that is called with the warnings of any Vault response that has them
+// (eg, a deprecated path or KV version mismatch), which are otherwise discarded. id is the secret ID, or the
+// Vault path for requests that aren't made with Get (eg, GetFields).
+func WithVaultWarningHandler(handler func(id string, warnings []string)) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.warningHandler = handler
+	}
+}
+
+// WithVaultUserAgent sets the User-Agent of Vault requests to ua followed by the library identifier (eg, "myapp/1.2 pvc/0.1.0"),
+// so that requests can be attributed in Vault audit logs. The default is just the library identifier.
+func WithVaultUserAgent(ua string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.userAgent = ua
+	}
+}
+
+// WithVaultDialContext makes the Vault HTTP client open connections with dial instead of dialing directly, eg to reach
+// a Vault cluster through an SSH tunnel to a bastion by passing the DialContext method of an *ssh.Client
+// (golang.org/x/crypto/ssh). The caller owns the tunnel and must keep it open for the lifetime of the client.
+func WithVaultDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.dial = dial
+	}
+}
+
+// WithVaultConnectionPool keeps connections to Vault open for reuse, which by default are closed after every request,
+// eg to avoid the cost of new connections under load. Up to maxIdle idle connections are kept in total, maxIdlePerHost
+// to each Vault host, each for up to idleTimeout. Zero uses the net/http default for that setting (no limit, 2 and no
+// limit). Negative values make NewSecretsClient fail.
+func WithVaultConnectionPool(maxIdle, maxIdlePerHost int, idleTimeout time.Duration) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.connPool = true
+		s.vaultBackend.maxIdleConns = maxIdle
+		s.vaultBackend.maxIdleConnsPerHost = maxIdlePerHost
+		s.vaultBackend.idleConnTimeout = idleTimeout
+	}
+}
+
+// WithVaultNamespace sets the Vault Enterprise namespace of every request, including authentication (see also
+// WithNamespaceContext)
+func WithVaultNamespace(ns string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.namespace = ns
+	}
+}
+
+// WithVaultRoleID sets the RoleID when using AppRole authentication
+func WithVaultRoleID(roleid string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.vaultBackend == nil {
+			s.vaultBackend = &vaultBackend{}
+		}
+		s.vaultBackend.roleid = roleid
+	}
+}
+
+// WithEnvVarBackend enables the environment variable backend. Any characters in the secret ID that are not alphanumeric ASCII or underscores (legal env var characters) will be replaced by underscores after mapping.
+func WithEnvVarBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.envVarBackend == nil {
+			s.envVarBackend = &envVarBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, envVarBackendName)
+	}
+}
+
+// WithEnvMap makes the environment variable backend read variables from env instead of the process environment.
+func WithEnvMap(env map[string]string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.envVarBackend == nil {
+			s.envVarBackend = &envVarBackend{}
+		}
+		s.envVarBackend.env = env
+	}
+}
+
+// WithEnvVarExpansion makes the environment variable backend replace references to other variables in values, as
+// ${VAR} or $VAR, with their values (eg, "${DB_HOST}:5432"), looking them up in the same environment (see WithEnvMap).
+// Unset variables are replaced with nothing, unless WithEnvVarExpansionStrict is used. Expansion happens after any
+// decryption (see WithEnvVarDecryptor).
+func WithEnvVarExpansion() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.envVarBackend == nil {
+			s.envVarBackend = &envVarBackend{}
+		}
+		s.envVarBackend.expand = true
+	}
+}
+
+// WithEnvVarExpansionStrict is WithEnvVarExpansion, but a value referencing an unset variable fails to be retrieved
+func WithEnvVarExpansionStrict() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.envVarBackend == nil {
+			s.envVarBackend = &envVarBackend{}
+		}
+		s.envVarBackend.expand = true
+		s.envVarBackend.expandStrict = true
+	}
+}
+
+// WithEnvVarDecryptor makes the environment variable backend pass each value through decrypt before returning it, eg
+// for secrets injected by CI encrypted with a deploy key (see HPKEDecryptor). An error from decrypt is returned
+// from the Get.
+func WithEnvVarDecryptor(decrypt func([]byte) ([]byte, error)) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.envVarBackend == nil {
+			s.envVarBackend = &envVarBackend{}
+		}
+		s.envVarBackend.decryptor = decrypt
+	}
+}
+
+// WithEnvVarPrefix enables GetAll and List for the environment variable backend, which return every variable whose
+// name starts with prefix (eg, "APP_SECRET_"), with the prefix stripped from the name to give the ID.
+// Get is unaffected and still uses the mapping.
+func WithEnvVarPrefix(prefix string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.envVarBackend == nil {
+			s.envVarBackend = &envVarBackend{}
+		}
+		s.envVarBackend.prefix = prefix
+	}
+}
+
+// WithJSONFileBackend enables the JSON file backend. The file should contain a single JSON object associating a name with a value: { "mysecret": "pa55w0rd"}.
+func WithJSONFileBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, jsonFileBackendName)
+	}
+}
+
+// WithJSONReader makes the JSON file backend read the JSON object from r (eg, os.Stdin) instead of a file.
+// r is read to the end when the client is created. It can't be combined with WithJSONFileLocation.
+func WithJSONReader(r io.Reader) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.reader = r
+	}
+}
+
+// WithJSONFileLocation sets the location to the JSON file
+func WithJSONFileLocation(loc string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.fileLocation = loc
+	}
+}
+
+// WithJSONFlatten flattens nested objects and arrays in the JSON file into a single level of keys joined by separator (default: ".").
+// For example, {"db": {"password": "x", "hosts": ["a", "b"]}} yields the keys "db.password", "db.hosts.0" and "db.hosts.1".
+func WithJSONFlatten(separator string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.flatten = true
+		s.jsonFileBackend.flattenSeparator = separator
+	}
+}
+
+// WithJSONPointer treats the mapped secret ID as an RFC 6901 JSON Pointer (eg, "/db/hosts/0") into the JSON file,
+// which may then hold any JSON value. Strings are returned as-is, other values in their JSON representation.
+// It can't be combined with WithJSONFlatten.
+func WithJSONPointer() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.pointer = true
+	}
+}
+
+// WithJSONAutoUnescape makes the JSON file backend decode values that are themselves JSON strings (ie, that were
+// encoded twice, like "\"line1\\nline2\"") once, so the intended plaintext is returned. Only quoted strings
+// containing escape sequences are decoded, other values are returned unchanged.
+func WithJSONAutoUnescape() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.autoUnescape = true
+	}
+}
+
+// WithJSONFilePersist makes Set write the JSON file (atomically, by replacing it) as well as updating the values held
+// in memory. Without it, values set are lost when the client is discarded.
+func WithJSONFilePersist() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.persist = true
+	}
+}
+
+// WithSOPSJSONBackend enables the JSON file backend for a SOPS-encrypted file (see WithJSONFileLocation), which is
+// decrypted in memory with the sops command when the client is created. sops must be on the PATH, and finds its keys
+// as usual (eg, from SOPS_AGE_KEY_FILE or the PGP keyring) unless WithSOPSKeyFile is used. Set can't persist the file.
+func WithSOPSJSONBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.sops = true
+		s.enabledBackends = append(s.enabledBackends, jsonFileBackendName)
+	}
 }
 
-// Get returns the value of a secret from the configured backend
-func (sc *SecretsClient) Get(id string) ([]byte, error) {
-	return sc.backend.Get(id)
+// WithSOPSKeyFile sets the age key file used to decrypt the file of the SOPS JSON backend
+func WithSOPSKeyFile(path string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.jsonFileBackend == nil {
+			s.jsonFileBackend = &jsonFileBackend{}
+		}
+		s.jsonFileBackend.sopsKeyFile = path
+	}
 }
 
-type secretBackend interface {
-	Get(id string) ([]byte, error)
+// WithFederatedBackend enables the federated config backend, for a JSON file mixing inline secrets with references to
+// secrets held elsewhere. The file is a JSON object associating a name with an object holding exactly one of
+// "value" (the secret itself), "vault" (the path of a Vault secret) or "env" (the name of an environment variable):
+//
+//	{"api-key": {"value": "abc123"}, "db-password": {"vault": "secret/myapp/db"}, "token": {"env": "MYAPP_TOKEN"}}
+//
+// Vault entries are read using the Vault options (eg WithVaultHost and WithVaultToken), which must be set if there are
+// any, without enabling the Vault backend. Environment variables are looked up in WithEnvMap if set.
+func WithFederatedBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.federatedBackend == nil {
+			s.federatedBackend = &federatedBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, federatedBackendName)
+	}
 }
 
-// SecretDefinition defines a secret and how it can be accessed via the various backends
-type SecretDefinition struct {
-	ID         string // arbitrary identifier for this secret
-	VaultPath  string // path in Vault (no leading slash, eg "secret/foo/bar")
-	EnvVarName string // environment variable name
-	JSONKey    string // key in JSON object
+// WithFederatedFileLocation sets the location of the federated config file
+func WithFederatedFileLocation(loc string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.federatedBackend == nil {
+			s.federatedBackend = &federatedBackend{}
+		}
+		s.federatedBackend.fileLocation = loc
+	}
 }
 
-type vaultBackend struct {
-	host               string
-	authentication     VaultAuthentication
-	authRetries        uint
-	authRetryDelaySecs uint
-	token              string
-	k8sjwt             string
-	k8sauthpath        string
-	appid              string
-	userid             string
-	useridpath         string
-	roleid             string
-	mapping            string
+// WithFederatedReader makes the federated backend read its config from r instead of a file. r is read when the client
+// is created. It can't be combined with WithFederatedFileLocation.
+func WithFederatedReader(r io.Reader) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.federatedBackend == nil {
+			s.federatedBackend = &federatedBackend{}
+		}
+		s.federatedBackend.reader = r
+	}
 }
 
-type envVarBackend struct {
-	mapping string
+// WithOnePasswordBackend enables the 1Password Connect backend. The mapped secret ID is the title (or UUID) of an item, and the value of its password field is returned.
+func WithOnePasswordBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.onePasswordBackend == nil {
+			s.onePasswordBackend = &onePasswordBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, onePasswordBackendName)
+	}
 }
 
-type jsonFileBackend struct {
-	fileLocation string
-	mapping      string
+// WithOnePasswordHost sets the 1Password Connect server URL (eg, http://localhost:8080)
+func WithOnePasswordHost(host string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.onePasswordBackend == nil {
+			s.onePasswordBackend = &onePasswordBackend{}
+		}
+		s.onePasswordBackend.host = host
+	}
 }
 
-type secretsClientConfig struct {
-	mapping         string
-	backendCount    int
-	vaultBackend    *vaultBackend
-	envVarBackend   *envVarBackend
-	jsonFileBackend *jsonFileBackend
+// WithOnePasswordToken sets the 1Password Connect access token
+func WithOnePasswordToken(token string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.onePasswordBackend == nil {
+			s.onePasswordBackend = &onePasswordBackend{}
+		}
+		s.onePasswordBackend.token = token
+	}
 }
 
-// SecretsClientOption defines options when creating a SecretsClient
-type SecretsClientOption func(*secretsClientConfig)
+// WithOnePasswordVault sets the UUID of the 1Password vault containing the items
+func WithOnePasswordVault(vaultID string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.onePasswordBackend == nil {
+			s.onePasswordBackend = &onePasswordBackend{}
+		}
+		s.onePasswordBackend.vaultID = vaultID
+	}
+}
 
-// WithMapping sets the template string mapping to determine the location for each secret in the backend. The secret ID will be interpolated as ".ID".
-// Example (Vault Backend): "secret/foo/bar/{{ .ID }}".
-// Example (Env Var Backend): "MYAPP_SECRET_{{ .ID }}"
-// Example (JSON Backend): "{{ .ID }}"
-func WithMapping(mapping string) SecretsClientOption {
+// WithOnePasswordField sets the label of the item field whose value is returned (default: the field with the password purpose)
+func WithOnePasswordField(label string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		s.mapping = mapping
+		if s.onePasswordBackend == nil {
+			s.onePasswordBackend = &onePasswordBackend{}
+		}
+		s.onePasswordBackend.field = label
 	}
 }
 
-// WithVaultBackend enables the Vault backend.
-func WithVaultBackend() SecretsClientOption {
+// WithDopplerBackend enables the Doppler backend. The mapped secret ID is the name of a secret in the configured
+// project and config.
+func WithDopplerBackend() SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.dopplerBackend == nil {
+			s.dopplerBackend = &dopplerBackend{}
 		}
-		s.backendCount++
+		s.enabledBackends = append(s.enabledBackends, dopplerBackendName)
 	}
 }
 
-// WithVaultHost sets the Vault server host (eg, https//my.vault.com:8300)
-func WithVaultHost(host string) SecretsClientOption {
+// WithDopplerHost sets the Doppler API URL (default: DefaultDopplerHost)
+func WithDopplerHost(host string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.dopplerBackend == nil {
+			s.dopplerBackend = &dopplerBackend{}
 		}
-		s.vaultBackend.host = host
+		s.dopplerBackend.host = host
 	}
 }
 
-// WithVaultAuthentication sets the Vault authentication method
-func WithVaultAuthentication(auth VaultAuthentication) SecretsClientOption {
+// WithDopplerToken sets the token used to authenticate to Doppler. Service tokens (dp.st.*) are scoped to a config,
+// so WithDopplerProject and WithDopplerConfig are optional with one.
+func WithDopplerToken(token string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.dopplerBackend == nil {
+			s.dopplerBackend = &dopplerBackend{}
 		}
-		s.vaultBackend.authentication = auth
+		s.dopplerBackend.token = token
 	}
 }
 
-// WithVaultAuthRetries sets the number of retries if authentication fails (default: 0)
-func WithVaultAuthRetries(retries uint) SecretsClientOption {
+// WithDopplerProject sets the Doppler project containing the secrets
+func WithDopplerProject(project string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.dopplerBackend == nil {
+			s.dopplerBackend = &dopplerBackend{}
 		}
-		s.vaultBackend.authRetries = retries
+		s.dopplerBackend.project = project
 	}
 }
 
-// WithVaultAuthRetryDelay sets the delay in seconds between authentication attempts (default: 0)
-func WithVaultAuthRetryDelay(secs uint) SecretsClientOption {
+// WithDopplerConfig sets the Doppler config (eg, "prd") to read secrets from
+func WithDopplerConfig(config string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.dopplerBackend == nil {
+			s.dopplerBackend = &dopplerBackend{}
 		}
-		s.vaultBackend.authRetryDelaySecs = secs
+		s.dopplerBackend.config = config
 	}
 }
 
-// WithVaultToken sets the token to use when using token auth
-func WithVaultToken(token string) SecretsClientOption {
+// WithInfisicalBackend enables the Infisical backend. The mapped secret ID is the name of a secret at the root of
+// the configured project and environment.
+func WithInfisicalBackend() SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.infisicalBackend == nil {
+			s.infisicalBackend = &infisicalBackend{}
 		}
-		s.vaultBackend.token = token
+		s.enabledBackends = append(s.enabledBackends, infisicalBackendName)
 	}
 }
 
-// WithVaultAppID sets the AppID to use when using AppID auth
-func WithVaultAppID(appid string) SecretsClientOption {
+// WithInfisicalHost sets the Infisical server URL (default: DefaultInfisicalHost)
+func WithInfisicalHost(host string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.infisicalBackend == nil {
+			s.infisicalBackend = &infisicalBackend{}
 		}
-		s.vaultBackend.appid = appid
+		s.infisicalBackend.host = host
 	}
 }
 
-// WithVaultK8sAuth sets the Kubernetes JWT and role to use for authentication
-func WithVaultK8sAuth(jwt, role string) SecretsClientOption {
+// WithInfisicalToken sets the access token (eg, of a machine identity) used to authenticate to Infisical
+func WithInfisicalToken(token string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.infisicalBackend == nil {
+			s.infisicalBackend = &infisicalBackend{}
 		}
-		s.vaultBackend.k8sjwt = jwt
-		s.vaultBackend.roleid = role
-		s.vaultBackend.authentication = K8s
+		s.infisicalBackend.token = token
 	}
 }
 
-// WithVaultK8sAuthPath sets the path for the k8s Vault auth backend (defaults to "kubernetes" otherwise)
-func WithVaultK8sAuthPath(path string) SecretsClientOption {
+// WithInfisicalServiceToken sets the service token used to authenticate to Infisical. Service tokens are scoped to a
+// project, so WithInfisicalProjectID is optional with one.
+func WithInfisicalServiceToken(token string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.infisicalBackend == nil {
+			s.infisicalBackend = &infisicalBackend{}
 		}
-		s.vaultBackend.k8sauthpath = path
+		s.infisicalBackend.serviceToken = token
 	}
 }
 
-// WithVaultUserID sets the UserID to use when using AppID auth
-func WithVaultUserID(userid string) SecretsClientOption {
+// WithInfisicalProjectID sets the ID of the Infisical project containing the secrets
+func WithInfisicalProjectID(id string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.infisicalBackend == nil {
+			s.infisicalBackend = &infisicalBackend{}
 		}
-		s.vaultBackend.userid = userid
+		s.infisicalBackend.projectID = id
 	}
 }
 
-// WithVaultUserIDPath sets the path to the file containing UserID when using AppID auth
-func WithVaultUserIDPath(useridpath string) SecretsClientOption {
+// WithInfisicalEnvironment sets the slug of the Infisical environment to read secrets from (eg, "prod")
+func WithInfisicalEnvironment(env string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.infisicalBackend == nil {
+			s.infisicalBackend = &infisicalBackend{}
 		}
-		s.vaultBackend.useridpath = useridpath
+		s.infisicalBackend.environment = env
 	}
 }
 
-// WithVaultRoleID sets the RoleID when using AppRole authentication
-func WithVaultRoleID(roleid string) SecretsClientOption {
+// WithConjurBackend enables the CyberArk Conjur backend. The mapped secret ID is the ID of a Conjur variable.
+func WithConjurBackend() SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.vaultBackend == nil {
-			s.vaultBackend = &vaultBackend{}
+		if s.conjurBackend == nil {
+			s.conjurBackend = &conjurBackend{}
 		}
-		s.vaultBackend.roleid = roleid
+		s.enabledBackends = append(s.enabledBackends, conjurBackendName)
 	}
 }
 
-// WithEnvVarBackend enables the environment variable backend. Any characters in the secret ID that are not alphanumeric ASCII or underscores (legal env var characters) will be replaced by underscores after mapping.
-func WithEnvVarBackend() SecretsClientOption {
+// WithConjurApplianceURL sets the Conjur appliance URL (eg, https://conjur.example.com)
+func WithConjurApplianceURL(url string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.envVarBackend == nil {
-			s.envVarBackend = &envVarBackend{}
+		if s.conjurBackend == nil {
+			s.conjurBackend = &conjurBackend{}
 		}
-		s.backendCount++
+		s.conjurBackend.applianceURL = url
 	}
 }
 
-// WithJSONFileBackend enables the JSON file backend. The file should contain a single JSON object associating a name with a value: { "mysecret": "pa55w0rd"}.
-func WithJSONFileBackend() SecretsClientOption {
+// WithConjurAccount sets the Conjur organization account
+func WithConjurAccount(account string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.jsonFileBackend == nil {
-			s.jsonFileBackend = &jsonFileBackend{}
+		if s.conjurBackend == nil {
+			s.conjurBackend = &conjurBackend{}
 		}
-		s.backendCount++
+		s.conjurBackend.account = account
 	}
 }
 
-// WithJSONFileLocation sets the location to the JSON file
-func WithJSONFileLocation(loc string) SecretsClientOption {
+// WithConjurLogin sets the login of the Conjur user or host to authenticate as (eg, host/myapp)
+func WithConjurLogin(login string) SecretsClientOption {
 	return func(s *secretsClientConfig) {
-		if s.jsonFileBackend == nil {
-			s.jsonFileBackend = &jsonFileBackend{}
+		if s.conjurBackend == nil {
+			s.conjurBackend = &conjurBackend{}
 		}
-		s.jsonFileBackend.fileLocation = loc
+		s.conjurBackend.login = login
+	}
+}
+
+// WithConjurAPIKey sets the API key used to authenticate to Conjur
+func WithConjurAPIKey(key string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.conjurBackend == nil {
+			s.conjurBackend = &conjurBackend{}
+		}
+		s.conjurBackend.apiKey = key
+	}
+}
+
+// WithSSMParameterStoreBackend enables the AWS Systems Manager Parameter Store backend. The mapped secret ID is the
+// parameter name (eg, "/myapp/db/password"). Credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and (optionally) AWS_SESSION_TOKEN environment variables.
+func WithSSMParameterStoreBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.ssmBackend == nil {
+			s.ssmBackend = &ssmBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, ssmBackendName)
+	}
+}
+
+// WithAWSRegion sets the AWS region of the SSM Parameter Store backend (default: the AWS_REGION or AWS_DEFAULT_REGION environment variable)
+func WithAWSRegion(region string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.ssmBackend == nil {
+			s.ssmBackend = &ssmBackend{}
+		}
+		s.ssmBackend.region = region
+	}
+}
+
+// WithSSMDecrypt sets whether SecureString parameters are decrypted (default: true). If false, their encrypted value is returned.
+func WithSSMDecrypt(decrypt bool) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.ssmBackend == nil {
+			s.ssmBackend = &ssmBackend{}
+		}
+		s.ssmBackend.skipDecrypt = !decrypt
+	}
+}
+
+// WithDockerSecretsBackend enables the Docker secrets backend, which reads secrets mounted as files by Docker Swarm,
+// Compose or Podman. The mapped secret ID is the name of the file in the secrets directory (see WithDockerSecretsDir).
+// A single trailing newline is stripped from the value.
+func WithDockerSecretsBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.dockerBackend == nil {
+			s.dockerBackend = &dockerSecretsBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, dockerBackendName)
+	}
+}
+
+// WithDockerSecretsDir sets the directory the Docker secrets backend reads from (default: DefaultDockerSecretsDir)
+func WithDockerSecretsDir(dir string) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.dockerBackend == nil {
+			s.dockerBackend = &dockerSecretsBackend{}
+		}
+		s.dockerBackend.dir = dir
+	}
+}
+
+// WithNoopBackend enables a backend that has no secrets, returning an empty value (or the one set with WithNoopValue)
+// for every ID without error, eg for demo environments where the application shouldn't need any secrets configured
+func WithNoopBackend() SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.noopBackend == nil {
+			s.noopBackend = &noopBackend{}
+		}
+		s.enabledBackends = append(s.enabledBackends, noopBackendName)
+	}
+}
+
+// WithMemoryBackend enables a backend serving the secrets held by mb, eg in tests (see MemoryBackend)
+func WithMemoryBackend(mb *MemoryBackend) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.memoryBackend = mb
+		s.enabledBackends = append(s.enabledBackends, memoryBackendName)
+	}
+}
+
+// WithNoopValue sets the placeholder value the no-op backend returns for every ID (default: empty)
+func WithNoopValue(value []byte) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		if s.noopBackend == nil {
+			s.noopBackend = &noopBackend{}
+		}
+		s.noopBackend.value = append([]byte(nil), value...)
 	}
 }
 
-// NewSecretsClient returns a SecretsClient configured according to the SecretsClientOptions supplied. Exactly one backend must be enabled.
-// Weird things will happen if you mix options with incompatible backends.
+// WithShadowBackend reads every secret fetched from the backend again from a shadow client created with ops, eg to
+// check that a store being migrated to holds the same values before switching to it. The shadow read happens in the
+// background after a successful Get (values served from the cache aren't compared), never affecting what the Get
+// returns or how long it takes. Differences and failed shadow reads are reported to the handler set with
+// WithShadowMismatchHandler, which is required.
+func WithShadowBackend(ops ...SecretsClientOption) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.shadowOps = ops
+	}
+}
+
+// WithShadowMismatchHandler sets the function called, from a separate goroutine, with each secret whose shadow value
+// differs from the primary's (see WithShadowBackend)
+func WithShadowMismatchHandler(handler func(ShadowMismatch)) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.shadowMismatch = handler
+	}
+}
+
+// WithRoute sends Gets for IDs starting with prefix to a separate client created with ops, with the prefix removed
+// (eg, with WithRoute("vault/", ...), "vault/db" is fetched as "db"). The longest matching prefix is used. IDs that
+// match no route are fetched from the backend enabled for this client, if any, or else fail with ErrNoRoute.
+// Options other than the backend's, eg caching and auditing, apply to this client as a whole.
+func WithRoute(prefix string, ops ...SecretsClientOption) SecretsClientOption {
+	return func(s *secretsClientConfig) {
+		s.routes = append(s.routes, route{prefix: prefix, ops: ops})
+	}
+}
+
+// NewSecretsClient returns a SecretsClient configured according to the SecretsClientOptions supplied. Exactly one backend must be enabled,
+// otherwise ErrNoBackendConfigured or ErrMultipleBackendsConfigured is returned. Options for backends other than the enabled one are ignored.
 func NewSecretsClient(ops ...SecretsClientOption) (*SecretsClient, error) {
 	config := &secretsClientConfig{}
 	for _, op := range ops {
 		op(config)
 	}
-	if config.backendCount != 1 {
-		return nil, fmt.Errorf("exactly one backend must be enabled")
+	backendName := routerBackendName
+	switch len(config.enabledBackends) {
+	case 0:
+		if len(config.routes) == 0 {
+			return nil, ErrNoBackendConfigured
+		}
+	case 1:
+		backendName = config.enabledBackends[0]
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrMultipleBackendsConfigured, strings.Join(config.enabledBackends, ", "))
+	}
+	if len(config.selfTestIDs) > 0 {
+		if err := mappingSelfTest(config.mapping, config.mappingRules, config.selfTestIDs); err != nil {
+			return nil, err
+		}
+	}
+	sc := SecretsClient{
+		backendName: backendName,
+		timeout:     newAtomicDuration(config.timeout),
+		auditSink:   config.auditSink,
+		dryRun:      config.dryRun,
+		missing:     config.missing,
+		flights:     newFlightGroup(),
+		integrity:   config.integrity,
+		fetched:     newIDSet(),
+		health:      newHealth(config.now),
+		readOnly:    config.readOnly,
+		envOverride: config.envOverride,
+		redact:      config.redact,
+	}
+	if config.metrics {
+		sc.metrics = newMetrics()
+	}
+	switch {
+	case config.maxConcurrency < 0:
+		return nil, fmt.Errorf("max concurrency must be positive: %v", config.maxConcurrency)
+	case config.maxConcurrency > 0:
+		sc.slots = make(chan struct{}, config.maxConcurrency)
+	}
+	if len(config.allowedIDs) > 0 {
+		sc.allowedIDs = make(map[string]struct{}, len(config.allowedIDs))
+		for _, id := range config.allowedIDs {
+			sc.allowedIDs[id] = struct{}{}
+		}
+	}
+	switch {
+	case config.cacheTTL < 0:
+		return nil, fmt.Errorf("cache TTL must be positive: %v", config.cacheTTL)
+	case config.cacheTTL > 0:
+		sc.cache = newSecretCache(config.cacheTTL)
+		if config.now != nil {
+			sc.cache.now = config.now
+		}
+	}
+	if config.hashIDs {
+		sc.hashIDs = true
+		sc.idSalt = config.idSalt
+		if sc.cache != nil {
+			sc.cache.key = sc.hashID
+		}
+	}
+	if len(config.cacheTTLs) > 0 {
+		if sc.cache == nil {
+			return nil, fmt.Errorf("per-ID cache TTLs require caching to be enabled (see WithCache)")
+		}
+		for id, ttl := range config.cacheTTLs {
+			if ttl <= 0 {
+				return nil, fmt.Errorf("cache TTL for %v must be positive: %v", id, ttl)
+			}
+		}
+		sc.cache.ttls = config.cacheTTLs
+	}
+	switch {
+	case config.cacheMaxEntries < 0:
+		return nil, fmt.Errorf("cache max entries must not be negative: %v", config.cacheMaxEntries)
+	case config.cacheMaxEntries > 0:
+		if sc.cache == nil {
+			return nil, fmt.Errorf("cache max entries requires caching to be enabled (see WithCache)")
+		}
+		sc.cache.max = config.cacheMaxEntries
 	}
-	sc := SecretsClient{}
 	switch {
-	case config.vaultBackend != nil:
+	case config.maxStale < 0:
+		return nil, fmt.Errorf("max staleness must be positive: %v", config.maxStale)
+	case config.maxStale > 0:
+		if sc.cache == nil {
+			return nil, fmt.Errorf("serving stale values requires caching to be enabled (see WithCache)")
+		}
+		sc.cache.maxStale = config.maxStale
+	}
+	if config.sharedCache != nil {
+		if sc.cache == nil {
+			return nil, fmt.Errorf("shared cache requires caching to be enabled (see WithCache)")
+		}
+		sc.shared = config.sharedCache
+	}
+	switch sc.backendName {
+	case vaultBackendName:
 		config.vaultBackend.mapping = config.mapping
+		config.vaultBackend.mappingRules = config.mappingRules
+		config.vaultBackend.notFound = config.notFound
+		config.vaultBackend.now = config.now
+		if config.dryRun {
+			// don't contact Vault at all
+			config.vaultBackend.authentication = None
+		}
 		vc, err := newVaultClient(config.vaultBackend)
 		if err != nil {
 			return nil, fmt.Errorf("error creating vault client: %v", err)
@@ -249,24 +2102,153 @@ func NewSecretsClient(ops ...SecretsClientOption) (*SecretsClient, error) {
 			return nil, fmt.Errorf("error getting vault backend: %v", err)
 		}
 		sc.backend = vbe
-	case config.envVarBackend != nil:
+	case envVarBackendName:
 		config.envVarBackend.mapping = config.mapping
+		config.envVarBackend.mappingRules = config.mappingRules
+		config.envVarBackend.caseInsensitive = config.caseInsensitive
 		ebe, err := newEnvVarBackendGetter(config.envVarBackend)
 		if err != nil {
 			return nil, fmt.Errorf("error getting env var backend: %v", err)
 		}
 		sc.backend = ebe
-	case config.jsonFileBackend != nil:
+	case jsonFileBackendName:
 		config.jsonFileBackend.mapping = config.mapping
+		config.jsonFileBackend.mappingRules = config.mappingRules
+		config.jsonFileBackend.caseInsensitive = config.caseInsensitive
 		jbe, err := newjsonFileBackendGetter(config.jsonFileBackend)
 		if err != nil {
 			return nil, fmt.Errorf("error getting JSON file backend: %v", err)
 		}
 		sc.backend = jbe
+	case federatedBackendName:
+		config.federatedBackend.mapping = config.mapping
+		config.federatedBackend.mappingRules = config.mappingRules
+		if vb := config.vaultBackend; vb != nil {
+			vb.notFound = config.notFound
+			vb.now = config.now
+			if config.dryRun {
+				vb.authentication = None
+			}
+		}
+		fbe, err := newFederatedBackendGetter(config.federatedBackend, config.vaultBackend, config.envVarBackend)
+		if err != nil {
+			return nil, fmt.Errorf("error getting federated backend: %v", err)
+		}
+		sc.backend = fbe
+	case onePasswordBackendName:
+		config.onePasswordBackend.mapping = config.mapping
+		config.onePasswordBackend.mappingRules = config.mappingRules
+		config.onePasswordBackend.notFound = config.notFound
+		obe, err := newOnePasswordBackendGetter(config.onePasswordBackend)
+		if err != nil {
+			return nil, fmt.Errorf("error getting 1Password backend: %v", err)
+		}
+		sc.backend = obe
+	case conjurBackendName:
+		config.conjurBackend.mapping = config.mapping
+		config.conjurBackend.mappingRules = config.mappingRules
+		config.conjurBackend.notFound = config.notFound
+		cbe, err := newConjurBackendGetter(config.conjurBackend)
+		if err != nil {
+			return nil, fmt.Errorf("error getting Conjur backend: %v", err)
+		}
+		sc.backend = cbe
+	case infisicalBackendName:
+		config.infisicalBackend.mapping = config.mapping
+		config.infisicalBackend.mappingRules = config.mappingRules
+		config.infisicalBackend.notFound = config.notFound
+		ibe, err := newInfisicalBackendGetter(config.infisicalBackend)
+		if err != nil {
+			return nil, fmt.Errorf("error getting Infisical backend: %v", err)
+		}
+		sc.backend = ibe
+	case dopplerBackendName:
+		config.dopplerBackend.mapping = config.mapping
+		config.dopplerBackend.mappingRules = config.mappingRules
+		config.dopplerBackend.notFound = config.notFound
+		dbe, err := newDopplerBackendGetter(config.dopplerBackend)
+		if err != nil {
+			return nil, fmt.Errorf("error getting Doppler backend: %v", err)
+		}
+		sc.backend = dbe
+	case ssmBackendName:
+		config.ssmBackend.mapping = config.mapping
+		config.ssmBackend.mappingRules = config.mappingRules
+		sbe, err := newSSMBackendGetter(config.ssmBackend)
+		if err != nil {
+			return nil, fmt.Errorf("error getting SSM Parameter Store backend: %v", err)
+		}
+		sc.backend = sbe
+	case dockerBackendName:
+		config.dockerBackend.mapping = config.mapping
+		config.dockerBackend.mappingRules = config.mappingRules
+		dbe, err := newDockerSecretsBackendGetter(config.dockerBackend)
+		if err != nil {
+			return nil, fmt.Errorf("error getting Docker secrets backend: %v", err)
+		}
+		sc.backend = dbe
+	case noopBackendName:
+		sc.backend = &noopBackendGetter{config: config.noopBackend}
+	case memoryBackendName:
+		if config.memoryBackend == nil {
+			return nil, fmt.Errorf("memory backend is nil")
+		}
+		sc.backend = config.memoryBackend
+	case customBackendName:
+		sc.backend = config.customBackend
+	}
+	if len(config.routes) > 0 {
+		rb, err := newRouterBackend(config.routes, sc.backend, sc.backendName)
+		if err != nil {
+			return nil, fmt.Errorf("error getting router backend: %w", err)
+		}
+		sc.backend = rb
+		sc.backendName = routerBackendName
+	}
+	if config.shadowOps != nil {
+		if config.shadowMismatch == nil {
+			return nil, fmt.Errorf("shadow backend requires a mismatch handler (see WithShadowMismatchHandler)")
+		}
+		shadow, err := NewSecretsClient(config.shadowOps...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating shadow client: %w", err)
+		}
+		sc.shadow = shadow
+		sc.onMismatch = config.shadowMismatch
 	}
 	return &sc, nil
 }
 
+// NewSecretsClientWithBackend returns a SecretsClient that retrieves secrets from b, with the (non-backend) options
+// ops applied. Mappings are up to b. To decorate a built-in backend, b can wrap a SecretsClient using it.
+func NewSecretsClientWithBackend(b Backend, ops ...SecretsClientOption) (*SecretsClient, error) {
+	if b == nil {
+		return nil, fmt.Errorf("backend is nil")
+	}
+	ops = append(ops[:len(ops):len(ops)], func(s *secretsClientConfig) {
+		s.customBackend = b
+		s.enabledBackends = append(s.enabledBackends, customBackendName)
+	})
+	return NewSecretsClient(ops...)
+}
+
+// foldKeys returns a copy of m keyed by fold(key). Where several keys fold to the same key, the value of the key that sorts first is kept.
+func foldKeys(m map[string]string, fold func(string) string) map[string]string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	folded := make(map[string]string, len(m))
+	for _, k := range keys {
+		fk := fold(k)
+		if _, ok := folded[fk]; !ok {
+			folded[fk] = m[k]
+		}
+	}
+	return folded
+}
+
 // SecretMapper maps secrets
 type SecretMapper interface {
 	MapSecret(id string) (string, error)
@@ -275,10 +2257,18 @@ type SecretMapper interface {
 // secretMapper manages turning secret IDs into a location suitable for a backend to use
 type secretMapper struct {
 	mappingTmpl *template.Template
+	identity    bool // mapping is just the ID, so the template needn't be executed for most IDs
+	rules       []mappingRule
 }
 
-// newSecretMapper returns a secret mapper using the supplied mapping string
-func newSecretMapper(mapping string) (*secretMapper, error) {
+// mappingRule is a compiled MappingRule
+type mappingRule struct {
+	match  *regexp.Regexp
+	mapper *secretMapper
+}
+
+// newSecretMapper returns a secret mapper using the supplied mapping string for IDs that don't match any of rules
+func newSecretMapper(mapping string, rules ...MappingRule) (*secretMapper, error) {
 	if !strings.Contains(mapping, "{{ .ID") && !strings.Contains(mapping, "{{.ID") {
 		return nil, fmt.Errorf("mapping must contain {{ .ID }}")
 	}
@@ -286,13 +2276,37 @@ func newSecretMapper(mapping string) (*secretMapper, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error parsing mapping: %v", err)
 	}
-	return &secretMapper{
+	sm := &secretMapper{
 		mappingTmpl: tmpl,
-	}, nil
+		identity:    mapping == "{{ .ID }}" || mapping == "{{.ID}}",
+	}
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing mapping rule match %q: %v", r.Match, err)
+		}
+		rm, err := newSecretMapper(r.Template)
+		if err != nil {
+			return nil, fmt.Errorf("error with mapping rule for %q: %v", r.Match, err)
+		}
+		sm.rules = append(sm.rules, mappingRule{match: re, mapper: rm})
+	}
+	return sm, nil
 }
 
+// htmlEscapedChars are the characters the mapping template escapes, so IDs containing them can't take the identity fast path
+const htmlEscapedChars = "\x00\"&'+<>"
+
 // mapSecret maps a secret ID to a location via the mapping string
 func (sm *secretMapper) MapSecret(id string) (string, error) {
+	for _, r := range sm.rules {
+		if r.match.MatchString(id) {
+			return r.mapper.MapSecret(id)
+		}
+	}
+	if sm.identity && !strings.ContainsAny(id, htmlEscapedChars) {
+		return id, nil
+	}
 	d := struct{ ID string }{ID: id}
 	b := bytes.Buffer{}
 	err := sm.mappingTmpl.Execute(&b, d)
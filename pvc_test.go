@@ -1,8 +1,16 @@
 package pvc
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewSecretsClientVaultBackend(t *testing.T) {
@@ -132,9 +140,12 @@ func TestNewSecretsClientMultipleBackends(t *testing.T) {
 	if err == nil {
 		t.Fatalf("should have failed")
 	}
-	if !strings.Contains(err.Error(), "exactly one") {
+	if !errors.Is(err, ErrMultipleBackendsConfigured) {
 		t.Fatalf("expected multiple backends error, received: %v", err)
 	}
+	if !strings.Contains(err.Error(), "vault, envvar, jsonfile") {
+		t.Fatalf("expected enabled backends to be listed, received: %v", err)
+	}
 }
 
 func TestNewSecretsClientNoBackends(t *testing.T) {
@@ -142,11 +153,22 @@ func TestNewSecretsClientNoBackends(t *testing.T) {
 	if err == nil {
 		t.Fatalf("should have failed")
 	}
-	if !strings.Contains(err.Error(), "exactly one") {
+	if err != ErrNoBackendConfigured {
 		t.Fatalf("expected no backends error, received: %v", err)
 	}
 }
 
+func TestNewSecretsClientOneBackend(t *testing.T) {
+	// options for other backends don't count as enabling them
+	sc, err := NewSecretsClient(WithVaultHost("foo"), WithJSONFileLocation("example/secrets.json"), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if _, ok := sc.backend.(*envVarBackendGetter); !ok {
+		t.Fatalf("wrong backend type: %T", sc.backend)
+	}
+}
+
 func TestNewSecretMapper(t *testing.T) {
 	sc, err := newSecretMapper("foo/{{ .ID }}/bar")
 	if err != nil {
@@ -161,6 +183,66 @@ func TestNewSecretMapper(t *testing.T) {
 	}
 }
 
+func TestSecretMapperIdentity(t *testing.T) {
+	ids := []string{"foo", "foo/bar", "FOO_bar-1.2", "", "a b", "a&b", "a+b", "<script>", `"quoted"`, "it's", "nul\x00", "ünïcødé", "\xff\xfe"}
+	for c := 0x20; c < 0x7f; c++ {
+		ids = append(ids, "x"+string(rune(c))+"y")
+	}
+	for _, mapping := range []string{"{{ .ID }}", "{{.ID}}"} {
+		sm, err := newSecretMapper(mapping)
+		if err != nil {
+			t.Fatalf("error getting secret mapper: %v", err)
+		}
+		if !sm.identity {
+			t.Fatalf("%v should be detected as an identity mapping", mapping)
+		}
+		tmplOnly := &secretMapper{mappingTmpl: sm.mappingTmpl}
+		for _, id := range ids {
+			fast, err := sm.MapSecret(id)
+			if err != nil {
+				t.Fatalf("error mapping: %v", err)
+			}
+			slow, err := tmplOnly.MapSecret(id)
+			if err != nil {
+				t.Fatalf("error mapping: %v", err)
+			}
+			if fast != slow {
+				t.Fatalf("identity mapping of %q differs from template: %q (expected %q)", id, fast, slow)
+			}
+		}
+	}
+	sm, err := newSecretMapper("secret/{{ .ID }}")
+	if err != nil {
+		t.Fatalf("error getting secret mapper: %v", err)
+	}
+	if sm.identity {
+		t.Fatalf("mapping with a prefix is not an identity mapping")
+	}
+}
+
+func BenchmarkSecretMapperIdentity(b *testing.B) {
+	sm, err := newSecretMapper("{{ .ID }}")
+	if err != nil {
+		b.Fatalf("error getting secret mapper: %v", err)
+	}
+	for _, bm := range []struct {
+		name string
+		sm   *secretMapper
+	}{
+		{"fast", sm},
+		{"template", &secretMapper{mappingTmpl: sm.mappingTmpl}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.sm.MapSecret("foo/bar"); err != nil {
+					b.Fatalf("error mapping: %v", err)
+				}
+			}
+		})
+	}
+}
+
 func TestNewSecretMapperMissingID(t *testing.T) {
 	_, err := newSecretMapper("{{ .Foo }}")
 	if err == nil {
@@ -174,3 +256,537 @@ func TestNewSecretMapperInvalidTemplate(t *testing.T) {
 		t.Fatalf("should have failed")
 	}
 }
+
+func TestWithJSONFlatten(t *testing.T) {
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONFileLocation("testing/nested_secrets.json"), WithJSONFlatten(""), WithMapping("db.{{ .ID }}"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	s, err := sc.Get("password")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "x" {
+		t.Fatalf("bad value: %v (expected x)", string(s))
+	}
+}
+
+type slowBackend struct {
+	delay time.Duration
+	value []byte
+}
+
+func (sb *slowBackend) Get(id string) ([]byte, error) {
+	time.Sleep(sb.delay)
+	return sb.value, nil
+}
+
+func TestGetTimeout(t *testing.T) {
+	sc := &SecretsClient{
+		backend: &slowBackend{delay: 500 * time.Millisecond},
+		timeout: newAtomicDuration(10 * time.Millisecond),
+	}
+	_, err := sc.Get("foo")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, received: %v", err)
+	}
+}
+
+func TestGetTimeoutNotExceeded(t *testing.T) {
+	value := "bar"
+	sc := &SecretsClient{
+		backend: &slowBackend{delay: time.Millisecond, value: []byte(value)},
+		timeout: newAtomicDuration(time.Second),
+	}
+	s, err := sc.Get("foo")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != value {
+		t.Fatalf("bad value: %v (expected %v)", string(s), value)
+	}
+}
+
+func TestGetContextCallerDeadline(t *testing.T) {
+	sc := &SecretsClient{
+		backend: &slowBackend{delay: 500 * time.Millisecond},
+		timeout: newAtomicDuration(time.Minute),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := sc.GetContext(ctx, "foo")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, received: %v", err)
+	}
+}
+
+func TestGetContextCanceled(t *testing.T) {
+	sc := &SecretsClient{
+		backend: &slowBackend{delay: 500 * time.Millisecond},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := sc.GetContext(ctx, "foo")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, received: %v", err)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if sc.getTimeout() != time.Second {
+		t.Fatalf("timeout was not set: %v", sc.getTimeout())
+	}
+}
+
+func TestWithEnvMap(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	s, err := sc.Get("foo")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "bar" {
+		t.Fatalf("bad value: %v (expected bar)", string(s))
+	}
+}
+
+func TestNewSecretsClientOnePasswordBackend(t *testing.T) {
+	sc, err := NewSecretsClient(WithOnePasswordBackend(), WithOnePasswordHost("http://localhost:8080"), WithOnePasswordToken("foo"), WithOnePasswordVault("bar"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	switch sc.backend.(type) {
+	case *onePasswordBackendGetter:
+		break
+	default:
+		t.Fatalf("wrong backend type: %T", sc.backend)
+	}
+}
+
+func TestNewSecretsClientConjurBackend(t *testing.T) {
+	sc, err := NewSecretsClient(WithConjurBackend(), WithConjurApplianceURL("https://conjur.example.com"), WithConjurAccount("myorg"), WithConjurLogin("host/myapp"), WithConjurAPIKey("foo"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	switch sc.backend.(type) {
+	case *conjurBackendGetter:
+		break
+	default:
+		t.Fatalf("wrong backend type: %T", sc.backend)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	cases := []struct {
+		name     string
+		ops      []SecretsClientOption
+		expected string
+	}{
+		{"vault", []SecretsClientOption{WithVaultBackend(), WithVaultHost("http://127.0.0.1:1"), WithVaultAuthentication(Token), WithMapping("secret/myapp/{{ .ID }}")}, "secret/myapp/foo/bar"},
+		{"envvar", []SecretsClientOption{WithEnvVarBackend(), WithMapping("MYAPP_{{ .ID }}")}, "MYAPP_FOO_BAR"},
+		{"jsonfile", []SecretsClientOption{WithJSONFileBackend(), WithJSONFileLocation("example/secrets.json")}, "foo/bar"},
+		{"1password", []SecretsClientOption{WithOnePasswordBackend(), WithOnePasswordHost("http://127.0.0.1:1"), WithOnePasswordToken("foo"), WithOnePasswordVault("bar")}, "foo/bar"},
+	}
+	for _, c := range cases {
+		sc, err := NewSecretsClient(append(c.ops, WithDryRun())...)
+		if err != nil {
+			t.Fatalf("%v: error getting SecretsClient: %v", c.name, err)
+		}
+		loc, err := sc.Get("foo/bar")
+		if err != ErrDryRun {
+			t.Fatalf("%v: expected ErrDryRun, received: %v", c.name, err)
+		}
+		if string(loc) != c.expected {
+			t.Fatalf("%v: bad location: %v (expected %v)", c.name, string(loc), c.expected)
+		}
+	}
+}
+
+func TestScoped(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_MYAPP_DB_PASSWORD": "hunter2"}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	parent, err := sc.Get("myapp/db/password")
+	if err != nil {
+		t.Fatalf("parent get failed: %v", err)
+	}
+	scoped := sc.Scoped("myapp/")
+	if scoped.backend != sc.backend {
+		t.Fatalf("scoped client should share the parent backend")
+	}
+	s, err := scoped.Get("db/password")
+	if err != nil {
+		t.Fatalf("scoped get failed: %v", err)
+	}
+	if string(s) != string(parent) {
+		t.Fatalf("bad value: %v (expected %v)", string(s), string(parent))
+	}
+	s, err = scoped.Scoped("db/").Get("password")
+	if err != nil {
+		t.Fatalf("nested scoped get failed: %v", err)
+	}
+	if string(s) != string(parent) {
+		t.Fatalf("bad value: %v (expected %v)", string(s), string(parent))
+	}
+}
+
+func TestGetDetailed(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithEnvVarBackend(), WithCache(time.Minute))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for i, fromCache := range []bool{false, true} {
+		r, err := sc.GetDetailed("foo")
+		if err != nil {
+			t.Fatalf("get %v failed: %v", i, err)
+		}
+		if string(r.Value) != "bar" {
+			t.Fatalf("bad value: %v", string(r.Value))
+		}
+		if r.Backend != envVarBackendName {
+			t.Fatalf("bad backend: %v", r.Backend)
+		}
+		if r.ResolvedPath != "SECRET_FOO" {
+			t.Fatalf("bad resolved path: %v", r.ResolvedPath)
+		}
+		if r.FromCache != fromCache {
+			t.Fatalf("get %v: FromCache should be %v", i, fromCache)
+		}
+	}
+}
+
+func TestGetDetailedScoped(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_APP_FOO": "bar"}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	r, err := sc.Scoped("app_").GetDetailed("foo")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if r.ResolvedPath != "SECRET_APP_FOO" || r.FromCache {
+		t.Fatalf("bad result: %+v", r)
+	}
+}
+
+func TestWithAllowedIDs(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithAllowedIDs("foo"), WithAllowedIDs("app/bar"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	cb := &countingBackend{value: []byte("value")}
+	sc.backend = cb
+	for _, get := range []func() ([]byte, error){
+		func() ([]byte, error) { return sc.Get("foo") },
+		func() ([]byte, error) { return sc.Scoped("app/").Get("bar") },
+	} {
+		if _, err := get(); err != nil {
+			t.Fatalf("allowed ID should have succeeded: %v", err)
+		}
+	}
+	for _, get := range []func() ([]byte, error){
+		func() ([]byte, error) { return sc.Get("secret") },
+		func() ([]byte, error) { return sc.Get("bar") },
+		func() ([]byte, error) { return sc.Scoped("app/").Get("foo") },
+	} {
+		if _, err := get(); !errors.Is(err, ErrIDNotAllowed) {
+			t.Fatalf("should have returned ErrIDNotAllowed: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 2 {
+		t.Fatalf("disallowed IDs should not reach the backend: %v calls", n)
+	}
+}
+
+func TestWithAllowedIDsEmpty(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithEnvVarBackend(), WithAllowedIDs())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("empty allowlist should not restrict IDs: %v", err)
+	}
+}
+
+func TestDetectCollisions(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	collisions, err := sc.DetectCollisions([]string{"db-password", "api_key", "db_password", "DB.password", "other"})
+	if err != nil {
+		t.Fatalf("detect collisions failed: %v", err)
+	}
+	if len(collisions) != 1 {
+		t.Fatalf("expected 1 collision: %v", collisions)
+	}
+	ids := collisions["SECRET_DB_PASSWORD"]
+	if len(ids) != 3 || ids[0] != "DB.password" || ids[1] != "db-password" || ids[2] != "db_password" {
+		t.Fatalf("bad colliding IDs: %v", ids)
+	}
+}
+
+func TestDetectCollisionsVaultNormalization(t *testing.T) {
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost("foo"), WithVaultAuthentication(None))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	collisions, err := sc.DetectCollisions([]string{"app/db", "app//db", "app/api"})
+	if err != nil {
+		t.Fatalf("detect collisions failed: %v", err)
+	}
+	if ids := collisions["secret/app/db"]; len(collisions) != 1 || len(ids) != 2 {
+		t.Fatalf("bad collisions: %v", collisions)
+	}
+}
+
+func TestMissingSecretPolicy(t *testing.T) {
+	newClient := func(policy MissingSecretPolicy) *SecretsClient {
+		sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}), WithMissingSecretPolicy(policy))
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		return sc
+	}
+	if _, err := newClient(PolicyError).Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+	v, err := newClient(PolicyEmpty).Get("missing")
+	if err != nil || v == nil || len(v) != 0 {
+		t.Fatalf("should have returned an empty value: %q: %v", v, err)
+	}
+	sc := newClient(PolicyPanic)
+	if v, err := sc.Get("foo"); err != nil || string(v) != "bar" {
+		t.Fatalf("existing secret should be returned: %v: %v", string(v), err)
+	}
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok || !errors.Is(err, ErrSecretNotFound) {
+			t.Fatalf("should have panicked with ErrSecretNotFound: %v", r)
+		}
+	}()
+	sc.Get("missing")
+	t.Fatalf("should have panicked")
+}
+
+func TestMappingRules(t *testing.T) {
+	rules := []MappingRule{
+		{Match: "^db/", Template: "database/creds/{{ .ID }}"},
+		{Match: `^(aws|gcp)/`, Template: "cloud/{{ .ID }}"},
+		{Match: "^db/legacy", Template: "never/{{ .ID }}"},
+	}
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost("foo"), WithVaultAuthentication(None), WithDryRun(),
+		WithMapping("kv/app/{{ .ID }}"), WithMappingRules(rules))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for id, want := range map[string]string{
+		"db/readonly": "database/creds/db/readonly",
+		"db/legacy":   "database/creds/db/legacy",
+		"aws/key":     "cloud/aws/key",
+		"gcp/key":     "cloud/gcp/key",
+		"api_key":     "kv/app/api_key",
+		"azure/key":   "kv/app/azure/key",
+	} {
+		v, err := sc.Get(id)
+		if !errors.Is(err, ErrDryRun) {
+			t.Fatalf("expected ErrDryRun: %v", err)
+		}
+		if string(v) != want {
+			t.Fatalf("bad location for %v: %v (expected %v)", id, string(v), want)
+		}
+	}
+}
+
+func TestMappingRulesInvalid(t *testing.T) {
+	for _, r := range []MappingRule{{Match: "(", Template: "{{ .ID }}"}, {Match: "^db/", Template: "no-id"}} {
+		if _, err := NewSecretsClient(WithEnvVarBackend(), WithMappingRules([]MappingRule{r})); err == nil {
+			t.Fatalf("should have failed for %+v", r)
+		}
+	}
+}
+
+func TestGetSecretError(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{}))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	_, err = sc.Scoped("app/").Get("missing")
+	se := &SecretError{}
+	if !errors.As(err, &se) {
+		t.Fatalf("should have returned a SecretError: %T: %v", err, err)
+	}
+	if se.ID != "app/missing" || se.Backend != envVarBackendName {
+		t.Fatalf("bad SecretError: %+v", se)
+	}
+	if !errors.Is(err, ErrSecretNotFound) || !errors.Is(errors.Unwrap(err), ErrSecretNotFound) {
+		t.Fatalf("should match ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := ioutil.WriteFile(path, []byte(`{"foo": "bar"}`), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONFileLocation(path), WithJSONFilePersist(), WithReadOnly())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if err := sc.Set("foo", []byte("rotated")); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, received: %v", err)
+	}
+	if s, err := sc.Get("foo"); err != nil || string(s) != "bar" {
+		t.Fatalf("value shouldn't have changed: %v (%v)", string(s), err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != `{"foo": "bar"}` {
+		t.Fatalf("file shouldn't have been written: %v (%v)", string(b), err)
+	}
+}
+
+func TestMappingSelfTest(t *testing.T) {
+	mapping := `{{ if ne .ID "skipped" }}SECRET_{{ .ID }}_{{ slice .ID 0 4 }}{{ end }}`
+	_, err := NewSecretsClient(WithEnvVarBackend(), WithMapping(mapping), WithMappingSelfTest("abcdef", "xy", "skipped", "wxyz"))
+	if err == nil {
+		t.Fatalf("should have failed")
+	}
+	for _, s := range []string{"2 of 4 IDs", "xy: ", "skipped: mapped to an empty location"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("error should contain %q: %v", s, err)
+		}
+	}
+	if strings.Contains(err.Error(), "abcdef") || strings.Contains(err.Error(), "wxyz") {
+		t.Fatalf("error should only list failing IDs: %v", err)
+	}
+	if _, err := NewSecretsClient(WithEnvVarBackend(), WithMapping(mapping), WithMappingSelfTest("abcdef", "wxyz")); err != nil {
+		t.Fatalf("self-test should pass: %v", err)
+	}
+}
+
+func TestIDHashing(t *testing.T) {
+	rs := &recordingSink{}
+	shared := newMemorySharedCache()
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_ACME_CORP": "bar"}), WithCache(time.Minute),
+		WithSharedCache(shared), WithAuditSink(rs.record), WithIDHashing([]byte("salt")))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		v, err := sc.Get("acme_corp")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(v) != "bar" {
+			t.Fatalf("bad value: %v", string(v))
+		}
+	}
+	hashed := sha256Hex([]byte("saltacme_corp"))
+	if len(rs.events) != 2 {
+		t.Fatalf("expected 2 events, got %v", len(rs.events))
+	}
+	for _, e := range rs.events {
+		if e.ID != hashed {
+			t.Fatalf("audit event should have the hashed ID: %+v", e)
+		}
+	}
+	if _, ok := sc.cache.entries[hashed]; !ok || len(sc.cache.entries) != 1 {
+		t.Fatalf("cache should be keyed by the hashed ID: %v", sc.cache.entries)
+	}
+	key := "envvar:" + sha256Hex([]byte("saltSECRET_ACME_CORP"))
+	if _, ok := shared.values[key]; !ok || len(shared.values) != 1 {
+		t.Fatalf("shared cache should be keyed by the hashed location: %v", shared.values)
+	}
+}
+
+func TestEnvOverride(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "from vault"}}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithEnvOverride())
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	r, err := sc.GetDetailed("db")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(r.Value) != "from vault" || r.Backend != "vault" {
+		t.Fatalf("without the env var Vault should be consulted: %+v", r)
+	}
+	t.Setenv("SECRET_DB", "from env")
+	r, err = sc.GetDetailed("db")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(r.Value) != "from env" || r.Backend != "envvar" || r.ResolvedPath != "SECRET_DB" {
+		t.Fatalf("the env var should override Vault: %+v", r)
+	}
+}
+
+// countingDecorator is a user-supplied Backend counting the Gets made through it
+type countingDecorator struct {
+	next  ContextBackend
+	calls int32
+}
+
+func (cd *countingDecorator) Get(id string) ([]byte, error) {
+	return cd.GetContext(context.Background(), id)
+}
+
+func (cd *countingDecorator) GetContext(ctx context.Context, id string) ([]byte, error) {
+	atomic.AddInt32(&cd.calls, 1)
+	return cd.next.GetContext(ctx, id)
+}
+
+func TestNewSecretsClientWithBackend(t *testing.T) {
+	inner, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	cd := &countingDecorator{next: inner}
+	sc, err := NewSecretsClientWithBackend(cd, WithCache(time.Minute))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		r, err := sc.GetDetailed("foo")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(r.Value) != "bar" || r.Backend != "custom" {
+			t.Fatalf("bad result: %+v", r)
+		}
+	}
+	if n := atomic.LoadInt32(&cd.calls); n != 1 {
+		t.Fatalf("expected 1 call through the decorator, got %v", n)
+	}
+	if _, err := sc.Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+	if n := atomic.LoadInt32(&cd.calls); n != 2 {
+		t.Fatalf("expected 2 calls through the decorator, got %v", n)
+	}
+}
+
+func TestNewSecretsClientWithBackendRejectsOtherBackends(t *testing.T) {
+	_, err := NewSecretsClientWithBackend(&countingDecorator{}, WithEnvVarBackend())
+	if !errors.Is(err, ErrMultipleBackendsConfigured) {
+		t.Fatalf("expected ErrMultipleBackendsConfigured, got %v", err)
+	}
+	if _, err := NewSecretsClientWithBackend(nil); err == nil {
+		t.Fatalf("should have failed with a nil backend")
+	}
+}
@@ -0,0 +1,97 @@
+package pvc
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// Reconfigure changes settings of a running client. Only WithTimeout, WithCache (if the client was created with a
+// cache), WithVaultReadRetries and WithVaultReadRetryDelay may be given, any other option returns
+// ErrCannotReconfigureBackend and nothing is changed. Settings whose option isn't given are left as they are, so
+// WithTimeout(0) removes the timeout and WithVaultReadRetries(0) disables retries; the cache TTL must be positive.
+// A new cache TTL applies to values cached afterwards. Clients previously derived with Scoped share the new cache TTL
+// and Vault retry settings but keep their timeout.
+func (sc *SecretsClient) Reconfigure(ops ...SecretsClientOption) error {
+	config := &secretsClientConfig{}
+	for _, op := range ops {
+		op(config)
+	}
+	allowed := secretsClientConfig{timeout: config.timeout, timeoutSet: config.timeoutSet, cacheTTL: config.cacheTTL, cacheTTLSet: config.cacheTTLSet}
+	if vb := config.vaultBackend; vb != nil {
+		allowed.vaultBackend = &vaultBackend{
+			readRetries:       vb.readRetries,
+			readRetriesSet:    vb.readRetriesSet,
+			readRetryDelay:    vb.readRetryDelay,
+			readRetryDelaySet: vb.readRetryDelaySet,
+		}
+	}
+	if !reflect.DeepEqual(*config, allowed) {
+		return fmt.Errorf("%w: only the timeout, cache TTL and Vault read retries can be reconfigured", ErrCannotReconfigureBackend)
+	}
+	if config.timeout < 0 || (config.vaultBackend != nil && config.vaultBackend.readRetryDelay < 0) {
+		return fmt.Errorf("timeout and retry delay must not be negative")
+	}
+	if config.cacheTTLSet && config.cacheTTL <= 0 {
+		return fmt.Errorf("cache TTL must be positive")
+	}
+	if config.cacheTTLSet && sc.cache == nil {
+		return fmt.Errorf("%w: caching wasn't enabled when the client was created", ErrCannotReconfigureBackend)
+	}
+	var vb *vaultBackend
+	if config.vaultBackend != nil {
+		vbg, ok := sc.backend.(*vaultBackendGetter)
+		if !ok {
+			return fmt.Errorf("%w: Vault read retries require the Vault backend", ErrCannotReconfigureBackend)
+		}
+		vb = vbg.config
+	}
+
+	if config.timeoutSet {
+		if sc.timeout == nil {
+			sc.timeout = newAtomicDuration(0) // a client that wasn't created with NewSecretsClient
+		}
+		sc.timeout.store(config.timeout)
+	}
+	if config.cacheTTLSet {
+		sc.cache.Lock()
+		sc.cache.ttl = config.cacheTTL
+		sc.cache.Unlock()
+	}
+	if vb != nil {
+		if config.vaultBackend.readRetriesSet {
+			atomic.StoreUint64(&vb.readRetries, config.vaultBackend.readRetries)
+		}
+		if config.vaultBackend.readRetryDelaySet {
+			atomic.StoreInt64((*int64)(&vb.readRetryDelay), int64(config.vaultBackend.readRetryDelay))
+		}
+	}
+	return nil
+}
+
+// atomicDuration is a duration that may be changed while it is read from other goroutines
+type atomicDuration struct {
+	d int64
+}
+
+func newAtomicDuration(d time.Duration) *atomicDuration {
+	return &atomicDuration{d: int64(d)}
+}
+
+// load returns the duration, which is zero for a nil atomicDuration
+func (a *atomicDuration) load() time.Duration {
+	if a == nil {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&a.d))
+}
+
+func (a *atomicDuration) store(d time.Duration) {
+	atomic.StoreInt64(&a.d, int64(d))
+}
+
+// getTimeout returns the timeout applied to each Get (see WithTimeout)
+func (sc *SecretsClient) getTimeout() time.Duration {
+	return sc.timeout.load()
+}
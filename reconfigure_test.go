@@ -0,0 +1,132 @@
+package pvc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconfigureCacheTTL(t *testing.T) {
+	clock := newFakeClock()
+	cb := &countingBackend{value: []byte("foo")}
+	sc := &SecretsClient{backend: cb, cache: newSecretCache(time.Minute)}
+	sc.cache.now = clock.now
+	if err := sc.Reconfigure(WithCache(time.Hour)); err != nil {
+		t.Fatalf("reconfigure failed: %v", err)
+	}
+	if _, err := sc.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	clock.advance(30 * time.Minute)
+	if _, err := sc.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 1 {
+		t.Fatalf("expected the value to be cached for the new TTL, got %v backend calls", n)
+	}
+	clock.advance(31 * time.Minute)
+	if _, err := sc.Get("bar"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&cb.calls); n != 2 {
+		t.Fatalf("expected the value to expire after the new TTL, got %v backend calls", n)
+	}
+}
+
+func TestReconfigureTimeout(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	if err := sc.Reconfigure(WithTimeout(time.Second)); err != nil {
+		t.Fatalf("reconfigure failed: %v", err)
+	}
+	if d := sc.getTimeout(); d != time.Second {
+		t.Fatalf("bad timeout: %v", d)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+}
+
+func TestReconfigureRejectsBackendOptions(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	for name, op := range map[string]SecretsClientOption{
+		"backend":     WithVaultBackend(),
+		"mapping":     WithMapping("OTHER_{{ .ID }}"),
+		"host":        WithVaultHost("https://vault.example.com"),
+		"cache":       WithCache(time.Minute),
+		"vault retry": WithVaultReadRetries(3),
+	} {
+		if err := sc.Reconfigure(WithTimeout(time.Second), op); !errors.Is(err, ErrCannotReconfigureBackend) {
+			t.Fatalf("%v: expected ErrCannotReconfigureBackend, got %v", name, err)
+		}
+	}
+	if d := sc.getTimeout(); d != 0 {
+		t.Fatalf("timeout should be unchanged after a rejected reconfigure: %v", d)
+	}
+}
+
+func TestReconfigureZero(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/secret/foo" {
+			atomic.AddInt32(&requests, 1)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithTimeout(time.Minute),
+		WithVaultReadRetries(2), WithVaultReadRetryDelay(time.Millisecond), WithCache(time.Minute))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	if err := sc.Reconfigure(WithTimeout(0), WithVaultReadRetries(0)); err != nil {
+		t.Fatalf("reconfigure failed: %v", err)
+	}
+	if d := sc.getTimeout(); d != 0 {
+		t.Fatalf("timeout should have been removed: %v", d)
+	}
+	if _, err := sc.Get("foo"); err == nil {
+		t.Fatalf("get should have failed")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("retries should have been disabled, got %v requests", n)
+	}
+	if err := sc.Reconfigure(WithCache(0)); err == nil {
+		t.Fatalf("a zero cache TTL should be rejected")
+	}
+}
+
+func TestReconfigureScoped(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_APP_FOO": "bar"}), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	scoped := sc.Scoped("app_")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			sc.Reconfigure(WithTimeout(time.Duration(i+1) * time.Second))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		sc.Scoped("app_")
+	}
+	wg.Wait()
+	if d := scoped.getTimeout(); d != time.Second {
+		t.Fatalf("a scoped client should keep its timeout: %v", d)
+	}
+	if d := sc.Scoped("app_").getTimeout(); d != 100*time.Second {
+		t.Fatalf("a client scoped afterwards should have the new timeout: %v", d)
+	}
+}
@@ -0,0 +1,48 @@
+package pvc
+
+import (
+	"regexp"
+)
+
+// redactions are the patterns DefaultErrorRedactor replaces, with their replacements
+var redactions = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?s)Errors:.*$`), "Errors: " + MaskReplacement}, // the error list of a Vault API response
+	{regexp.MustCompile(`(?s)\{.*\}`), MaskReplacement},                  // JSON response bodies
+	{regexp.MustCompile(`"(?:[^"\\]|\\.)*"`), `"` + MaskReplacement + `"`},
+	{regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://\S+`), MaskReplacement}, // URLs, which may contain paths or credentials
+}
+
+// DefaultErrorRedactor is the redactor used by WithErrorRedaction(nil). It replaces response bodies, quoted strings,
+// URLs and the error details of Vault responses with MaskReplacement, keeping the rest of the message, such as
+// status codes. The secret ID is outside the redacted part of errors from Get, so it is always kept.
+func DefaultErrorRedactor(msg string) string {
+	for _, r := range redactions {
+		msg = r.re.ReplaceAllString(msg, r.repl)
+	}
+	return msg
+}
+
+// redactedError is an error whose message is passed through redact, unwrapping to the original error
+type redactedError struct {
+	err    error
+	redact func(string) string
+}
+
+func (e *redactedError) Error() string {
+	return e.redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns err with its message redacted if WithErrorRedaction was used
+func (sc *SecretsClient) redactError(err error) error {
+	if err == nil || sc.redact == nil {
+		return err
+	}
+	return &redactedError{err: err, redact: sc.redact}
+}
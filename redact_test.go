@@ -0,0 +1,153 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorRedaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors": ["invalid value \"hunter2\" for field password"]}`))
+	}))
+	defer ts.Close()
+	for _, c := range []struct {
+		ops    []SecretsClientOption
+		redact bool
+	}{
+		{nil, false},
+		{[]SecretsClientOption{WithErrorRedaction(nil)}, true},
+	} {
+		ops := append([]SecretsClientOption{WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None)}, c.ops...)
+		sc, err := NewSecretsClient(ops...)
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		_, err = sc.Get("db")
+		if err == nil {
+			t.Fatalf("get should have failed")
+		}
+		msg := err.Error()
+		if strings.Contains(msg, "hunter2") == c.redact {
+			t.Fatalf("secret-like value should be redacted only with WithErrorRedaction (%v): %v", c.redact, msg)
+		}
+		if !strings.Contains(msg, "secret db") || !strings.Contains(msg, "400") {
+			t.Fatalf("the ID and status should be kept: %v", msg)
+		}
+		var se *SecretError
+		if !errors.As(err, &se) || se.ID != "db" {
+			t.Fatalf("should be a SecretError: %v", err)
+		}
+	}
+}
+
+func TestErrorRedactionCustom(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{}), WithErrorRedaction(func(msg string) string {
+		return strings.ReplaceAll(msg, "SECRET_FOO", "[var]")
+	}))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	_, err = sc.Get("foo")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+	if strings.Contains(err.Error(), "SECRET_FOO") || !strings.Contains(err.Error(), "[var]") {
+		t.Fatalf("error should be redacted: %v", err)
+	}
+}
+
+func TestDefaultErrorRedactor(t *testing.T) {
+	for in, want := range map[string]string{
+		`unexpected status code: 500: {"token": "abc"}`:              `unexpected status code: 500: ***`,
+		`error decoding "s3cret" as JSON`:                            `error decoding "***" as JSON`,
+		`error performing request: Get https://u:p@host/v1/x: EOF`:   `error performing request: Get *** EOF`,
+		"Code: 403. Errors:\n\n* permission denied on secret/app/db": "Code: 403. Errors: ***",
+		`secret not found: SECRET_FOO`:                               `secret not found: SECRET_FOO`,
+	} {
+		if got := DefaultErrorRedactor(in); got != want {
+			t.Fatalf("DefaultErrorRedactor(%q) = %q (expected %q)", in, got, want)
+		}
+	}
+}
+
+// leakyBackend fails every operation with an error quoting a secret path
+type leakyBackend struct{}
+
+var errLeaky = errors.New(`permission denied on "secret/app/hunter2"`)
+
+func (leakyBackend) Get(id string) ([]byte, error)                     { return nil, errLeaky }
+func (leakyBackend) Set(id string, value []byte) error                 { return errLeaky }
+func (leakyBackend) Stat(id string) (SecretInfo, error)                { return SecretInfo{}, errLeaky }
+func (leakyBackend) GetFields(id string) (map[string][]byte, error)    { return nil, errLeaky }
+func (leakyBackend) GetAll() (map[string][]byte, error)                { return nil, errLeaky }
+func (leakyBackend) GetGroup(prefix string) (map[string][]byte, error) { return nil, errLeaky }
+func (leakyBackend) locate(id string) (string, error)                  { return "", errLeaky }
+
+func TestErrorRedactionPublicMethods(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors": ["permission denied on \"secret/app/hunter2\""]}`))
+	}))
+	defer ts.Close()
+	vsc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithErrorRedaction(nil))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	sc := &SecretsClient{backend: leakyBackend{}, cache: newSecretCache(time.Minute), redact: DefaultErrorRedactor}
+	ctx := context.Background()
+	for name, call := range map[string]func() error{
+		"Get":              func() error { _, err := sc.Get("db"); return err },
+		"GetContext":       func() error { _, err := sc.GetContext(ctx, "db"); return err },
+		"GetDetailed":      func() error { _, err := sc.GetDetailed("db"); return err },
+		"GetBatch":         func() error { _, err := sc.GetBatch([]string{"db"}); return err },
+		"Warmup":           func() error { return sc.Warmup(ctx, "db") },
+		"GetCachedInto":    func() error { var v map[string]string; return sc.GetCachedInto("db", &v) },
+		"GetCertificate":   func() error { _, err := sc.GetCertificate("cert", "key"); return err },
+		"GetX509":          func() error { _, err := sc.GetX509("cert"); return err },
+		"GetPKCS12":        func() error { _, err := sc.GetPKCS12("bundle", "pw"); return err },
+		"DeriveKey":        func() error { _, err := sc.DeriveKey("master", nil, 32); return err },
+		"ExecWithSecrets":  func() error { return sc.ExecWithSecrets(exec.Command("true"), map[string]string{"db": "DB"}) },
+		"Export":           func() error { return sc.Export([]string{"db"}, ExportDotenv, io.Discard) },
+		"GetFormatted":     func() error { _, err := sc.GetFormatted("db", Raw); return err },
+		"GetURL":           func() error { _, err := sc.GetURL("db"); return err },
+		"GetBool":          func() error { _, err := sc.GetBool("db"); return err },
+		"GetInt":           func() error { _, err := sc.GetInt("db"); return err },
+		"HashSecret":       func() error { _, err := sc.HashSecret("db"); return err },
+		"CompareSecret":    func() error { _, err := sc.CompareSecret("db", sha256Hex(nil)); return err },
+		"RenderTemplate":   func() error { _, err := sc.RenderTemplate(`{{ secret "db" }}`, []string{"db"}); return err },
+		"DetectCollisions": func() error { _, err := sc.DetectCollisions([]string{"db"}); return err },
+		"GetFields":        func() error { _, err := sc.GetFields("db"); return err },
+		"Set":              func() error { return sc.Set("db", []byte("x")) },
+		"GetAll":           func() error { _, err := sc.GetAll(); return err },
+		"GetGroup":         func() error { _, err := sc.GetGroup("db_"); return err },
+		"List":             func() error { _, err := sc.List(); return err },
+		"Stat":             func() error { _, err := sc.Stat("db"); return err },
+		"OnRotate":         func() error { _, err := sc.OnRotate("db", time.Minute, nil); return err },
+		"LastError":        func() error { return sc.LastError() },
+		"Vault Get":        func() error { _, err := vsc.Get("db"); return err },
+		"Vault GetFields":  func() error { _, err := vsc.GetFields("db"); return err },
+		"GetPath":          func() error { _, err := vsc.GetPath("secret/app"); return err },
+		"GetRaw":           func() error { _, err := vsc.GetRaw("db"); return err },
+		"EncryptTransit":   func() error { _, err := vsc.EncryptTransit("app", []byte("x")); return err },
+		"VaultTokenTTL":    func() error { _, err := vsc.VaultTokenTTL(); return err },
+		"Vault LastError":  func() error { return vsc.LastError() },
+	} {
+		err := call()
+		if err == nil && !strings.Contains(name, "LastError") {
+			t.Fatalf("%v should have failed", name)
+		}
+		if err != nil && strings.Contains(err.Error(), "hunter2") {
+			t.Fatalf("%v error should be redacted: %v", name, err)
+		}
+	}
+}
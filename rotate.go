@@ -0,0 +1,87 @@
+package pvc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RotateMaxFailures is the number of consecutive failed rebuilds after which OnRotate stops watching
+const RotateMaxFailures = 3
+
+// OnRotate polls the backend for secret id every interval, bypassing the cache, and calls rebuild with the previous
+// and new values whenever the value changes, eg to rebuild a connection pool when a password is rotated. If rebuild
+// fails, it is retried with the same values at the next poll; after RotateMaxFailures consecutive failures polling
+// stops. Failed polls are skipped. The value is fetched once before OnRotate returns, and an error is returned if
+// that fails. The returned function stops polling, waiting for any rebuild in progress, and returns the error that
+// stopped it early, if any.
+func (sc *SecretsClient) OnRotate(id string, interval time.Duration, rebuild func(old, new []byte) error) (func() error, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive: %v", interval)
+	}
+	id = sc.prefix + id
+	if err := sc.checkAllowed(id); err != nil {
+		return nil, err
+	}
+	poll := func() ([]byte, error) {
+		ctx := context.Background()
+		if timeout := sc.getTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		v, _, err := sc.fetch(ctx, id)
+		sc.audit(ctx, id, v, err)
+		if err == nil {
+			// the previous value stays masked too, as it may still be in use until rebuild succeeds
+			sc.fetched.add(id)
+			sc.fetched.addValue(v)
+		}
+		return v, err
+	}
+	old, err := poll()
+	if err != nil {
+		return nil, sc.redactError(fmt.Errorf("error getting %v: %w", id, err))
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	var stopErr error
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		failures := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			v, err := poll()
+			if err != nil || bytes.Equal(v, old) {
+				continue
+			}
+			if err := rebuild(old, v); err != nil {
+				failures++
+				if failures >= RotateMaxFailures {
+					stopErr = fmt.Errorf("rebuild after rotation of %v failed %v times in a row: %w", id, failures, err)
+					return
+				}
+				continue
+			}
+			failures = 0
+			old = v
+			if sc.cache != nil {
+				sc.cache.set(id, v, 0)
+			}
+		}
+	}()
+	once := sync.Once{}
+	return func() error {
+		once.Do(func() { close(done) })
+		<-stopped
+		return stopErr
+	}, nil
+}
@@ -0,0 +1,139 @@
+package pvc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rotatingBackend returns the current value for every ID
+type rotatingBackend struct {
+	sync.Mutex
+	value []byte
+}
+
+func (rb *rotatingBackend) Get(id string) ([]byte, error) {
+	rb.Lock()
+	defer rb.Unlock()
+	return rb.value, nil
+}
+
+func (rb *rotatingBackend) rotate(v string) {
+	rb.Lock()
+	defer rb.Unlock()
+	rb.value = []byte(v)
+}
+
+func TestOnRotate(t *testing.T) {
+	rb := &rotatingBackend{value: []byte("v1")}
+	sc := &SecretsClient{backend: rb, cache: newSecretCache(time.Hour)}
+	type change struct{ old, new string }
+	changes := make(chan change, 10)
+	stop, err := sc.OnRotate("db", time.Millisecond, func(old, new []byte) error {
+		changes <- change{string(old), string(new)}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("OnRotate failed: %v", err)
+	}
+	prev := "v1"
+	for _, v := range []string{"v2", "v3"} {
+		rb.rotate(v)
+		select {
+		case c := <-changes:
+			if c.old != prev || c.new != v {
+				t.Fatalf("bad change: %+v (expected %v -> %v)", c, prev, v)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("rebuild wasn't called for %v", v)
+		}
+		prev = v
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("stop should succeed: %v", err)
+	}
+	if v, ok := sc.cache.get("db"); !ok || string(v) != "v3" {
+		t.Fatalf("cache should hold the rotated value: %v", string(v))
+	}
+	select {
+	case c := <-changes:
+		t.Fatalf("unexpected change: %+v", c)
+	default:
+	}
+}
+
+func TestOnRotateRebuildFailures(t *testing.T) {
+	rb := &rotatingBackend{value: []byte("v1")}
+	sc := &SecretsClient{backend: rb}
+	calls := make(chan struct{}, 10)
+	stop, err := sc.OnRotate("db", time.Millisecond, func(old, new []byte) error {
+		calls <- struct{}{}
+		return errors.New("pool rebuild failed")
+	})
+	if err != nil {
+		t.Fatalf("OnRotate failed: %v", err)
+	}
+	rb.rotate("v2")
+	for i := 0; i < RotateMaxFailures; i++ {
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			t.Fatalf("rebuild should have been retried")
+		}
+	}
+	if err := stop(); err == nil {
+		t.Fatalf("stop should report the rebuild failures")
+	}
+	if len(calls) != 0 {
+		t.Fatalf("rebuild shouldn't be called after %v failures", RotateMaxFailures)
+	}
+}
+
+func TestOnRotateInitialGetFails(t *testing.T) {
+	sc := &SecretsClient{backend: delayedBackend{}}
+	if _, err := sc.OnRotate("missing", time.Second, func(old, new []byte) error { return nil }); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestOnRotateAuditAndMask(t *testing.T) {
+	mb := NewMemoryBackend(map[string][]byte{"db": []byte("first-pass")})
+	rs := &recordingSink{}
+	sc, err := NewSecretsClient(WithMemoryBackend(mb), WithAuditSink(rs.record))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	rebuilt := make(chan struct{}, 1)
+	stop, err := sc.OnRotate("db", time.Millisecond, func(old, new []byte) error {
+		rebuilt <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("OnRotate failed: %v", err)
+	}
+	mb.Set("db", []byte("second-pass"))
+	select {
+	case <-rebuilt:
+	case <-time.After(time.Second):
+		t.Fatalf("rebuild wasn't called")
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("stop should succeed: %v", err)
+	}
+	if len(rs.events) < 2 {
+		t.Fatalf("polls should be audited: %+v", rs.events)
+	}
+	for _, e := range rs.events {
+		if e.ID != "db" || !e.Success {
+			t.Fatalf("bad audit event: %+v", e)
+		}
+	}
+	m, err := sc.Masker()
+	if err != nil {
+		t.Fatalf("error getting Masker: %v", err)
+	}
+	if s := m.Mask("first-pass second-pass"); s != "*** ***" {
+		t.Fatalf("old and new values should be masked: %q", s)
+	}
+}
@@ -0,0 +1,119 @@
+package pvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// route is a prefix of secret IDs and the options of the client that serves them (see WithRoute)
+type route struct {
+	prefix string
+	ops    []SecretsClientOption
+	client *SecretsClient
+}
+
+// routerBackend delegates each Get to the client of the route matching the ID
+type routerBackend struct {
+	routes       []route // longest prefix first
+	fallback     Backend
+	fallbackName string
+}
+
+func newRouterBackend(routes []route, fallback Backend, fallbackName string) (*routerBackend, error) {
+	rb := &routerBackend{fallback: fallback, fallbackName: fallbackName}
+	seen := map[string]bool{}
+	for _, r := range routes {
+		if r.prefix == "" {
+			return nil, fmt.Errorf("route prefix must not be empty")
+		}
+		if seen[r.prefix] {
+			return nil, fmt.Errorf("duplicate route: %q", r.prefix)
+		}
+		seen[r.prefix] = true
+		sc, err := NewSecretsClient(r.ops...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating client for route %q: %w", r.prefix, err)
+		}
+		r.client = sc
+		rb.routes = append(rb.routes, r)
+	}
+	sort.SliceStable(rb.routes, func(i, j int) bool {
+		return len(rb.routes[i].prefix) > len(rb.routes[j].prefix)
+	})
+	return rb, nil
+}
+
+// match returns the route for id and id without the route's prefix, or nil if no route matches
+func (rb *routerBackend) match(id string) (*route, string) {
+	for i := range rb.routes {
+		if strings.HasPrefix(id, rb.routes[i].prefix) {
+			return &rb.routes[i], strings.TrimPrefix(id, rb.routes[i].prefix)
+		}
+	}
+	return nil, id
+}
+
+func (rb *routerBackend) Get(id string) ([]byte, error) {
+	return rb.GetContext(context.Background(), id)
+}
+
+func (rb *routerBackend) GetContext(ctx context.Context, id string) ([]byte, error) {
+	r, rid := rb.match(id)
+	switch {
+	case r != nil:
+		v, err := r.client.GetContext(ctx, rid)
+		// the route's client reports the error as a SecretError, which this client wraps again
+		var se *SecretError
+		if errors.As(err, &se) {
+			return nil, r.client.redactError(se.Err)
+		}
+		return v, err
+	case rb.fallback == nil:
+		return nil, fmt.Errorf("%w: %v", ErrNoRoute, id)
+	}
+	if cb, ok := rb.fallback.(ContextBackend); ok {
+		return cb.GetContext(ctx, id)
+	}
+	return rb.fallback.Get(id)
+}
+
+// backendName returns the name of the backend id is routed to, eg for audit events
+func (rb *routerBackend) backendName(id string) string {
+	r, rid := rb.match(id)
+	switch {
+	case r != nil:
+		return r.client.backendFor(rid)
+	case rb.fallback != nil:
+		return rb.fallbackName
+	}
+	return routerBackendName
+}
+
+// backendFor returns the name of the backend that serves id, which differs from the client's backend name if routes
+// are configured (see WithRoute)
+func (sc *SecretsClient) backendFor(id string) string {
+	if rb, ok := sc.backend.(*routerBackend); ok {
+		return rb.backendName(id)
+	}
+	return sc.backendName
+}
+
+// locate returns the location of id in the backend it is routed to
+func (rb *routerBackend) locate(id string) (string, error) {
+	b := rb.fallback
+	r, rid := rb.match(id)
+	switch {
+	case r != nil:
+		b = r.client.backend
+	case b == nil:
+		return "", fmt.Errorf("%w: %v", ErrNoRoute, id)
+	}
+	sl, ok := b.(secretLocator)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return sl.locate(rid)
+}
@@ -0,0 +1,83 @@
+package pvc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "from vault"}}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(
+		WithRoute("vault/", WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None)),
+		WithRoute("env/", WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_DB": "from env"})),
+		WithRoute("env/other/", WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_DB": "from other env"})),
+	)
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for id, want := range map[string]string{"vault/db": "from vault", "env/db": "from env", "env/other/db": "from other env"} {
+		v, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get %v failed: %v", id, err)
+		}
+		if string(v) != want {
+			t.Fatalf("bad value for %v: %v", id, string(v))
+		}
+	}
+	_, err = sc.Get("vault/missing")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+	// the error names the backend of the route, and isn't wrapped again by the router
+	if msg := err.Error(); !strings.HasPrefix(msg, "error getting secret vault/missing from vault backend: ") || strings.Count(msg, "error getting secret") != 1 {
+		t.Fatalf("bad error: %v", msg)
+	}
+	if _, err := sc.Get("db"); !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("should have returned ErrNoRoute: %v", err)
+	}
+	r, err := sc.GetDetailed("vault/db")
+	if err != nil || r.ResolvedPath != "secret/db" || r.Backend != "vault" {
+		t.Fatalf("bad result: %+v: %v", r, err)
+	}
+}
+
+func TestRouterFallback(t *testing.T) {
+	rs := &recordingSink{}
+	sc, err := NewSecretsClient(
+		WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_DB": "default"}),
+		WithRoute("json/", WithJSONFileBackend(), WithJSONFileLocation("example/secrets.json")),
+		WithAuditSink(rs.record),
+	)
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	v, err := sc.Get("db")
+	if err != nil || string(v) != "default" {
+		t.Fatalf("unrouted ID should use the default backend: %v: %v", string(v), err)
+	}
+	if _, err := sc.Get("json/foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if len(rs.events) != 2 || rs.events[0].Backend != "envvar" || rs.events[1].Backend != "jsonfile" {
+		t.Fatalf("audit events should name the backend that served each ID: %+v", rs.events)
+	}
+}
+
+func TestRouterInvalidRoute(t *testing.T) {
+	if _, err := NewSecretsClient(WithRoute("env/")); !errors.Is(err, ErrNoBackendConfigured) {
+		t.Fatalf("route without a backend should fail: %v", err)
+	}
+	if _, err := NewSecretsClient(WithRoute("env/", WithEnvVarBackend()), WithRoute("env/", WithEnvVarBackend())); err == nil {
+		t.Fatalf("duplicate routes should fail")
+	}
+}
@@ -0,0 +1,28 @@
+package pvc
+
+import (
+	"bytes"
+	"context"
+)
+
+// ShadowMismatch reports a secret whose value from the shadow backend differs from the primary's (see
+// WithShadowBackend). Neither value is included.
+type ShadowMismatch struct {
+	ID  string // ID of the secret, including any prefix (see Scoped)
+	Err error  // set if the shadow read failed, eg with ErrSecretNotFound if the secret hasn't been migrated
+}
+
+// shadowRead compares value, just fetched from the primary backend for id, with the shadow backend's value in the
+// background, reporting any difference to the mismatch handler
+func (sc *SecretsClient) shadowRead(id string, value []byte) {
+	value = append([]byte(nil), value...)
+	go func() {
+		sv, err := sc.shadow.GetContext(context.Background(), id)
+		switch {
+		case err != nil:
+			sc.onMismatch(ShadowMismatch{ID: id, Err: err})
+		case !bytes.Equal(sv, value):
+			sc.onMismatch(ShadowMismatch{ID: id})
+		}
+	}()
+}
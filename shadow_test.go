@@ -0,0 +1,76 @@
+package pvc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShadowBackend(t *testing.T) {
+	mismatches := make(chan ShadowMismatch, 10)
+	shadow := NewMemoryBackend(map[string][]byte{"same": []byte("foo"), "different": []byte("new")})
+	sc, err := NewSecretsClient(
+		WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_SAME": "foo", "SECRET_DIFFERENT": "old", "SECRET_UNMIGRATED": "bar"}),
+		WithShadowBackend(WithMemoryBackend(shadow)),
+		WithShadowMismatchHandler(func(m ShadowMismatch) { mismatches <- m }),
+	)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	for id, want := range map[string]string{"same": "foo", "different": "old", "unmigrated": "bar"} {
+		v, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(v) != want {
+			t.Fatalf("the primary value should be returned for %v: %v", id, string(v))
+		}
+	}
+	got := map[string]ShadowMismatch{}
+	for len(got) < 2 {
+		select {
+		case m := <-mismatches:
+			got[m.ID] = m
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for mismatches, got %v", got)
+		}
+	}
+	if m, ok := got["different"]; !ok || m.Err != nil {
+		t.Fatalf("expected a mismatch for different: %+v", got)
+	}
+	if m, ok := got["unmigrated"]; !ok || !errors.Is(m.Err, ErrSecretNotFound) {
+		t.Fatalf("expected a not found mismatch for unmigrated: %+v", got)
+	}
+	select {
+	case m := <-mismatches:
+		t.Fatalf("unexpected mismatch: %+v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestShadowBackendDoesNotBlock(t *testing.T) {
+	shadow := NewMemoryBackend(map[string][]byte{"foo": []byte("bar")})
+	shadow.SetLatency("foo", time.Minute)
+	sc, err := NewSecretsClient(
+		WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}),
+		WithShadowBackend(WithMemoryBackend(shadow)),
+		WithShadowMismatchHandler(func(m ShadowMismatch) {}),
+	)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	start := time.Now()
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("get should not wait for the shadow read: %v", d)
+	}
+}
+
+func TestShadowBackendRequiresHandler(t *testing.T) {
+	_, err := NewSecretsClient(WithEnvVarBackend(), WithShadowBackend(WithMemoryBackend(NewMemoryBackend(nil))))
+	if err == nil {
+		t.Fatalf("should have failed without a mismatch handler")
+	}
+}
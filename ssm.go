@@ -0,0 +1,209 @@
+package pvc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Default mapping for this backend
+const (
+	DefaultSSMMapping = "{{ .ID }}"
+)
+
+// ssmIO is the subset of the SSM API used by the backend
+type ssmIO interface {
+	// GetParameter returns the value of the parameter name, or ErrSecretNotFound if it doesn't exist
+	GetParameter(ctx context.Context, name string, decrypt bool) (string, error)
+}
+
+type ssmBackendGetter struct {
+	client ssmIO
+	mapper SecretMapper
+	config *ssmBackend
+}
+
+func newSSMBackendGetter(sb *ssmBackend) (*ssmBackendGetter, error) {
+	if sb.mapping == "" {
+		sb.mapping = DefaultSSMMapping
+	}
+	sm, err := newSecretMapper(sb.mapping, sb.mappingRules...)
+	if err != nil {
+		return nil, fmt.Errorf("error with mapping: %v", err)
+	}
+	if sb.client == nil {
+		c, err := newSSMClient(sb.region)
+		if err != nil {
+			return nil, err
+		}
+		sb.client = c
+	}
+	return &ssmBackendGetter{
+		client: sb.client,
+		mapper: sm,
+		config: sb,
+	}, nil
+}
+
+// locate returns the name of the parameter holding id
+func (sbg *ssmBackendGetter) locate(id string) (string, error) {
+	name, err := sbg.mapper.MapSecret(id)
+	if err != nil {
+		return "", fmt.Errorf("error mapping id to parameter: %v", err)
+	}
+	return name, nil
+}
+
+func (sbg *ssmBackendGetter) Get(id string) ([]byte, error) {
+	return sbg.GetContext(context.Background(), id)
+}
+
+func (sbg *ssmBackendGetter) GetContext(ctx context.Context, id string) ([]byte, error) {
+	name, err := sbg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := sbg.client.GetParameter(ctx, name, !sbg.config.skipDecrypt)
+	if err != nil {
+		return nil, fmt.Errorf("error getting parameter %v: %w", name, err)
+	}
+	return []byte(v), nil
+}
+
+// ssmClient calls the SSM JSON API directly, signing requests with AWS Signature Version 4
+type ssmClient struct {
+	httpClient   *http.Client
+	endpoint     string // eg, https://ssm.us-east-1.amazonaws.com
+	region       string
+	accessKeyID  string
+	secretKey    string
+	sessionToken string
+	now          func() time.Time
+}
+
+func newSSMClient(region string) (*ssmClient, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("AWS region is required")
+	}
+	c := &ssmClient{
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+		endpoint:     "https://ssm." + region + ".amazonaws.com",
+		region:       region,
+		accessKeyID:  os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		now:          time.Now,
+	}
+	if c.accessKeyID == "" || c.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	return c, nil
+}
+
+func (c *ssmClient) GetParameter(ctx context.Context, name string, decrypt bool) (string, error) {
+	body, err := json.Marshal(struct {
+		Name           string
+		WithDecryption bool
+	}{Name: name, WithDecryption: decrypt})
+	if err != nil {
+		return "", fmt.Errorf("error encoding request: %v", err)
+	}
+	req, err := http.NewRequest("POST", c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	c.sign(req, body)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error performing request: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}{}
+		json.Unmarshal(b, &e)
+		// the type may be namespaced, eg "com.amazonaws.ssm#ParameterNotFound"
+		if e.Type[strings.LastIndex(e.Type, "#")+1:] == "ParameterNotFound" {
+			return "", ErrSecretNotFound
+		}
+		return "", fmt.Errorf("unexpected status code from SSM: %v: %v %v", resp.StatusCode, e.Type, e.Message)
+	}
+	out := struct {
+		Parameter struct {
+			Value string
+		}
+	}{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return "", fmt.Errorf("error decoding response: %v", err)
+	}
+	return out.Parameter.Value, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to req, whose body is body
+func (c *ssmClient) sign(req *http.Request, body []byte) {
+	t := c.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+	// signed header names must be sorted
+	headers := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if c.sessionToken != "" {
+		headers = []string{"content-type", "host", "x-amz-date", "x-amz-security-token", "x-amz-target"}
+	}
+	canonical := &strings.Builder{}
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonical.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signed := strings.Join(headers, ";")
+	creq := strings.Join([]string{req.Method, "/", "", canonical.String(), signed, sha256Hex(body)}, "\n")
+	scope := date + "/" + c.region + "/ssm/aws4_request"
+	sts := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(creq))}, "\n")
+	key := []byte("AWS4" + c.secretKey)
+	for _, s := range []string{date, c.region, "ssm", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, sts))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v", c.accessKeyID, scope, signed, sig))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
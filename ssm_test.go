@@ -0,0 +1,108 @@
+package pvc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSSMServer mimics the SSM GetParameter API for a single SecureString parameter
+func newTestSSMServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AmazonSSM.GetParameter" {
+			t.Errorf("bad target: %v", r.Header.Get("X-Amz-Target"))
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/20200102/us-west-2/ssm/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature=") {
+			t.Errorf("bad authorization: %v", r.Header.Get("Authorization"))
+		}
+		in := struct {
+			Name           string
+			WithDecryption bool
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Errorf("error decoding request: %v", err)
+		}
+		if in.Name != "/myapp/db/password" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ParameterNotFound","message":""}`))
+			return
+		}
+		v := "AQICAHh-encrypted"
+		if in.WithDecryption {
+			v = "hunter2"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Parameter": map[string]interface{}{"Name": in.Name, "Type": "SecureString", "Value": v},
+		})
+	}))
+}
+
+func testSSMClient(url string) *ssmClient {
+	return &ssmClient{
+		httpClient:  &http.Client{},
+		endpoint:    url,
+		region:      "us-west-2",
+		accessKeyID: "AKID",
+		secretKey:   "secret",
+		now:         func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+}
+
+func TestSSMBackendGetterSecureString(t *testing.T) {
+	s := newTestSSMServer(t)
+	defer s.Close()
+	for _, decrypt := range []bool{true, false} {
+		sbg, err := newSSMBackendGetter(&ssmBackend{client: testSSMClient(s.URL), skipDecrypt: !decrypt, mapping: "/myapp/{{ .ID }}"})
+		if err != nil {
+			t.Fatalf("should have succeeded: %v", err)
+		}
+		v, err := sbg.Get("db/password")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if want := map[bool]string{true: "hunter2", false: "AQICAHh-encrypted"}[decrypt]; string(v) != want {
+			t.Fatalf("bad value with decrypt %v: %q", decrypt, v)
+		}
+	}
+}
+
+func TestSSMBackendGetterNotFound(t *testing.T) {
+	s := newTestSSMServer(t)
+	defer s.Close()
+	sbg, err := newSSMBackendGetter(&ssmBackend{client: testSSMClient(s.URL)})
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	_, err = sbg.Get("missing")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestSSMClientSessionToken(t *testing.T) {
+	c := testSSMClient("https://ssm.us-west-2.amazonaws.com")
+	c.sessionToken = "token"
+	req, _ := http.NewRequest("POST", c.endpoint+"/", nil)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	c.sign(req, []byte("{}"))
+	if req.Header.Get("X-Amz-Security-Token") != "token" || !strings.Contains(req.Header.Get("Authorization"), "x-amz-date;x-amz-security-token;x-amz-target") {
+		t.Fatalf("session token should be sent and signed: %v", req.Header)
+	}
+}
+
+func TestNewSSMClientMissingConfig(t *testing.T) {
+	for _, k := range []string{"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+		t.Setenv(k, "")
+	}
+	if _, err := newSSMClient(""); err == nil {
+		t.Fatalf("should have failed without region")
+	}
+	if _, err := newSSMClient("us-west-2"); err == nil {
+		t.Fatalf("should have failed without credentials")
+	}
+}
@@ -0,0 +1,46 @@
+package pvc
+
+import (
+	"errors"
+	"time"
+)
+
+// SecretInfo describes a secret without its value
+type SecretInfo struct {
+	Exists  bool
+	Size    int64     // length of the value in bytes (for file-based backends, of the file holding it)
+	ModTime time.Time // when the value was last modified, if the backend knows (otherwise zero)
+}
+
+// statSecretBackend is implemented by backends that can describe a secret without returning its value
+type statSecretBackend interface {
+	Stat(id string) (SecretInfo, error)
+}
+
+// Stat returns the size and modification time of a secret, eg so that tools syncing secrets can skip unchanged ones.
+// A secret that doesn't exist is reported with Exists false rather than an error. The Docker secrets, JSON file and
+// environment variable backends support this, others return ErrNotSupported.
+func (sc *SecretsClient) Stat(id string) (SecretInfo, error) {
+	id = sc.prefix + id
+	sb, ok := sc.backend.(statSecretBackend)
+	if !ok {
+		return SecretInfo{}, ErrNotSupported
+	}
+	if err := sc.checkAllowed(id); err != nil {
+		return SecretInfo{}, err
+	}
+	info, err := sb.Stat(id)
+	return info, sc.redactError(err)
+}
+
+// statValue returns the SecretInfo of a secret held in memory, which is get(id)
+func statValue(get func(id string) ([]byte, error), id string, modTime time.Time) (SecretInfo, error) {
+	v, err := get(id)
+	switch {
+	case errors.Is(err, ErrSecretNotFound):
+		return SecretInfo{}, nil
+	case err != nil:
+		return SecretInfo{}, err
+	}
+	return SecretInfo{Exists: true, Size: int64(len(v)), ModTime: modTime}, nil
+}
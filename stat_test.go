@@ -0,0 +1,91 @@
+package pvc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatDockerSecrets(t *testing.T) {
+	dir := testDockerSecretsDir(t)
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "db_password"), modTime, modTime); err != nil {
+		t.Fatalf("error setting mod time: %v", err)
+	}
+	sc, err := NewSecretsClient(WithDockerSecretsBackend(), WithDockerSecretsDir(dir))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	si, err := sc.Stat("db_password")
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if !si.Exists || si.Size != int64(len("hunter2\n")) || !si.ModTime.Equal(modTime) {
+		t.Fatalf("bad info: %+v", si)
+	}
+	si, err = sc.Stat("missing")
+	if err != nil {
+		t.Fatalf("stat of missing secret failed: %v", err)
+	}
+	if si.Exists {
+		t.Fatalf("missing secret shouldn't exist: %+v", si)
+	}
+}
+
+func TestStatJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := ioutil.WriteFile(path, []byte(`{"foo": "bar"}`), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("error setting mod time: %v", err)
+	}
+	sc, err := NewSecretsClient(WithJSONFileBackend(), WithJSONFileLocation(path))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	si, err := sc.Stat("foo")
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if !si.Exists || si.Size != 3 || !si.ModTime.Equal(modTime) {
+		t.Fatalf("bad info: %+v", si)
+	}
+	if err := sc.Set("foo", []byte("longer")); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	si, err = sc.Stat("foo")
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if si.Size != 6 || !si.ModTime.After(modTime) {
+		t.Fatalf("info should reflect the value set: %+v", si)
+	}
+}
+
+func TestStatEnvVar(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend(), WithEnvMap(map[string]string{"SECRET_FOO": "bar"}))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	si, err := sc.Stat("foo")
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if !si.Exists || si.Size != 3 || !si.ModTime.IsZero() {
+		t.Fatalf("bad info: %+v", si)
+	}
+}
+
+func TestStatNotSupported(t *testing.T) {
+	sc, err := NewSecretsClient(WithNoopBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Stat("foo"); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package pvc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// RenderTemplate renders templateText (a text/template) with a secret function returning the value of a secret:
+//
+//	password: {{ secret "db_password" }}
+//
+// The secrets in ids are fetched before rendering and are the only ones the template may reference. All fetch errors
+// are reported together; errors never include secret values.
+func (sc *SecretsClient) RenderTemplate(templateText string, ids []string) (string, error) {
+	values := make(map[string]string, len(ids))
+	errs := []string{}
+	for _, id := range ids {
+		v, err := sc.Get(id)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", id, err))
+			continue
+		}
+		values[id] = string(v)
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return "", fmt.Errorf("error fetching secrets: %v", strings.Join(errs, "; "))
+	}
+	tmpl, err := template.New("secrets").Option("missingkey=error").Funcs(template.FuncMap{
+		"secret": func(id string) (string, error) {
+			v, ok := values[id]
+			if !ok {
+				return "", fmt.Errorf("secret %q not in the list of IDs to render", id)
+			}
+			return v, nil
+		},
+	}).Parse(templateText)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template: %v", err)
+	}
+	b := &strings.Builder{}
+	if err := tmpl.Execute(b, nil); err != nil {
+		return "", fmt.Errorf("error rendering template: %v", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,60 @@
+package pvc
+
+import (
+	"strings"
+	"testing"
+)
+
+func testTemplateSecretsClient(t *testing.T) *SecretsClient {
+	sc, err := NewSecretsClient(WithEnvMap(map[string]string{
+		"SECRET_DB_USER":     "admin",
+		"SECRET_DB_PASSWORD": "pa55w0rd",
+		"SECRET_API_KEY":     "k3y",
+	}), WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	return sc
+}
+
+func TestRenderTemplate(t *testing.T) {
+	sc := testTemplateSecretsClient(t)
+	tmpl := `db: {{ secret "db_user" }}:{{ secret "db_password" }}
+api_key: {{ secret "api_key" | printf "%q" }}
+`
+	out, err := sc.RenderTemplate(tmpl, []string{"db_user", "db_password", "api_key"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	expected := "db: admin:pa55w0rd\napi_key: \"k3y\"\n"
+	if out != expected {
+		t.Fatalf("bad output: %q (expected %q)", out, expected)
+	}
+}
+
+func TestRenderTemplateFetchErrors(t *testing.T) {
+	sc := testTemplateSecretsClient(t)
+	_, err := sc.RenderTemplate(`{{ secret "db_password" }}`, []string{"db_password", "missing1", "missing2"})
+	if err == nil {
+		t.Fatalf("should have failed")
+	}
+	for _, s := range []string{"missing1", "missing2"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("error should report %v: %v", s, err)
+		}
+	}
+	if strings.Contains(err.Error(), "pa55w0rd") {
+		t.Fatalf("error should not contain secret values: %v", err)
+	}
+}
+
+func TestRenderTemplateUnlistedID(t *testing.T) {
+	sc := testTemplateSecretsClient(t)
+	_, err := sc.RenderTemplate(`{{ secret "db_user" }} {{ secret "db_password" }}`, []string{"db_user"})
+	if err == nil {
+		t.Fatalf("should have failed for an ID not in the list")
+	}
+	if strings.Contains(err.Error(), "admin") || strings.Contains(err.Error(), "pa55w0rd") {
+		t.Fatalf("error should not contain secret values: %v", err)
+	}
+}
@@ -1,11 +1,22 @@
 package pvc
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/vault/api"
@@ -16,18 +27,29 @@ const (
 	DefaultVaultMapping = "secret/{{ .ID }}"
 )
 
+// DefaultVaultMinTLSVersion is the minimum TLS version used for connections to Vault by default
+const DefaultVaultMinTLSVersion = tls.VersionTLS12
+
+// DefaultVaultTokenLookupCacheTTL is how long a token lookup-self result is reused by default
+const DefaultVaultTokenLookupCacheTTL = 10 * time.Second
+
 // VaultAuthentication enumerates the supported Vault authentication methods
 type VaultAuthentication int
 
 // Various Vault authentication methods
 const (
-	None    VaultAuthentication = iota // No authentication at all
-	AppID                              // AppID
-	Token                              // Token authentication
-	AppRole                            // AppRole
-	K8s                                // Kubernetes
+	None       VaultAuthentication = iota // No authentication at all
+	AppID                                 // AppID
+	Token                                 // Token authentication
+	AppRole                               // AppRole
+	K8s                                   // Kubernetes
+	VaultAgent                            // Token read from a Vault Agent auto-auth sink file
+	JWT                                   // JWT/OIDC (eg, a CI or cloud identity token)
 )
 
+// DefaultVaultJWTAuthPath is the mount path of the JWT auth method used by default
+const DefaultVaultJWTAuthPath = "jwt"
+
 type vaultBackendGetter struct {
 	vc     vaultIO
 	mapper SecretMapper
@@ -55,17 +77,67 @@ func newVaultBackendGetter(vb *vaultBackend, vc vaultIO) (*vaultBackendGetter, e
 	case AppRole:
 		return nil, fmt.Errorf("AppRole authentication not implemented")
 	case K8s:
-		err = vc.K8sAuth(vb.k8sjwt, vb.roleid)
+		jwt := vb.k8sjwt
+		if jwt == "" {
+			path := vb.k8sTokenPath
+			if path == "" && vb.k8sTokenAudience != "" {
+				path = filepath.Join(DefaultVaultK8sTokenDir, vb.k8sTokenAudience)
+			}
+			if path == "" {
+				return nil, fmt.Errorf("Kubernetes JWT, token path or audience is required for Kubernetes authentication")
+			}
+			jwt, err = readJWT(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := checkJWTAudience(jwt, vb.k8sTokenAudience); err != nil {
+			return nil, err
+		}
+		err = vc.K8sAuth(jwt, vb.roleid)
 		if err != nil {
 			return nil, fmt.Errorf("error performing Kubernetes authentication: %v", err)
 		}
+	case JWT:
+		jwt, aud := vb.jwt, vb.jwtAudience
+		switch {
+		case vb.githubOIDC:
+			aud = vb.githubOIDCAudience
+			jwt, err = githubOIDCToken(aud)
+			if err != nil {
+				return nil, err
+			}
+		case vb.jwtPath != "":
+			jwt, err = readJWT(vb.jwtPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if jwt == "" || vb.jwtRole == "" {
+			return nil, fmt.Errorf("JWT and role are required for JWT authentication")
+		}
+		if err := checkJWTAudience(jwt, aud); err != nil {
+			return nil, err
+		}
+		err = vc.JWTAuth(jwt, vb.jwtRole)
+		if err != nil {
+			return nil, fmt.Errorf("error performing JWT authentication: %v", err)
+		}
+	case VaultAgent:
+		if vb.agentTokenSink == "" {
+			return nil, fmt.Errorf("Vault Agent token sink path is required")
+		}
+		err = vc.AgentTokenSinkAuth(vb.agentTokenSink)
+		if err != nil {
+			return nil, fmt.Errorf("error authenticating with Vault Agent token: %v", err)
+		}
 	default:
 		return nil, fmt.Errorf("unknown authentication method: %v", vb.authentication)
 	}
 	if vb.mapping == "" {
 		vb.mapping = DefaultVaultMapping
 	}
-	sm, err := newSecretMapper(vb.mapping)
+	sm, err := newSecretMapper(vb.mapping, vb.mappingRules...)
 	if err != nil {
 		return nil, fmt.Errorf("error with mapping: %v", err)
 	}
@@ -76,33 +148,284 @@ func newVaultBackendGetter(vb *vaultBackend, vc vaultIO) (*vaultBackendGetter, e
 	}, nil
 }
 
-func (vbg *vaultBackendGetter) Get(id string) ([]byte, error) {
+// locate returns the Vault path holding id
+func (vbg *vaultBackendGetter) locate(id string) (string, error) {
 	path, err := vbg.mapper.MapSecret(id)
 	if err != nil {
-		return nil, fmt.Errorf("error mapping id to path: %v", err)
+		return "", fmt.Errorf("error mapping id to path: %v", err)
+	}
+	return vbg.normalize(path), nil
+}
+
+// normalize strips leading and trailing slashes from the mapped path and collapses repeated ones, which Vault would reject
+func (vbg *vaultBackendGetter) normalize(path string) string {
+	return joinPath(path)
+}
+
+// joinPath joins parts with slashes into a Vault path, collapsing repeated slashes (including those at the edges of
+// parts) and dropping empty segments and leading and trailing slashes, so that eg joinPath("secret/", "/foo//bar/")
+// is "secret/foo/bar". Every Vault API path is built with it.
+func joinPath(parts ...string) string {
+	segments := []string{}
+	for _, p := range parts {
+		for _, s := range strings.Split(p, "/") {
+			if s != "" {
+				segments = append(segments, s)
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// apiPath returns the path of the Vault API endpoint joined from parts, eg "/v1/sys/seal-status"
+func apiPath(parts ...string) string {
+	return "/" + joinPath(append([]string{"v1"}, parts...)...)
+}
+
+func (vbg *vaultBackendGetter) Get(id string) ([]byte, error) {
+	path, err := vbg.locate(id)
+	if err != nil {
+		return nil, err
 	}
 	v, err := vbg.vc.GetStringValue(path)
 	if err != nil {
-		return nil, fmt.Errorf("error reading value: %v", err)
+		return nil, fmt.Errorf("error reading value: %w", err)
 	}
 	return []byte(v), nil
 }
 
+func (vbg *vaultBackendGetter) GetContext(ctx context.Context, id string) ([]byte, error) {
+	v, _, err := vbg.getWithTTL(ctx, id)
+	return v, err
+}
+
+func (vbg *vaultBackendGetter) getWithTTL(ctx context.Context, id string) ([]byte, time.Duration, error) {
+	path, err := vbg.locate(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return vbg.getPathWithTTL(ctx, id, path)
+}
+
+// getPathWithTTL returns the value of the secret id stored at path, along with the TTL suggested by Vault (see
+// getWithTTL). It is used by other backends that read secrets from Vault, such as the federated backend.
+func (vbg *vaultBackendGetter) getPathWithTTL(ctx context.Context, id, path string) ([]byte, time.Duration, error) {
+	v, ttl, err := vbg.vc.GetStringValueWithTTL(context.WithValue(ctx, secretIDKey{}, id), path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading value: %w", err)
+	}
+	return []byte(v), ttl, nil
+}
+
+// GetFields returns every field of the secret id as a map of field name to value, in a single request.
+// Non-string field values are returned JSON-encoded.
+func (vbg *vaultBackendGetter) GetFields(id string) (map[string][]byte, error) {
+	path, err := vbg.locate(id)
+	if err != nil {
+		return nil, err
+	}
+	return vbg.getPath(path)
+}
+
+// getPath returns every field of the secret at path (see GetFields)
+func (vbg *vaultBackendGetter) getPath(path string) (map[string][]byte, error) {
+	vals, err := vbg.vc.GetValues(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading values: %w", err)
+	}
+	fields := make(map[string][]byte, len(vals))
+	for k, v := range vals {
+		switch v := v.(type) {
+		case string:
+			fields[k] = []byte(v)
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding field %v: %v", k, err)
+			}
+			fields[k] = b
+		}
+	}
+	return fields, nil
+}
+
+// GetPath returns every field of the Vault secret at path, as GetFields does for a secret ID, eg to read many values
+// stored under one KV version 2 secret in a single request. path is used as is, without the mapping or any Scoped
+// prefix, so it can't be used with WithAllowedIDs. Backends other than Vault return ErrNotSupported.
+func (sc *SecretsClient) GetPath(path string) (map[string][]byte, error) {
+	vbg, ok := sc.backend.(*vaultBackendGetter)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	if len(sc.allowedIDs) > 0 {
+		return nil, fmt.Errorf("%w: GetPath can't be used with an allowlist", ErrIDNotAllowed)
+	}
+	release, err := sc.acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	path = joinPath(path)
+	fields, err := vbg.getPath(path)
+	sc.audit(context.Background(), path, nil, err)
+	if err != nil {
+		return nil, sc.redactError(err)
+	}
+	// the fields can't be fetched again by ID, so Masker is given their values
+	for _, v := range fields {
+		sc.fetched.addValue(v)
+	}
+	return fields, nil
+}
+
+// SetVaultToken replaces the Vault token used by subsequent requests, eg when it has been renewed by an external process.
+// The token is not checked for validity. It returns ErrNotSupported for other backends.
+func (sc *SecretsClient) SetVaultToken(token string) error {
+	vbg, ok := sc.backend.(*vaultBackendGetter)
+	if !ok {
+		return ErrNotSupported
+	}
+	vbg.vc.SetToken(token)
+	return nil
+}
+
+// VaultResponse is the decoded response from Vault for a secret, as returned by GetRaw
+type VaultResponse struct {
+	Data          map[string]interface{} // as returned by Vault, not unwrapped for KV version 2
+	Auth          *VaultAuth
+	LeaseID       string
+	LeaseDuration int // seconds
+	Renewable     bool
+	Warnings      []string
+	Header        http.Header
+}
+
+// VaultAuth is the authentication information in a VaultResponse
+type VaultAuth struct {
+	ClientToken   string
+	Accessor      string
+	Policies      []string
+	Metadata      map[string]string
+	LeaseDuration int // seconds
+	Renewable     bool
+}
+
+// GetRaw returns the full response from Vault for the secret id, eg to access warnings or data GetFields doesn't expose.
+// Backends other than Vault return ErrNotSupported.
+func (sc *SecretsClient) GetRaw(id string) (*VaultResponse, error) {
+	vbg, ok := sc.backend.(*vaultBackendGetter)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	id = sc.prefix + id
+	if err := sc.checkAllowed(id); err != nil {
+		return nil, err
+	}
+	path, err := vbg.locate(id)
+	if err != nil {
+		return nil, sc.redactError(err)
+	}
+	release, err := sc.acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	s, h, err := vbg.vc.GetRaw(path)
+	sc.audit(context.Background(), id, nil, err)
+	if err != nil {
+		return nil, sc.redactError(fmt.Errorf("error reading value: %w", err))
+	}
+	for _, v := range secretFields(s.Data) {
+		switch v := v.(type) {
+		case string:
+			sc.fetched.addValue([]byte(v))
+		default:
+			if b, err := json.Marshal(v); err == nil {
+				sc.fetched.addValue(b)
+			}
+		}
+	}
+	resp := &VaultResponse{
+		Data:          s.Data,
+		LeaseID:       s.LeaseID,
+		LeaseDuration: s.LeaseDuration,
+		Renewable:     s.Renewable,
+		Warnings:      s.Warnings,
+		Header:        h,
+	}
+	if s.Auth != nil {
+		resp.Auth = &VaultAuth{
+			ClientToken:   s.Auth.ClientToken,
+			Accessor:      s.Auth.Accessor,
+			Policies:      s.Auth.Policies,
+			Metadata:      s.Auth.Metadata,
+			LeaseDuration: s.Auth.LeaseDuration,
+			Renewable:     s.Auth.Renewable,
+		}
+	}
+	return resp, nil
+}
+
+// DefaultVaultTransitMount is the path the transit secrets engine is mounted at unless set with WithVaultTransitMount
+const DefaultVaultTransitMount = "transit"
+
+// EncryptTransit encrypts plaintext with the Vault transit key keyName, returning the ciphertext (eg, "vault:v1:...").
+// Backends other than Vault return ErrNotSupported.
+func (sc *SecretsClient) EncryptTransit(keyName string, plaintext []byte) ([]byte, error) {
+	vbg, ok := sc.backend.(*vaultBackendGetter)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	mount := vbg.config.transitMount
+	if mount == "" {
+		mount = DefaultVaultTransitMount
+	}
+	ct, err := vbg.vc.TransitEncrypt(mount, keyName, plaintext)
+	if err != nil {
+		return nil, sc.redactError(fmt.Errorf("error encrypting with transit key %v: %w", keyName, err))
+	}
+	return []byte(ct), nil
+}
+
+// VaultTokenTTL returns the remaining TTL of the Vault token in use. It returns ErrNotSupported for other backends.
+func (sc *SecretsClient) VaultTokenTTL() (time.Duration, error) {
+	vbg, ok := sc.backend.(*vaultBackendGetter)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	ttl, err := vbg.vc.TokenTTL()
+	return ttl, sc.redactError(err)
+}
+
 // vaultIO describes an object capable of interacting with Vault
 type vaultIO interface {
 	TokenAuth(token string) error
 	AppIDAuth(appid string, userid string, useridpath string) error
 	AppRoleAuth(roleid string) error
 	K8sAuth(jwt, roleid string) error
+	AgentTokenSinkAuth(path string) error
+	JWTAuth(jwt, role string) error
 	GetStringValue(path string) (string, error)
+	GetStringValueWithTTL(ctx context.Context, path string) (string, time.Duration, error)
 	GetBase64Value(path string) ([]byte, error)
+	GetValues(path string) (map[string]interface{}, error)
+	GetRaw(path string) (*api.Secret, http.Header, error)
+	TransitEncrypt(mount, key string, plaintext []byte) (string, error)
+	TokenTTL() (time.Duration, error)
+	SetToken(token string)
 }
 
 // vaultClient is the concrete implementation of vaultIO interacting with a real Vault server
 type vaultClient struct {
-	client *api.Client
-	config *vaultBackend
-	token  string
+	client     *api.Client
+	httpClient *http.Client
+	config     *vaultBackend
+
+	tokenMu       sync.Mutex // guards token and the cached lookup of it
+	token         string
+	lookup        *api.Secret // cached lookup-self response for token
+	lookupExpires time.Time
+	canRead       map[string]bool // whether token may read each path checked (see WithVaultCapabilityPrecheck)
 }
 
 var _ vaultIO = &vaultClient{}
@@ -110,20 +433,93 @@ var _ vaultIO = &vaultClient{}
 // newVaultClient returns a vaultClient object or error
 func newVaultClient(config *vaultBackend) (*vaultClient, error) {
 	vc := vaultClient{}
-	c, err := api.NewClient(&api.Config{Address: config.host})
+	if config.now == nil {
+		config.now = time.Now
+	}
+	hc, err := newVaultHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.hosts) > 1 {
+		hp, err := newHostPool(config.hosts, hc.Transport)
+		if err != nil {
+			return nil, err
+		}
+		hp.now = config.now
+		hc.Transport = hp
+	}
+	c, err := api.NewClient(&api.Config{Address: config.host, HttpClient: hc})
+	if err != nil {
+		return nil, err
+	}
+	headers := http.Header{"User-Agent": []string{userAgent(config.userAgent)}}
+	if config.namespace != "" {
+		headers.Set(vaultNamespaceHeader, config.namespace)
+	}
+	c.SetHeaders(headers)
 	vc.client = c
+	vc.httpClient = hc
 	vc.config = config
-	return &vc, err
+	if config.notFound == nil {
+		config.notFound = DefaultNotFoundDetector
+	}
+	return &vc, nil
+}
+
+// userAgent returns the User-Agent for Vault requests, with ua (if set) preceding the library identifier
+func userAgent(ua string) string {
+	lib := "pvc/" + Version
+	if ua == "" {
+		return lib
+	}
+	return ua + " " + lib
+}
+
+// newVaultHTTPClient returns the HTTP client used to talk to Vault, based on the Vault API defaults
+func newVaultHTTPClient(config *vaultBackend) (*http.Client, error) {
+	minTLS := config.minTLSVersion
+	if minTLS == 0 {
+		minTLS = DefaultVaultMinTLSVersion
+	}
+	switch minTLS {
+	case tls.VersionTLS12, tls.VersionTLS13:
+		break
+	default:
+		return nil, fmt.Errorf("insecure or unknown minimum TLS version: %#x (must be TLS 1.2 or later)", minTLS)
+	}
+	def := api.DefaultConfig()
+	if def.Error != nil {
+		return nil, fmt.Errorf("error getting default Vault config: %v", def.Error)
+	}
+	tr, ok := def.HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unexpected default Vault transport type: %T", def.HttpClient.Transport)
+	}
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{}
+	}
+	tr.TLSClientConfig.MinVersion = minTLS
+	if config.dial != nil {
+		tr.DialContext = config.dial
+	}
+	if config.connPool {
+		if config.maxIdleConns < 0 || config.maxIdleConnsPerHost < 0 || config.idleConnTimeout < 0 {
+			return nil, fmt.Errorf("connection pool settings must not be negative")
+		}
+		tr.DisableKeepAlives = false
+		tr.MaxIdleConns = config.maxIdleConns
+		tr.MaxIdleConnsPerHost = config.maxIdleConnsPerHost
+		tr.IdleConnTimeout = config.idleConnTimeout
+	}
+	return def.HttpClient, nil
 }
 
 // tokenAuth sets the client token but doesn't check validity
 func (c *vaultClient) TokenAuth(token string) error {
-	c.token = token
-	c.client.SetToken(token)
-	ta := c.client.Auth().Token()
+	c.setToken(token)
 	var err error
 	for i := 0; i <= int(c.config.authRetries); i++ {
-		_, err = ta.LookupSelf()
+		_, err = c.lookupSelf()
 		if err == nil {
 			break
 		}
@@ -164,10 +560,61 @@ func (c *vaultClient) getTokenAndConfirm(route string, payload interface{}) erro
 	}
 	body := output.(map[string]interface{})
 	auth := body["auth"].(map[string]interface{})
-	c.token = auth["client_token"].(string)
+	c.setToken(auth["client_token"].(string))
 	return nil
 }
 
+// setToken sets the token used for subsequent requests and invalidates any cached lookup of the previous one
+func (c *vaultClient) setToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.token = token
+	c.lookup = nil
+	c.canRead = nil
+}
+
+// getToken returns the token currently in use
+func (c *vaultClient) getToken() string {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.token
+}
+
+// SetToken replaces the token used for subsequent requests without checking its validity
+func (c *vaultClient) SetToken(token string) {
+	c.setToken(token)
+}
+
+// lookupSelf returns the lookup-self response for the current token, reusing a cached response if it is recent enough
+func (c *vaultClient) lookupSelf() (*api.Secret, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.lookup != nil && c.config.now().Before(c.lookupExpires) {
+		return c.lookup, nil
+	}
+	c.client.SetToken(c.token)
+	s, err := c.client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, err
+	}
+	ttl := c.config.tokenLookupCacheTTL
+	if ttl == 0 {
+		ttl = DefaultVaultTokenLookupCacheTTL
+	}
+	c.lookup = s
+	c.lookupExpires = c.config.now().Add(ttl)
+	return s, nil
+}
+
+// TokenTTL returns the remaining TTL of the current token
+func (c *vaultClient) TokenTTL() (time.Duration, error) {
+	s, err := c.lookupSelf()
+	if err != nil {
+		return 0, fmt.Errorf("error looking up token: %v", err)
+	}
+	return s.TokenTTL()
+}
+
 // appIDAuth attempts to perform app-id authorization.
 func (c *vaultClient) AppIDAuth(appid string, userid string, useridpath string) error {
 	if userid == "" {
@@ -184,7 +631,7 @@ func (c *vaultClient) AppIDAuth(appid string, userid string, useridpath string)
 		AppID:  appid,
 		UserID: string(userid),
 	}
-	return c.getTokenAndConfirm("/v1/auth/app-id/login", &bodystruct)
+	return c.getTokenAndConfirm(apiPath("auth/app-id/login"), &bodystruct)
 }
 
 func (c *vaultClient) AppRoleAuth(roleid string) error {
@@ -202,37 +649,508 @@ func (c *vaultClient) K8sAuth(jwt, roleid string) error {
 	if c.config.k8sauthpath == "" {
 		c.config.k8sauthpath = "kubernetes"
 	}
-	return c.getTokenAndConfirm(fmt.Sprintf("/v1/auth/%v/login", c.config.k8sauthpath), &payload)
+	return c.getTokenAndConfirm(apiPath("auth", c.config.k8sauthpath, "login"), &payload)
 }
 
-// getValue retrieves value at path
-func (c *vaultClient) getValue(path string) (interface{}, error) {
-	c.client.SetToken(c.token)
-	lc := c.client.Logical()
-	s, err := lc.Read(path)
+// JWTAuth logs in to the JWT auth method with jwt and role
+func (c *vaultClient) JWTAuth(jwt, role string) error {
+	payload := struct {
+		JWT  string `json:"jwt"`
+		Role string `json:"role"`
+	}{
+		JWT:  jwt,
+		Role: role,
+	}
+	if c.config.jwtAuthPath == "" {
+		c.config.jwtAuthPath = DefaultVaultJWTAuthPath
+	}
+	return c.getTokenAndConfirm(apiPath("auth", c.config.jwtAuthPath, "login"), &payload)
+}
+
+// DefaultVaultK8sTokenDir is the directory searched for a service account token projected for the audience set with
+// WithVaultK8sTokenAudience, which is expected to be named after the audience
+const DefaultVaultK8sTokenDir = "/var/run/secrets/tokens"
+
+// checkJWTAudience returns an error if aud is set and isn't one of the audiences of jwt. The signature isn't verified.
+func checkJWTAudience(jwt, aud string) error {
+	if aud == "" {
+		return nil
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fmt.Errorf("error decoding JWT claims: %v", err)
+	}
+	claims := struct {
+		Aud interface{} `json:"aud"` // a string or array of strings
+	}{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return fmt.Errorf("error decoding JWT claims: %v", err)
+	}
+	auds := []interface{}{claims.Aud}
+	if a, ok := claims.Aud.([]interface{}); ok {
+		auds = a
+	}
+	for _, a := range auds {
+		if a == aud {
+			return nil
+		}
+	}
+	return fmt.Errorf("JWT audience %v doesn't include %v", claims.Aud, aud)
+}
+
+// readJWT reads a JWT from the file at path
+func readJWT(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading JWT: %v", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// githubOIDCToken requests an ID token for the running GitHub Actions job, issued for aud if it is set
+func githubOIDCToken(aud string) (string, error) {
+	u, reqToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if u == "" || reqToken == "" {
+		return "", fmt.Errorf("ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN are required for GitHub OIDC authentication (does the job have the id-token: write permission?)")
+	}
+	if aud != "" {
+		sep := "?"
+		if strings.Contains(u, "?") {
+			sep = "&"
+		}
+		u += sep + "audience=" + url.QueryEscape(aud)
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating ID token request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+reqToken)
+	resp, err := (&http.Client{Timeout: 60 * time.Second}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting ID token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code requesting ID token: %v", resp.StatusCode)
+	}
+	out := struct {
+		Value string `json:"value"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("error decoding ID token response: %v", err)
+	}
+	if out.Value == "" {
+		return "", fmt.Errorf("ID token response has no token")
+	}
+	return out.Value, nil
+}
+
+// AgentTokenSinkAuth authenticates with the token Vault Agent writes to the sink file at path.
+// The file is re-read if a request is denied, as the agent may have replaced the token (see readSecret).
+func (c *vaultClient) AgentTokenSinkAuth(path string) error {
+	token, err := readAgentToken(path)
+	if err != nil {
+		return err
+	}
+	return c.TokenAuth(token)
+}
+
+// readAgentToken reads a token from a Vault Agent sink file
+func readAgentToken(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token sink: %v", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token sink is empty: %v", path)
+	}
+	return token, nil
+}
+
+// newRequest returns a request for the Vault API, with any trace propagation headers from ctx
+func (c *vaultClient) newRequest(ctx context.Context, method, path string) *api.Request {
+	req := c.client.NewRequest(method, path)
+	req.ClientToken = c.getToken()
+	ns, nsok := namespaceFromContext(ctx)
+	if c.config.traceHeaders != nil || nsok {
+		// the headers are shared with the client, so must be copied before modifying
+		h := copyHeader(req.Headers)
+		if c.config.traceHeaders != nil {
+			c.config.traceHeaders(ctx, h)
+		}
+		if nsok {
+			h.Set(vaultNamespaceHeader, ns)
+		}
+		req.Headers = h
+	}
+	return req
+}
+
+// copyHeader returns a copy of h that can be modified without affecting h
+func copyHeader(h http.Header) http.Header {
+	c := http.Header{}
+	for k, v := range h {
+		c[k] = v
+	}
+	return c
+}
+
+// rawRequest performs req, unlike api.Client.RawRequest passing ctx to the HTTP client so that it can be
+// canceled and observed by the transport (eg, for tracing). A single redirect (eg, from a standby node) is followed.
+func (c *vaultClient) rawRequest(ctx context.Context, req *api.Request) (*api.Response, error) {
+	var resp *http.Response
+	for redirects := 0; ; redirects++ {
+		hr, err := req.ToHTTP()
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+		resp, err = c.httpClient.Do(hr.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect:
+			if redirects > 0 {
+				break
+			}
+			loc, err := resp.Location()
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("error following redirect: %v", err)
+			}
+			if req.URL.Scheme == "https" && loc.Scheme != "https" {
+				return nil, fmt.Errorf("redirect would cause protocol downgrade")
+			}
+			req.URL = loc
+			if err := req.ResetJSONBody(); err != nil {
+				return nil, fmt.Errorf("error resetting request body: %v", err)
+			}
+			continue
+		}
+		break
+	}
+	result := &api.Response{Response: resp}
+	return result, result.Error()
+}
+
+// DefaultVaultReadRetryDelay is the delay before the first retry of a failed read (see WithVaultReadRetries)
+const DefaultVaultReadRetryDelay = 100 * time.Millisecond
+
+// retryable returns whether a read that returned resp (which is nil if there was no response) and err is worth retrying
+func (c *vaultClient) retryable(resp *api.Response, err error) bool {
+	if resp == nil {
+		if c.config.retryableError != nil {
+			return c.config.retryableError(err)
+		}
+		return true
+	}
+	if c.config.retryableStatuses != nil {
+		for _, s := range c.config.retryableStatuses {
+			if resp.StatusCode == s {
+				return true
+			}
+		}
+		return false
+	}
+	return resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// read performs a GET of path, retrying transient failures as configured
+func (c *vaultClient) read(ctx context.Context, path string) (*api.Response, error) {
+	delay := time.Duration(atomic.LoadInt64((*int64)(&c.config.readRetryDelay)))
+	retries := atomic.LoadUint64(&c.config.readRetries)
+	if delay == 0 {
+		delay = DefaultVaultReadRetryDelay
+	}
+	for i := 0; ; i++ {
+		req := c.newRequest(ctx, "GET", apiPath(path))
+		if c.config.forwardToActive {
+			req.Headers = copyHeader(req.Headers)
+			req.Headers.Set(vaultForwardHeader, "active-node")
+		}
+		resp, err := c.rawRequest(ctx, req)
+		if err == nil || uint64(i) >= retries || ctx.Err() != nil || !c.retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// checkCanRead returns ErrInsufficientCapabilities if the token may not read path, according to sys/capabilities-self.
+// The result for each path is cached until the token changes.
+func (c *vaultClient) checkCanRead(ctx context.Context, path string) error {
+	c.tokenMu.Lock()
+	ok, checked := c.canRead[path]
+	c.tokenMu.Unlock()
+	if !checked {
+		caps, err := c.capabilities(ctx, path)
+		if err != nil {
+			return fmt.Errorf("error checking capabilities on %v: %v", path, err)
+		}
+		for _, cp := range caps {
+			if cp == "read" || cp == "root" {
+				ok = true
+			}
+		}
+		c.tokenMu.Lock()
+		if c.canRead == nil {
+			c.canRead = map[string]bool{}
+		}
+		c.canRead[path] = ok
+		c.tokenMu.Unlock()
+	}
+	if !ok {
+		return fmt.Errorf("%w: token can't read %v", ErrInsufficientCapabilities, path)
+	}
+	return nil
+}
+
+// capabilities returns the capabilities of the token on path
+func (c *vaultClient) capabilities(ctx context.Context, path string) ([]string, error) {
+	req := c.newRequest(ctx, "POST", apiPath("sys/capabilities-self"))
+	if err := req.SetJSONBody(map[string][]string{"paths": {path}}); err != nil {
+		return nil, fmt.Errorf("error setting JSON body: %v", err)
+	}
+	resp, err := c.rawRequest(ctx, req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := api.ParseSecret(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	// the capabilities are keyed by path, and also by "capabilities" when a single path is given
+	caps, ok := s.Data[path].([]interface{})
+	if !ok {
+		caps, _ = s.Data["capabilities"].([]interface{})
+	}
+	out := make([]string, 0, len(caps))
+	for _, cp := range caps {
+		if s, ok := cp.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// readSecret reads the secret at path, also returning the response headers
+func (c *vaultClient) readSecret(ctx context.Context, path string) (*api.Secret, http.Header, error) {
+	if c.config.capabilityPrecheck {
+		if err := c.checkCanRead(ctx, path); err != nil {
+			return nil, nil, err
+		}
+	}
+	resp, err := c.read(ctx, path)
+	if resp != nil && resp.StatusCode == http.StatusForbidden && c.config.agentTokenSink != "" {
+		// the agent may have rotated the token since it was last read, so retry once with the current one
+		resp.Body.Close()
+		token, terr := readAgentToken(c.config.agentTokenSink)
+		if terr != nil {
+			return nil, nil, fmt.Errorf("error reading secret from Vault: %v: %v (%v)", path, err, terr)
+		}
+		c.setToken(token)
+		resp, err = c.read(ctx, path)
+	}
+	var body []byte
+	if resp != nil {
+		defer resp.Body.Close()
+		var rerr error
+		body, rerr = ioutil.ReadAll(resp.Body)
+		if rerr != nil {
+			return nil, nil, fmt.Errorf("error reading response from Vault: %v: %v", path, rerr)
+		}
+		if c.config.notFound(resp.StatusCode, body) {
+			return nil, nil, ErrSecretNotFound
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error reading secret from Vault: %v: %v", path, err)
+		if resp != nil && resp.StatusCode == http.StatusServiceUnavailable {
+			if serr := c.checkSealed(ctx); serr != nil {
+				return nil, nil, serr
+			}
+		}
+		return nil, nil, fmt.Errorf("error reading secret from Vault: %v: %v", path, err)
+	}
+	s, err := api.ParseSecret(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing secret from Vault: %v: %v", path, err)
 	}
 	if s == nil {
-		return nil, fmt.Errorf("secret not found")
+		return nil, nil, ErrSecretNotFound
+	}
+	if len(s.Warnings) > 0 && c.config.warningHandler != nil {
+		id, ok := ctx.Value(secretIDKey{}).(string)
+		if !ok {
+			id = path
+		}
+		c.config.warningHandler(id, s.Warnings)
+	}
+	return s, resp.Header, nil
+}
+
+// checkSealed returns ErrVaultSealed, with the unseal progress, if sys/seal-status reports that Vault is sealed
+func (c *vaultClient) checkSealed(ctx context.Context) error {
+	resp, err := c.rawRequest(ctx, c.newRequest(ctx, "GET", apiPath("sys/seal-status")))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil
+	}
+	status := struct {
+		Sealed   bool `json:"sealed"`
+		T        int  `json:"t"`
+		Progress int  `json:"progress"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil || !status.Sealed {
+		return nil
+	}
+	return fmt.Errorf("%w (unseal progress: %v of %v key shares)", ErrVaultSealed, status.Progress, status.T)
+}
+
+// vaultForwardHeader is the header asking a performance standby to forward a request to the active node
+const vaultForwardHeader = "X-Vault-Forward"
+
+// vaultNamespaceHeader is the header selecting the Vault Enterprise namespace of a request
+const vaultNamespaceHeader = "X-Vault-Namespace"
+
+type namespaceKey struct{}
+
+// WithNamespaceContext returns a copy of ctx that makes Vault requests made with it (eg, by GetContext) use the
+// namespace ns, overriding WithVaultNamespace. Gets in a namespace set this way bypass the cache.
+func WithNamespaceContext(ctx context.Context, ns string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, ns)
+}
+
+// namespaceFromContext returns the namespace set on ctx with WithNamespaceContext, if any
+func namespaceFromContext(ctx context.Context) (string, bool) {
+	ns, ok := ctx.Value(namespaceKey{}).(string)
+	return ns, ok
+}
+
+// secretIDKey is the context key of the secret ID being read, for the warning handler
+type secretIDKey struct{}
+
+// getValue retrieves value at path, along with the cache TTL suggested by Vault
+func (c *vaultClient) getValue(ctx context.Context, path string) (interface{}, time.Duration, error) {
+	s, h, err := c.readSecret(ctx, path)
+	if err != nil {
+		return nil, 0, err
 	}
 	if _, ok := s.Data["value"]; !ok {
-		return nil, fmt.Errorf("secret missing 'value' key")
+		return nil, 0, fmt.Errorf("secret missing 'value' key")
+	}
+	return s.Data["value"], cacheTTL(s, h), nil
+}
+
+// cacheTTL returns how long Vault suggests the secret s may be cached for, from the Cache-Control max-age
+// header if present, or otherwise the lease duration (which for KV version 1 reflects the "ttl" field).
+// It returns zero if there is no suggestion and a negative duration if the secret must not be cached.
+func cacheTTL(s *api.Secret, h http.Header) time.Duration {
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.TrimSpace(strings.ToLower(d))
+		switch {
+		case d == "no-store" || d == "no-cache":
+			return -1
+		case strings.HasPrefix(d, "max-age="):
+			secs, err := strconv.Atoi(strings.TrimPrefix(d, "max-age="))
+			if err != nil || secs < 0 {
+				continue
+			}
+			if secs == 0 {
+				return -1
+			}
+			return time.Duration(secs) * time.Second
+		}
+	}
+	if s.LeaseDuration > 0 {
+		return time.Duration(s.LeaseDuration) * time.Second
+	}
+	return 0
+}
+
+// GetValues retrieves every field of the secret at path. For KV version 2 secrets the fields are taken from the inner data object.
+func (c *vaultClient) GetValues(path string) (map[string]interface{}, error) {
+	s, _, err := c.readSecret(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	return secretFields(s.Data), nil
+}
+
+// GetRaw retrieves the secret at path, along with the response headers, without interpreting its data
+func (c *vaultClient) GetRaw(path string) (*api.Secret, http.Header, error) {
+	return c.readSecret(context.Background(), path)
+}
+
+// TransitEncrypt encrypts plaintext with the key named key of the transit secrets engine mounted at mount
+func (c *vaultClient) TransitEncrypt(mount, key string, plaintext []byte) (string, error) {
+	ctx := context.Background()
+	req := c.newRequest(ctx, "POST", apiPath(mount, "encrypt", url.PathEscape(key)))
+	err := req.SetJSONBody(map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)})
+	if err != nil {
+		return "", fmt.Errorf("error setting JSON body: %v", err)
+	}
+	resp, err := c.rawRequest(ctx, req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return "", fmt.Errorf("error performing request: %v", err)
+	}
+	s, err := api.ParseSecret(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
 	}
-	return s.Data["value"], nil
+	ct, ok := s.Data["ciphertext"].(string)
+	if !ok {
+		return "", fmt.Errorf("response missing ciphertext")
+	}
+	return ct, nil
+}
+
+// secretFields returns the fields of secret data, unwrapping the KV version 2 {"data": {...}, "metadata": {...}} envelope if present
+func secretFields(data map[string]interface{}) map[string]interface{} {
+	inner, ok := data["data"].(map[string]interface{})
+	if _, hasMetadata := data["metadata"]; ok && hasMetadata && len(data) == 2 {
+		return inner
+	}
+	return data
 }
 
 // GetStringValue retrieves a value expected to be a string
 func (c *vaultClient) GetStringValue(path string) (string, error) {
-	val, err := c.getValue(path)
+	val, _, err := c.GetStringValueWithTTL(context.Background(), path)
+	return val, err
+}
+
+// GetStringValueWithTTL retrieves a value expected to be a string, along with the cache TTL suggested by Vault (see cacheTTL)
+func (c *vaultClient) GetStringValueWithTTL(ctx context.Context, path string) (string, time.Duration, error) {
+	val, ttl, err := c.getValue(ctx, path)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	switch val := val.(type) {
 	case string:
-		return val, nil
+		return val, ttl, nil
 	default:
-		return "", fmt.Errorf("unexpected type for %v value: %T", path, val)
+		return "", 0, fmt.Errorf("unexpected type for %v value: %T", path, val)
 	}
 }
 
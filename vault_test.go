@@ -1,14 +1,38 @@
 package pvc
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/dollarshaveclub/pvc/mocks"
 	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/vault/api"
 )
 
 var testVaultBackend = vaultBackend{}
 
+func testSecretMapper(t *testing.T, mapping string) *secretMapper {
+	sm, err := newSecretMapper(mapping)
+	if err != nil {
+		t.Fatalf("error getting secret mapper: %v", err)
+	}
+	return sm
+}
+
 func TestNewVaultBackendGetter(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -48,3 +72,1188 @@ func TestVaultBackendGet(t *testing.T) {
 		t.Fatalf("bad value: %v (wanted %v)", s, value)
 	}
 }
+
+func TestVaultTokenTTL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mvc := mocks.NewMockvaultIO(ctrl)
+	mvc.EXPECT().TokenTTL().Return(time.Hour, nil).Times(1)
+	sc := &SecretsClient{backend: &vaultBackendGetter{vc: mvc}}
+	ttl, err := sc.VaultTokenTTL()
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if ttl != time.Hour {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+}
+
+func TestVaultTokenTTLNotVault(t *testing.T) {
+	sc := &SecretsClient{backend: &envVarBackendGetter{}}
+	if _, err := sc.VaultTokenTTL(); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+// testVaultServer returns an httptest server that responds to token lookup-self, counting the calls made
+func testVaultServer(t *testing.T, lookups *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/token/lookup-self", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(lookups, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"id": "` + r.Header.Get("X-Vault-Token") + `", "ttl": 3600}}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestVaultClientTokenLookupCache(t *testing.T) {
+	var lookups int32
+	ts := testVaultServer(t, &lookups)
+	defer ts.Close()
+	vc, err := newVaultClient(&vaultBackend{host: ts.URL, tokenLookupCacheTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	if err := vc.TokenAuth("footoken"); err != nil {
+		t.Fatalf("error authenticating: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		ttl, err := vc.TokenTTL()
+		if err != nil {
+			t.Fatalf("error getting ttl: %v", err)
+		}
+		if ttl != time.Hour {
+			t.Fatalf("bad ttl: %v", ttl)
+		}
+	}
+	if n := atomic.LoadInt32(&lookups); n != 1 {
+		t.Fatalf("expected 1 lookup-self request, got %v", n)
+	}
+	// re-authenticating invalidates the cache
+	if err := vc.TokenAuth("bartoken"); err != nil {
+		t.Fatalf("error authenticating: %v", err)
+	}
+	if _, err := vc.TokenTTL(); err != nil {
+		t.Fatalf("error getting ttl: %v", err)
+	}
+	if n := atomic.LoadInt32(&lookups); n != 2 {
+		t.Fatalf("expected 2 lookup-self requests, got %v", n)
+	}
+}
+
+func TestVaultClientTokenLookupCacheExpiry(t *testing.T) {
+	var lookups int32
+	ts := testVaultServer(t, &lookups)
+	defer ts.Close()
+	vc, err := newVaultClient(&vaultBackend{host: ts.URL, tokenLookupCacheTTL: time.Millisecond})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	if err := vc.TokenAuth("footoken"); err != nil {
+		t.Fatalf("error authenticating: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := vc.TokenTTL(); err != nil {
+		t.Fatalf("error getting ttl: %v", err)
+	}
+	if n := atomic.LoadInt32(&lookups); n != 2 {
+		t.Fatalf("expected 2 lookup-self requests, got %v", n)
+	}
+}
+
+func TestVaultBackendGetFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mvc := mocks.NewMockvaultIO(ctrl)
+	mvc.EXPECT().GetValues("secret/db").Return(map[string]interface{}{
+		"username": "admin",
+		"password": "hunter2",
+		"port":     json.Number("5432"),
+	}, nil).Times(1)
+	vbg := &vaultBackendGetter{vc: mvc, mapper: testSecretMapper(t, DefaultVaultMapping), config: &vaultBackend{}}
+	sc := &SecretsClient{backend: vbg}
+	fields, err := sc.GetFields("db")
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	expected := map[string]string{"username": "admin", "password": "hunter2", "port": "5432"}
+	if len(fields) != len(expected) {
+		t.Fatalf("bad fields: %v", fields)
+	}
+	for k, v := range expected {
+		if string(fields[k]) != v {
+			t.Fatalf("bad value for %v: %v (expected %v)", k, string(fields[k]), v)
+		}
+	}
+}
+
+func TestGetFieldsNotSupported(t *testing.T) {
+	sc := &SecretsClient{backend: &envVarBackendGetter{}}
+	if _, err := sc.GetFields("foo"); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+func TestSecretFieldsKVv2(t *testing.T) {
+	s, err := api.ParseSecret(strings.NewReader(`{
+		"data": {
+			"data": {"username": "admin", "password": "hunter2"},
+			"metadata": {"version": 3}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("error parsing secret: %v", err)
+	}
+	fields := secretFields(s.Data)
+	if len(fields) != 2 || fields["username"] != "admin" || fields["password"] != "hunter2" {
+		t.Fatalf("bad fields: %v", fields)
+	}
+}
+
+func TestSecretFieldsKVv1(t *testing.T) {
+	s, err := api.ParseSecret(strings.NewReader(`{"data": {"data": "foo", "value": "bar"}}`))
+	if err != nil {
+		t.Fatalf("error parsing secret: %v", err)
+	}
+	fields := secretFields(s.Data)
+	if len(fields) != 2 || fields["data"] != "foo" || fields["value"] != "bar" {
+		t.Fatalf("bad fields: %v", fields)
+	}
+}
+
+func TestVaultClientGetValues(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"username": "admin", "password": "hunter2"}, "metadata": {"version": 1}}}`))
+	}))
+	defer ts.Close()
+	vc, err := newVaultClient(&vaultBackend{host: ts.URL})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	vals, err := vc.GetValues("secret/data/db")
+	if err != nil {
+		t.Fatalf("should have succeeded: %v", err)
+	}
+	if vals["password"] != "hunter2" {
+		t.Fatalf("bad values: %v", vals)
+	}
+	if _, err := vc.GetValues("secret/data/missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, received: %v", err)
+	}
+}
+
+func TestNewVaultClientInsecureMinTLSVersion(t *testing.T) {
+	for _, v := range []uint16{tls.VersionTLS10, tls.VersionTLS11, 0x1234} {
+		if _, err := newVaultClient(&vaultBackend{host: "https://foo", minTLSVersion: v}); err == nil {
+			t.Fatalf("should have failed for version %#x", v)
+		}
+	}
+}
+
+// testTLSVaultClient returns a Vault client trusting the certificate of ts
+func testTLSVaultClient(t *testing.T, ts *httptest.Server, minTLS uint16) *vaultClient {
+	vc, err := newVaultClient(&vaultBackend{host: ts.URL, minTLSVersion: minTLS})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	vc.httpClient.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+	return vc
+}
+
+func TestVaultClientMinTLSVersion(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	defer ts.Close()
+
+	vc := testTLSVaultClient(t, ts, 0)
+	if _, err := vc.GetStringValue("secret/foo"); err != nil {
+		t.Fatalf("should have succeeded with default minimum version: %v", err)
+	}
+
+	vc = testTLSVaultClient(t, ts, tls.VersionTLS13)
+	_, err := vc.GetStringValue("secret/foo")
+	if err == nil {
+		t.Fatalf("handshake should have failed")
+	}
+	if !strings.Contains(err.Error(), "protocol version") {
+		t.Fatalf("expected protocol version error, received: %v", err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cases := []struct {
+		cacheControl  string
+		leaseDuration int
+		expected      time.Duration
+	}{
+		{"", 0, 0},
+		{"", 300, 5 * time.Minute},
+		{"max-age=120", 0, 2 * time.Minute},
+		{"public, max-age=60", 300, time.Minute},
+		{"max-age=0", 300, -1},
+		{"no-store", 0, -1},
+		{"max-age=bogus", 0, 0},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		if c.cacheControl != "" {
+			h.Set("Cache-Control", c.cacheControl)
+		}
+		ttl := cacheTTL(&api.Secret{LeaseDuration: c.leaseDuration}, h)
+		if ttl != c.expected {
+			t.Fatalf("bad ttl for %q/%v: %v (expected %v)", c.cacheControl, c.leaseDuration, ttl, c.expected)
+		}
+	}
+}
+
+func TestVaultCacheExpiryFromHeader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/secret/hinted" {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	vc, err := newVaultClient(&vaultBackend{host: ts.URL})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	vbg := &vaultBackendGetter{vc: vc, mapper: testSecretMapper(t, DefaultVaultMapping), config: &vaultBackend{}}
+	sc := &SecretsClient{backend: vbg, cache: newSecretCache(time.Minute)}
+	for _, id := range []string{"hinted", "unhinted"} {
+		s, err := sc.Get(id)
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if string(s) != "foo" {
+			t.Fatalf("bad value: %v", string(s))
+		}
+	}
+	now := time.Now()
+	if e := sc.cache.entries["hinted"].expires; e.Before(now.Add(59 * time.Minute)) {
+		t.Fatalf("expiry should follow Cache-Control header: %v", e)
+	}
+	if e := sc.cache.entries["unhinted"].expires; e.After(now.Add(time.Minute)) {
+		t.Fatalf("expiry should fall back to cache TTL: %v", e)
+	}
+}
+
+func TestSetVaultToken(t *testing.T) {
+	var lookups int32
+	tokens := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/token/lookup-self" {
+			atomic.AddInt32(&lookups, 1)
+			w.Write([]byte(`{"data": {"ttl": 3600}}`))
+			return
+		}
+		tokens <- r.Header.Get("X-Vault-Token")
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(Token), WithVaultToken("oldtoken"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if tok := <-tokens; tok != "oldtoken" {
+		t.Fatalf("bad token: %v", tok)
+	}
+	if err := sc.SetVaultToken("newtoken"); err != nil {
+		t.Fatalf("error setting token: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if tok := <-tokens; tok != "newtoken" {
+		t.Fatalf("bad token after SetVaultToken: %v", tok)
+	}
+	if n := atomic.LoadInt32(&lookups); n != 1 {
+		t.Fatalf("SetVaultToken should not look up the token: %v lookups", n)
+	}
+}
+
+func TestSetVaultTokenNotVault(t *testing.T) {
+	sc := &SecretsClient{backend: &envVarBackendGetter{}}
+	if err := sc.SetVaultToken("foo"); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+type traceKey struct{}
+
+func TestVaultTracePropagationHeaders(t *testing.T) {
+	var got atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("traceparent"))
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	vb := &vaultBackend{host: ts.URL, traceHeaders: func(ctx context.Context, h http.Header) {
+		if tp, ok := ctx.Value(traceKey{}).(string); ok {
+			h.Set("traceparent", tp)
+		}
+	}}
+	vc, err := newVaultClient(vb)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	var transportSaw atomic.Value
+	rt := vc.httpClient.Transport
+	vc.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if tp, ok := r.Context().Value(traceKey{}).(string); ok {
+			transportSaw.Store(tp)
+		}
+		return rt.RoundTrip(r)
+	})
+	vbg := &vaultBackendGetter{vc: vc, mapper: testSecretMapper(t, DefaultVaultMapping), config: vb}
+	sc := &SecretsClient{backend: vbg}
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if _, err := sc.GetContext(context.WithValue(context.Background(), traceKey{}, tp), "foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.Load() != tp {
+		t.Fatalf("server should have received traceparent: %v", got.Load())
+	}
+	if transportSaw.Load() != tp {
+		t.Fatalf("transport should have received the request context: %v", transportSaw.Load())
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.Load() != "" {
+		t.Fatalf("traceparent should be absent without a span in the context: %v", got.Load())
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestVaultAgentTokenSink(t *testing.T) {
+	var current atomic.Value
+	current.Store("token1")
+	var denied int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/token/lookup-self" {
+			w.Write([]byte(`{"data": {"ttl": 3600}}`))
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != current.Load() {
+			atomic.AddInt32(&denied, 1)
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	sink := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(sink, []byte("token1\n"), 0600); err != nil {
+		t.Fatalf("error writing sink: %v", err)
+	}
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAgentTokenSink(sink))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	// the agent rotates the token
+	current.Store("token2")
+	if err := ioutil.WriteFile(sink, []byte("token2\n"), 0600); err != nil {
+		t.Fatalf("error writing sink: %v", err)
+	}
+	s, err := sc.Get("foo")
+	if err != nil {
+		t.Fatalf("get should have succeeded after re-reading the sink: %v", err)
+	}
+	if string(s) != "foo" {
+		t.Fatalf("bad value: %v", string(s))
+	}
+	if n := atomic.LoadInt32(&denied); n != 1 {
+		t.Fatalf("expected a single denied request: %v", n)
+	}
+	// a token that's still rejected after re-reading the sink is an error
+	current.Store("token3")
+	if _, err := sc.Get("foo"); err == nil {
+		t.Fatalf("get should have failed with a stale sink")
+	}
+	if n := atomic.LoadInt32(&denied); n != 3 {
+		t.Fatalf("expected only one retry: %v denied requests", n)
+	}
+}
+
+func TestVaultAgentTokenSinkMissing(t *testing.T) {
+	_, err := NewSecretsClient(WithVaultBackend(), WithVaultHost("http://127.0.0.1:8200"), WithVaultAgentTokenSink(filepath.Join(t.TempDir(), "missing")))
+	if err == nil {
+		t.Fatalf("should have failed with missing sink file")
+	}
+}
+
+func TestVaultBackendGetterNormalize(t *testing.T) {
+	vbg := &vaultBackendGetter{mapper: testSecretMapper(t, "{{ .ID }}")}
+	path, err := vbg.locate("//app//db-password.v2")
+	if err != nil {
+		t.Fatalf("locate failed: %v", err)
+	}
+	if path != "app/db-password.v2" {
+		t.Fatalf("bad path: %v", path)
+	}
+}
+
+func TestVaultNotFoundDetector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/secret/missing" {
+			w.Write([]byte(`{"errors": ["secret does not exist"]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	detect := func(status int, body []byte) bool {
+		return status == http.StatusNotFound || (status == http.StatusOK && strings.Contains(string(body), `"errors"`))
+	}
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithNotFoundDetector(detect))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+	s, err := sc.Get("foo")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(s) != "foo" {
+		t.Fatalf("bad value: %v", string(s))
+	}
+}
+
+func TestVaultDefaultNotFoundDetector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors": []}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("should have returned ErrSecretNotFound: %v", err)
+	}
+}
+
+func TestVaultJWTAuth(t *testing.T) {
+	var readToken atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/ci-jwt/login":
+			body := struct {
+				JWT  string `json:"jwt"`
+				Role string `json:"role"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.JWT != "eyJ.test.jwt" || body.Role != "ci" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"auth": {"client_token": "jwttoken"}}`))
+		default:
+			readToken.Store(r.Header.Get("X-Vault-Token"))
+			w.Write([]byte(`{"data": {"value": "foo"}}`))
+		}
+	}))
+	defer ts.Close()
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(jwtFile, []byte("eyJ.test.jwt\n"), 0600); err != nil {
+		t.Fatalf("error writing JWT: %v", err)
+	}
+	for _, op := range []SecretsClientOption{WithVaultJWT("eyJ.test.jwt"), WithVaultJWTPath(jwtFile)} {
+		sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), op, WithVaultJWTRole("ci"), WithVaultJWTAuthPath("ci-jwt"))
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		if _, err := sc.Get("foo"); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if tok := readToken.Load(); tok != "jwttoken" {
+			t.Fatalf("read should use the token from JWT login: %v", tok)
+		}
+	}
+}
+
+func TestVaultJWTAudience(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"auth": {"client_token": "jwttoken"}}`))
+	}))
+	defer ts.Close()
+	jwt := testJWT("https://vault.example.com")
+	_, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultJWT(jwt), WithVaultJWTRole("ci"),
+		WithVaultJWTAudience("https://vault.example.com"), WithVaultK8sTokenAudience("vault"))
+	if err != nil {
+		t.Fatalf("the Kubernetes audience shouldn't apply to JWT authentication: %v", err)
+	}
+	_, err = NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultJWT(jwt), WithVaultJWTRole("ci"),
+		WithVaultJWTAudience("vault"))
+	if err == nil || !strings.Contains(err.Error(), "audience") {
+		t.Fatalf("should have rejected a token for the wrong audience: %v", err)
+	}
+}
+
+func TestVaultJWTAuthMissingRole(t *testing.T) {
+	_, err := NewSecretsClient(WithVaultBackend(), WithVaultHost("http://127.0.0.1:8200"), WithVaultJWT("eyJ.test.jwt"))
+	if err == nil {
+		t.Fatalf("should have failed without a role")
+	}
+}
+
+func TestGetRaw(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Vault-Custom", "yes")
+		w.Write([]byte(`{
+			"lease_id": "secret/foo/abc",
+			"lease_duration": 3600,
+			"renewable": true,
+			"data": {"value": "foo", "rotated_at": "2026-01-01", "replicas": 3},
+			"warnings": ["Endpoint ignored these unrecognized parameters: [bar]"]
+		}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	resp, err := sc.GetRaw("foo")
+	if err != nil {
+		t.Fatalf("get raw failed: %v", err)
+	}
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "unrecognized parameters") {
+		t.Fatalf("bad warnings: %v", resp.Warnings)
+	}
+	if resp.Data["rotated_at"] != "2026-01-01" || resp.Data["replicas"] != json.Number("3") {
+		t.Fatalf("bad data: %v", resp.Data)
+	}
+	if resp.LeaseID != "secret/foo/abc" || resp.LeaseDuration != 3600 || !resp.Renewable || resp.Auth != nil {
+		t.Fatalf("bad lease: %+v", resp)
+	}
+	if resp.Header.Get("X-Vault-Custom") != "yes" {
+		t.Fatalf("bad header: %v", resp.Header)
+	}
+	m, err := sc.Masker()
+	if err != nil {
+		t.Fatalf("error getting Masker: %v", err)
+	}
+	if s := m.Mask("rotated at 2026-01-01"); s != "rotated at ***" {
+		t.Fatalf("values from GetRaw should be masked: %q", s)
+	}
+}
+
+func TestGetRawNotVault(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.GetRaw("foo"); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+func TestVaultWarningHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/secret/old" {
+			w.Write([]byte(`{"data": {"value": "foo"}, "warnings": ["path is deprecated", "use secret/new"]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "bar"}}`))
+	}))
+	defer ts.Close()
+	got := map[string][]string{}
+	handler := func(id string, warnings []string) {
+		got[id] = warnings
+	}
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithVaultWarningHandler(handler))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for _, id := range []string{"old", "new"} {
+		if _, err := sc.Get(id); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if len(got) != 1 || len(got["old"]) != 2 || got["old"][0] != "path is deprecated" || got["old"][1] != "use secret/new" {
+		t.Fatalf("bad warnings: %v", got)
+	}
+	if _, err := sc.GetFields("old"); err != nil {
+		t.Fatalf("get fields failed: %v", err)
+	}
+	if len(got["secret/old"]) != 2 {
+		t.Fatalf("GetFields warnings should be reported by path: %v", got)
+	}
+	sc, err = NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("old"); err != nil {
+		t.Fatalf("get without handler failed: %v", err)
+	}
+}
+
+func TestVaultUserAgent(t *testing.T) {
+	var got atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("User-Agent"))
+		if r.URL.Path == "/v1/auth/token/lookup-self" {
+			w.Write([]byte(`{"data": {"ttl": 3600}}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	for ua, want := range map[string]string{"": "pvc/" + Version, "myapp/1.2": "myapp/1.2 pvc/" + Version} {
+		got.Store("")
+		sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(Token), WithVaultToken("foo"), WithVaultUserAgent(ua))
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		if got.Load() != want {
+			t.Fatalf("bad User-Agent for token lookup: %v", got.Load())
+		}
+		got.Store("")
+		if _, err := sc.Get("foo"); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if got.Load() != want {
+			t.Fatalf("bad User-Agent for read: %v", got.Load())
+		}
+	}
+}
+
+// flakyVaultServer returns a Vault server that fails the first failures reads with status
+func flakyVaultServer(failures int32, status int, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"errors": ["try again"]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+}
+
+func TestVaultReadRetries(t *testing.T) {
+	var calls int32
+	ts := flakyVaultServer(2, http.StatusServiceUnavailable, &calls)
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithVaultReadRetries(2), WithVaultReadRetryDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil {
+		t.Fatalf("get should have succeeded after retries: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %v", calls)
+	}
+}
+
+func TestVaultRetryableStatusCodes(t *testing.T) {
+	var calls int32
+	ts := flakyVaultServer(1, http.StatusBadRequest, &calls)
+	defer ts.Close()
+	ops := []SecretsClientOption{WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithVaultReadRetries(1), WithVaultReadRetryDelay(time.Millisecond)}
+	sc, err := NewSecretsClient(ops...)
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err == nil || calls != 1 {
+		t.Fatalf("400 should not be retried by default: %v (%v calls)", err, calls)
+	}
+	atomic.StoreInt32(&calls, 0)
+	sc, err = NewSecretsClient(append(ops, WithRetryableStatusCodes(http.StatusBadRequest))...)
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err != nil || calls != 2 {
+		t.Fatalf("400 should have been retried: %v (%v calls)", err, calls)
+	}
+}
+
+func TestVaultRetryableErrorFunc(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+	var asked int32
+	retryable := func(err error) bool {
+		atomic.AddInt32(&asked, 1)
+		return false
+	}
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(url), WithVaultAuthentication(None), WithVaultReadRetries(3), WithVaultReadRetryDelay(time.Millisecond), WithRetryableErrorFunc(retryable))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.Get("foo"); err == nil {
+		t.Fatalf("should have failed")
+	}
+	if asked != 1 {
+		t.Fatalf("connection error should have been classified once and not retried: %v", asked)
+	}
+}
+
+func TestVaultEncryptTransit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&in)
+		if r.Method != "POST" || r.URL.Path != "/v1/encryption/encrypt/my-key" || in["plaintext"] != "aHVudGVyMg==" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data": {"ciphertext": "vault:v1:abcdef", "key_version": 1}}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithVaultTransitMount("encryption"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	ct, err := sc.EncryptTransit("my-key", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if string(ct) != "vault:v1:abcdef" {
+		t.Fatalf("bad ciphertext: %v", string(ct))
+	}
+	if _, err := sc.EncryptTransit("other-key", []byte("hunter2")); err == nil {
+		t.Fatalf("should have failed for an error response")
+	}
+}
+
+func TestVaultEncryptTransitNotVault(t *testing.T) {
+	sc, err := NewSecretsClient(WithEnvVarBackend())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if _, err := sc.EncryptTransit("my-key", []byte("hunter2")); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("should have returned ErrNotSupported: %v", err)
+	}
+}
+
+func TestVaultCapabilityPrecheck(t *testing.T) {
+	var checks int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/capabilities-self":
+			atomic.AddInt32(&checks, 1)
+			in := struct{ Paths []string }{}
+			json.NewDecoder(r.Body).Decode(&in)
+			caps := `["list"]`
+			if len(in.Paths) == 1 && in.Paths[0] == "secret/allowed" {
+				caps = `["read", "list"]`
+			}
+			w.Write([]byte(`{"data": {"capabilities": ` + caps + `, "` + in.Paths[0] + `": ` + caps + `}}`))
+		case "/v1/secret/allowed", "/v1/secret/denied":
+			w.Write([]byte(`{"data": {"value": "foo"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None), WithVaultCapabilityPrecheck())
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := sc.Get("allowed"); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if _, err := sc.Get("denied"); !errors.Is(err, ErrInsufficientCapabilities) {
+			t.Fatalf("should have returned ErrInsufficientCapabilities: %v", err)
+		}
+	}
+	if checks != 2 {
+		t.Fatalf("capabilities should be checked once per path, got %v checks", checks)
+	}
+	if err := sc.SetVaultToken("other"); err != nil {
+		t.Fatalf("set token failed: %v", err)
+	}
+	sc.Get("allowed")
+	if checks != 3 {
+		t.Fatalf("capabilities should be checked again for a new token, got %v checks", checks)
+	}
+}
+
+func TestVaultSealed(t *testing.T) {
+	for _, sealed := range []bool{true, false} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/sys/seal-status" {
+				fmt.Fprintf(w, `{"type": "shamir", "sealed": %v, "t": 3, "n": 5, "progress": 1}`, sealed)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"errors": ["Vault is sealed"]}`))
+		}))
+		sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None))
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		_, err = sc.Get("foo")
+		ts.Close()
+		if err == nil {
+			t.Fatalf("get should have failed")
+		}
+		if errors.Is(err, ErrVaultSealed) != sealed {
+			t.Fatalf("sealed %v: bad error: %v", sealed, err)
+		}
+		if sealed && !strings.Contains(err.Error(), "1 of 3") {
+			t.Fatalf("error should include the unseal progress: %v", err)
+		}
+	}
+}
+
+// testJWT returns an unsigned JWT with the audience claim aud
+func testJWT(aud interface{}) string {
+	claims, _ := json.Marshal(map[string]interface{}{"aud": aud, "sub": "system:serviceaccount:default:app"})
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+}
+
+func TestVaultK8sTokenAudience(t *testing.T) {
+	jwt := testJWT([]string{"vault", "other"})
+	var loginJWT atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			body := struct {
+				JWT  string `json:"jwt"`
+				Role string `json:"role"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			loginJWT.Store(body.JWT)
+			w.Write([]byte(`{"auth": {"client_token": "k8stoken"}}`))
+		default:
+			w.Write([]byte(`{"data": {"value": "foo"}}`))
+		}
+	}))
+	defer ts.Close()
+	tokenFile := filepath.Join(t.TempDir(), "vault")
+	if err := ioutil.WriteFile(tokenFile, []byte(jwt+"\n"), 0600); err != nil {
+		t.Fatalf("error writing token: %v", err)
+	}
+	_, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultK8sAuth("", "app"),
+		WithVaultK8sTokenAudience("vault"), WithVaultK8sTokenPath(tokenFile))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	if v := loginJWT.Load(); v != jwt {
+		t.Fatalf("login should use the projected token: %v", v)
+	}
+	_, err = NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultK8sAuth(testJWT("https://kubernetes.default.svc"), "app"),
+		WithVaultK8sTokenAudience("vault"))
+	if err == nil || !strings.Contains(err.Error(), "audience") {
+		t.Fatalf("should have rejected a token for the wrong audience: %v", err)
+	}
+}
+
+func TestVaultK8sTokenAudienceDefaultPath(t *testing.T) {
+	vb := &vaultBackend{host: "http://127.0.0.1:8200", authentication: K8s, k8sTokenAudience: "pvc-test-audience"}
+	_, err := newVaultBackendGetter(vb, nil)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(DefaultVaultK8sTokenDir, "pvc-test-audience")) {
+		t.Fatalf("should have read the token projected for the audience: %v", err)
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	for _, c := range []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"secret", "foo"}, "secret/foo"},
+		{[]string{"secret/", "/foo"}, "secret/foo"},
+		{[]string{"/secret//", "", "foo//bar/"}, "secret/foo/bar"},
+		{[]string{"", "/", "//"}, ""},
+		{[]string{"kv/data/", "app/", "db"}, "kv/data/app/db"},
+	} {
+		if got := joinPath(c.parts...); got != c.want {
+			t.Fatalf("joinPath(%q) = %q (expected %q)", c.parts, got, c.want)
+		}
+	}
+	if got := apiPath("/transit/", "encrypt", "key"); got != "/v1/transit/encrypt/key" {
+		t.Fatalf("bad API path: %v", got)
+	}
+}
+
+func TestVaultNamespaceContext(t *testing.T) {
+	namespaces := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.Header.Get("X-Vault-Namespace")
+		namespaces <- ns
+		fmt.Fprintf(w, `{"data": {"value": %q}}`, "value in "+ns)
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None),
+		WithVaultNamespace("default-ns"), WithCache(time.Minute))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for _, c := range []struct {
+		ns, want string
+	}{
+		{"", "default-ns"},
+		{"tenant-a", "tenant-a"},
+		{"tenant-b", "tenant-b"},
+	} {
+		ctx := context.Background()
+		if c.ns != "" {
+			ctx = WithNamespaceContext(ctx, c.ns)
+		}
+		v, err := sc.GetContext(ctx, "foo")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if ns := <-namespaces; ns != c.want {
+			t.Fatalf("request should use namespace %v: %v", c.want, ns)
+		}
+		if string(v) != "value in "+c.want {
+			t.Fatalf("bad value: %v", string(v))
+		}
+	}
+}
+
+func TestVaultDialContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	var dialed int32
+	// stands in for a tunnel to the bastion, which connects to the real server whatever address is asked for
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dialed, 1)
+		if addr != "vault.internal:8200" {
+			return nil, fmt.Errorf("unexpected address: %v", addr)
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+	}
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost("http://vault.internal:8200"), WithVaultAuthentication(None), WithVaultDialContext(dial))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	v, err := sc.Get("foo")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if string(v) != "foo" {
+		t.Fatalf("bad value: %v", string(v))
+	}
+	if atomic.LoadInt32(&dialed) == 0 {
+		t.Fatalf("request should have used the dialer")
+	}
+}
+
+func TestVaultGitHubOIDC(t *testing.T) {
+	jwt := testJWT("https://vault.example.com")
+	actions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer reqtoken" || r.URL.Query().Get("audience") != "https://vault.example.com" ||
+			r.URL.Query().Get("api-version") != "2.0" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"count": 1, "value": "` + jwt + `"}`))
+	}))
+	defer actions.Close()
+	var logins int32
+	var readToken atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/jwt/login":
+			body := struct {
+				JWT  string `json:"jwt"`
+				Role string `json:"role"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.JWT != jwt || body.Role != "ci" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			atomic.AddInt32(&logins, 1)
+			w.Write([]byte(`{"auth": {"client_token": "citoken"}}`))
+		default:
+			readToken.Store(r.Header.Get("X-Vault-Token"))
+			w.Write([]byte(`{"data": {"value": "foo"}}`))
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", actions.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "reqtoken")
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultGitHubOIDC("ci"), WithVaultGitHubOIDCAudience("https://vault.example.com"))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := sc.Get("foo"); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if tok := readToken.Load(); tok != "citoken" {
+		t.Fatalf("read should use the token from JWT login: %v", tok)
+	}
+	if n := atomic.LoadInt32(&logins); n != 1 {
+		t.Fatalf("the Vault token should be reused, got %v logins", n)
+	}
+}
+
+func TestVaultGitHubOIDCOutsideActions(t *testing.T) {
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+	_, err := NewSecretsClient(WithVaultBackend(), WithVaultHost("http://127.0.0.1:8200"), WithVaultGitHubOIDC("ci"))
+	if err == nil || !strings.Contains(err.Error(), "ACTIONS_ID_TOKEN_REQUEST_URL") {
+		t.Fatalf("should have failed without the Actions environment: %v", err)
+	}
+}
+
+func TestVaultForwardToActive(t *testing.T) {
+	forwards := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwards <- r.Header.Get("X-Vault-Forward")
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	for _, enabled := range []bool{false, true} {
+		ops := []SecretsClientOption{WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None)}
+		want := ""
+		if enabled {
+			ops = append(ops, WithVaultForwardToActive())
+			want = "active-node"
+		}
+		sc, err := NewSecretsClient(ops...)
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		if _, err := sc.Get("foo"); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if f := <-forwards; f != want {
+			t.Fatalf("bad X-Vault-Forward header with forwarding %v: %q", enabled, f)
+		}
+	}
+}
+
+func TestVaultGetPath(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data/app/config" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {
+			"data": {"db_user": "admin", "db_password": "hunter2", "pool_size": 10},
+			"metadata": {"version": 3}
+		}}`))
+	}))
+	defer ts.Close()
+	sc, err := NewSecretsClient(WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None))
+	if err != nil {
+		t.Fatalf("error getting SecretsClient: %v", err)
+	}
+	fields, err := sc.GetPath("/kv/data/app/config")
+	if err != nil {
+		t.Fatalf("get path failed: %v", err)
+	}
+	expected := map[string]string{"db_user": "admin", "db_password": "hunter2", "pool_size": "10"}
+	if len(fields) != len(expected) {
+		t.Fatalf("bad fields: %v", fields)
+	}
+	for k, v := range expected {
+		if string(fields[k]) != v {
+			t.Fatalf("bad value for %v: %v (expected %v)", k, string(fields[k]), v)
+		}
+	}
+	if _, err := sc.GetPath("kv/data/app/missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestVaultGetPathNotSupported(t *testing.T) {
+	sc := &SecretsClient{backend: &envVarBackendGetter{}}
+	if _, err := sc.GetPath("kv/data/app"); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, received: %v", err)
+	}
+}
+
+func TestVaultConnectionPool(t *testing.T) {
+	c, err := newVaultHTTPClient(&vaultBackend{connPool: true, maxIdleConns: 50, maxIdleConnsPerHost: 10, idleConnTimeout: time.Minute})
+	if err != nil {
+		t.Fatalf("error creating HTTP client: %v", err)
+	}
+	tr := c.Transport.(*http.Transport)
+	if tr.DisableKeepAlives || tr.MaxIdleConns != 50 || tr.MaxIdleConnsPerHost != 10 || tr.IdleConnTimeout != time.Minute {
+		t.Fatalf("pool settings not applied: keep-alives disabled %v, %v, %v, %v", tr.DisableKeepAlives, tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+	if _, err := newVaultHTTPClient(&vaultBackend{connPool: true, maxIdleConnsPerHost: -1}); err == nil {
+		t.Fatalf("should have failed with a negative setting")
+	}
+	// connections are reused only with pooling
+	for _, pooled := range []bool{false, true} {
+		var conns int32
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data": {"value": "foo"}}`))
+		}))
+		ts.Config.ConnState = func(c net.Conn, s http.ConnState) {
+			if s == http.StateNew {
+				atomic.AddInt32(&conns, 1)
+			}
+		}
+		ts.Start()
+		ops := []SecretsClientOption{WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None)}
+		if pooled {
+			ops = append(ops, WithVaultConnectionPool(10, 10, time.Minute))
+		}
+		sc, err := NewSecretsClient(ops...)
+		if err != nil {
+			t.Fatalf("error getting SecretsClient: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := sc.Get("foo"); err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+		}
+		ts.Close()
+		if n := atomic.LoadInt32(&conns); (pooled && n != 1) || (!pooled && n != 5) {
+			t.Fatalf("unexpected number of connections with pooling %v: %v", pooled, n)
+		}
+	}
+}
+
+func BenchmarkVaultConnectionPool(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"value": "foo"}}`))
+	}))
+	defer ts.Close()
+	for name, ops := range map[string][]SecretsClientOption{
+		"default": nil,
+		"pooled":  {WithVaultConnectionPool(100, 100, time.Minute)},
+	} {
+		b.Run(name, func(b *testing.B) {
+			sc, err := NewSecretsClient(append([]SecretsClientOption{WithVaultBackend(), WithVaultHost(ts.URL), WithVaultAuthentication(None)}, ops...)...)
+			if err != nil {
+				b.Fatalf("error getting SecretsClient: %v", err)
+			}
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := sc.Get("foo"); err != nil {
+						// not Fatalf, as this runs on other goroutines than the benchmark's
+						b.Errorf("get failed: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}